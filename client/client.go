@@ -0,0 +1,276 @@
+// Package client is the Go SDK of the user service. Client wraps the typed
+// client generated from api.yml into package api. It logs in and attaches
+// the bearer token, logs in again when the token is rejected, retries
+// failed requests with an exponential backoff and decodes error responses
+// into *Error.
+//
+//	c, err := client.New("https://users.example.com", client.Options{
+//		Credentials: &client.Credentials{PhoneNumber: "+6281234567890", Password: password},
+//	})
+//	profile, err := c.GetProfile(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"InterviewBackendSawitProGolang/client/api"
+
+	openapi_types "github.com/deepmap/oapi-codegen/pkg/types"
+)
+
+// Defaults of the zero Options fields.
+const (
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// Credentials identify the user by PhoneNumber or, when it is empty, by a
+// verified Email.
+type Credentials struct {
+	PhoneNumber string
+	Email       string
+	Password    string
+}
+
+type Options struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient api.HttpRequestDoer
+	// Token authenticates the user endpoints until it is rejected.
+	Token string
+	// Credentials are used to log in when there is no token and again
+	// when the token is rejected.
+	Credentials *Credentials
+	// AdminToken authenticates the /admin endpoints.
+	AdminToken string
+	// MaxRetries is how often a failed request is retried, negative
+	// disables retries.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the delay before a retry, it doubles
+	// with every attempt. A Retry-After header takes precedence, up to
+	// MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type Client struct {
+	// API is the generated client sharing the token handling and retries
+	// of Client, for the responses its methods do not return.
+	API *api.ClientWithResponses
+
+	opts     Options
+	basePath string
+
+	mu    sync.Mutex
+	token string
+}
+
+// New returns a client of the service at server, its base URL.
+func New(server string, opts Options) (*Client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("parsing server URL: %w", err)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	c := &Client{
+		opts:     opts,
+		basePath: strings.TrimSuffix(u.Path, "/"),
+		token:    opts.Token,
+	}
+	c.API, err = api.NewClientWithResponses(server, api.WithHTTPClient(c))
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Token returns the bearer token in use, empty before the first login.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) Register(ctx context.Context, req api.RegisterRequest) (*api.RegisterResponse, error) {
+	res, err := c.API.RegisterWithResponse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON201)
+}
+
+// Login logs in with creds and uses the token for the following calls.
+func (c *Client) Login(ctx context.Context, creds Credentials) (*api.LoginResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.login(ctx, creds)
+}
+
+// login must be called with c.mu held.
+func (c *Client) login(ctx context.Context, creds Credentials) (*api.LoginResponse, error) {
+	body := api.LoginJSONRequestBody{Password: &creds.Password}
+	if creds.PhoneNumber != "" {
+		body.PhoneNumber = &creds.PhoneNumber
+	} else {
+		email := openapi_types.Email(creds.Email)
+		body.Email = &email
+	}
+	res, err := c.API.LoginWithResponse(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	login, err := expect(res.HTTPResponse, res.Body, res.JSON200)
+	if err != nil {
+		return nil, err
+	}
+	if login.Token == nil {
+		return nil, fmt.Errorf("login response has no token")
+	}
+	c.token = *login.Token
+	return login, nil
+}
+
+func (c *Client) GetProfile(ctx context.Context) (*api.GetProfileResponse, error) {
+	res, err := c.API.GetProfileWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) UpdateProfile(ctx context.Context, req api.UpdateProfileRequest) (*api.UpdateProfileResponse, error) {
+	res, err := c.API.UpdateProfileWithResponse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+// UploadAvatar uploads the image read from r as the user's avatar.
+func (c *Client) UploadAvatar(ctx context.Context, filename string, r io.Reader) (*api.Avatar, error) {
+	// The body is buffered so retries can send it again.
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("avatar", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("reading avatar: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	res, err := c.API.UploadAvatarWithBodyWithResponse(ctx, w.FormDataContentType(), &body)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) SendEmailVerification(ctx context.Context) (*api.MessageResponse, error) {
+	res, err := c.API.SendEmailVerificationWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON202)
+}
+
+func (c *Client) VerifyEmail(ctx context.Context, token string) (*api.MessageResponse, error) {
+	res, err := c.API.VerifyEmailWithResponse(ctx, &api.VerifyEmailParams{Token: token})
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) CreateWebhook(ctx context.Context, req api.CreateWebhookRequest) (*api.CreatedWebhook, error) {
+	res, err := c.API.CreateWebhookWithResponse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON201)
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) (*api.WebhookList, error) {
+	res, err := c.API.ListWebhooksWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id api.WebhookID) (*api.Webhook, error) {
+	res, err := c.API.GetWebhookWithResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id api.WebhookID) error {
+	res, err := c.API.DeleteWebhookWithResponse(ctx, id)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() != http.StatusNoContent {
+		return decodeError(res.HTTPResponse, res.Body)
+	}
+	return nil
+}
+
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id api.WebhookID, params *api.ListWebhookDeliveriesParams) (*api.WebhookDeliveryList, error) {
+	res, err := c.API.ListWebhookDeliveriesWithResponse(ctx, id, params)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) GetWebhookDelivery(ctx context.Context, id api.WebhookID, deliveryID api.DeliveryID) (*api.WebhookDeliveryDetail, error) {
+	res, err := c.API.GetWebhookDeliveryWithResponse(ctx, id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON200)
+}
+
+func (c *Client) RedeliverWebhookDelivery(ctx context.Context, id api.WebhookID, deliveryID api.DeliveryID) (*api.MessageResponse, error) {
+	res, err := c.API.RedeliverWebhookDeliveryWithResponse(ctx, id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return expect(res.HTTPResponse, res.Body, res.JSON202)
+}
+
+// expect returns the decoded body of a successful response, or the *Error
+// of any other.
+func expect[T any](resp *http.Response, body []byte, value *T) (*T, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, decodeError(resp, body)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return value, nil
+}
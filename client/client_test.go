@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/client/api"
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/handler"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	phoneNumber = "+6281234567890"
+	password    = "Passw0rd!"
+	adminToken  = "admin-token"
+)
+
+// testServer serves the real handlers behind the spec validator. Before
+// passes a request on, it can answer it instead by returning true.
+type testServer struct {
+	*httptest.Server
+	Before   func(w http.ResponseWriter, r *http.Request) bool
+	requests atomic.Int32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	mw, err := middleware.NewMiddleware(middleware.Options{
+		PublicKey:  signer.PublicKey(),
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+		AdminToken: adminToken,
+	})
+	require.NoError(t, err)
+	e := echo.New()
+	e.Use(mw)
+	generated.RegisterHandlers(e, handler.NewServer(handler.NewServerOptions{
+		Repository:  repository.NewMemoryRepository(),
+		TokenSigner: signer,
+		BcryptCost:  bcrypt.MinCost,
+	}))
+
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.Before != nil && s.Before(w, r) {
+			return
+		}
+		// The validator matches the host of the servers in the spec.
+		r.Host = "localhost:8080"
+		e.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newClient(t *testing.T, s *testServer, opts Options) *Client {
+	opts.MinBackoff = time.Millisecond
+	opts.MaxBackoff = 10 * time.Millisecond
+	c, err := New(s.URL, opts)
+	require.NoError(t, err)
+	return c
+}
+
+func register(t *testing.T, c *Client) {
+	_, err := c.Register(context.Background(), api.RegisterRequest{
+		PhoneNumber: ptr(phoneNumber),
+		FullName:    ptr("Test User"),
+		Password:    ptr(password),
+	})
+	require.NoError(t, err)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestLogsInWithCredentials(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{Credentials: &Credentials{PhoneNumber: phoneNumber, Password: password}})
+	register(t, c)
+
+	profile, err := c.GetProfile(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Test User", *profile.FullName)
+	require.NotEmpty(t, c.Token())
+
+	_, err = c.UpdateProfile(context.Background(), api.UpdateProfileRequest{
+		PhoneNumber: ptr(phoneNumber),
+		FullName:    ptr("Renamed User"),
+	})
+	require.NoError(t, err)
+	profile, err = c.GetProfile(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Renamed User", *profile.FullName)
+}
+
+func TestLogsInAgainWhenTokenIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{
+		Token:       "expired",
+		Credentials: &Credentials{PhoneNumber: phoneNumber, Password: password},
+	})
+	register(t, c)
+
+	_, err := c.GetProfile(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, "expired", c.Token())
+}
+
+func TestRejectedTokenWithoutCredentials(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{Token: "expired"})
+
+	_, err := c.GetProfile(context.Background())
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	require.EqualValues(t, 1, s.requests.Load())
+}
+
+func TestLoginWithWrongPassword(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{})
+	register(t, c)
+
+	_, err := c.Login(context.Background(), Credentials{PhoneNumber: phoneNumber, Password: "Wrong0rd!"})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.NotEmpty(t, apiErr.Message)
+	require.Empty(t, c.Token())
+}
+
+func TestDecodesFieldErrors(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{})
+
+	_, err := c.Register(context.Background(), api.RegisterRequest{
+		PhoneNumber: ptr("12345"),
+		FullName:    ptr("Test User"),
+		Password:    ptr(password),
+	})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Contains(t, apiErr.Fields, "phoneNumber")
+}
+
+func TestDecodesConflict(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{})
+	register(t, c)
+
+	_, err := c.Register(context.Background(), api.RegisterRequest{
+		PhoneNumber: ptr(phoneNumber),
+		FullName:    ptr("Other User"),
+		Password:    ptr(password),
+	})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusConflict, apiErr.StatusCode)
+}
+
+func TestRetriesServiceUnavailable(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{})
+	var failures atomic.Int32
+	s.Before = func(w http.ResponseWriter, r *http.Request) bool {
+		if failures.Add(1) > 2 {
+			return false
+		}
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	}
+
+	register(t, c)
+	require.EqualValues(t, 3, s.requests.Load())
+}
+
+func TestDoesNotRetryPostOnInternalError(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{})
+	s.Before = func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, `{"message":"boom"}`, http.StatusInternalServerError)
+		return true
+	}
+
+	_, err := c.Register(context.Background(), api.RegisterRequest{
+		PhoneNumber: ptr(phoneNumber),
+		FullName:    ptr("Test User"),
+		Password:    ptr(password),
+	})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	require.Equal(t, "boom", apiErr.Message)
+	require.EqualValues(t, 1, s.requests.Load())
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{Token: "token", MaxRetries: 2})
+	s.Before = func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusBadGateway)
+		return true
+	}
+
+	_, err := c.GetProfile(context.Background())
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	require.EqualValues(t, 3, s.requests.Load())
+}
+
+func TestStopsRetryingWhenContextIsDone(t *testing.T) {
+	s := newTestServer(t)
+	c, err := New(s.URL, Options{Token: "token", MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	require.NoError(t, err)
+	s.Before = func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.GetProfile(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestAdminToken(t *testing.T) {
+	s := newTestServer(t)
+	c := newClient(t, s, Options{AdminToken: adminToken})
+
+	created, err := c.CreateWebhook(context.Background(), api.CreateWebhookRequest{Url: "https://example.com/hooks"})
+	require.NoError(t, err)
+	webhook, err := c.GetWebhook(context.Background(), created.Id)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/hooks", webhook.Url)
+	require.NoError(t, c.DeleteWebhook(context.Background(), created.Id))
+
+	_, err = newClient(t, s, Options{}).ListWebhooks(context.Background())
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestIntrospectionKeepsClientCredentials(t *testing.T) {
+	s := newTestServer(t)
+	var authorization string
+	s.Before = func(w http.ResponseWriter, r *http.Request) bool {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"active":false}`))
+		return true
+	}
+	c := newClient(t, s, Options{
+		Token:       "user-token",
+		Credentials: &Credentials{PhoneNumber: phoneNumber, Password: password},
+	})
+
+	resp, err := c.API.IntrospectTokenWithBodyWithResponse(context.Background(),
+		"application/x-www-form-urlencoded", strings.NewReader("token=token"),
+		func(ctx context.Context, req *http.Request) error {
+			req.SetBasicAuth("gateway", "secret")
+			return nil
+		})
+	require.NoError(t, err)
+	require.False(t, resp.JSON200.Active)
+	require.Equal(t, "Basic Z2F0ZXdheTpzZWNyZXQ=", authorization)
+}
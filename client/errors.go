@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a response with a status other than the documented success,
+// use errors.As to inspect it:
+//
+//	var apiErr *client.Error
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+type Error struct {
+	StatusCode int
+	// Message is the message of the response, or the status text when it
+	// has none.
+	Message string
+	// Fields lists the messages of every invalid field of a 400 response
+	// by its path, e.g. "address.city".
+	Fields map[string][]string
+	// RetryAfter is the delay asked for by a 503 response.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("user service: %d %s", e.StatusCode, e.Message)
+	}
+	paths := make([]string, 0, len(e.Fields))
+	for path := range e.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var messages []string
+	for _, path := range paths {
+		messages = append(messages, e.Fields[path]...)
+	}
+	return fmt.Sprintf("user service: %d %s", e.StatusCode, strings.Join(messages, "; "))
+}
+
+func decodeError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp),
+	}
+	// Both ErrorResponse and ErrorBadRequestResponse.
+	var payload struct {
+		Message string              `json:"message"`
+		Errors  map[string][]string `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		e.Message = payload.Message
+		e.Fields = payload.Errors
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+// retryAfter returns the delay of a Retry-After header in seconds, zero
+// when there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
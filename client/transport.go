@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// publicPaths are the endpoints called without a bearer token.
+var publicPaths = map[string]bool{
+	"/users/register":     true,
+	"/users/login":        true,
+	"/users/email/verify": true,
+	"/oauth/introspect":   true,
+}
+
+// oauthPrefix starts the paths of the OAuth endpoints, whose callers
+// authenticate with their own client credentials rather than a bearer
+// token.
+const oauthPrefix = "/oauth/"
+
+// Do sends the requests of the generated client. It authenticates them,
+// logs in again once when the token is rejected and retries those failing
+// with a network error or a 5xx status that are safe to repeat: any of an
+// idempotent method, and only 503s, which the service answers before doing
+// anything, of the others.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	endpoint := strings.TrimPrefix(req.URL.Path, c.basePath)
+	admin := strings.HasPrefix(endpoint, "/admin/")
+	bearer := !admin && !publicPaths[endpoint] && !strings.HasPrefix(endpoint, oauthPrefix)
+	relogged, sent := false, false
+	for attempt := 0; ; attempt++ {
+		r := req.Clone(ctx)
+		if req.Body != nil && sent {
+			if req.GetBody == nil {
+				return nil, errors.New("request body cannot be sent again")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		if admin && c.opts.AdminToken != "" {
+			r.Header.Set("X-Admin-Token", c.opts.AdminToken)
+		}
+		var token string
+		if bearer {
+			var err error
+			if token, err = c.bearerToken(ctx); err != nil {
+				return nil, err
+			}
+			if token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+
+		resp, err := c.opts.HTTPClient.Do(r)
+		sent = true
+		if err == nil && bearer && !relogged && c.opts.Credentials != nil &&
+			(resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			discard(resp)
+			c.forgetToken(token)
+			relogged = true
+			attempt--
+			continue
+		}
+		if attempt >= c.opts.MaxRetries || !retryable(req.Method, resp, err) {
+			return resp, err
+		}
+		delay := c.backoff(attempt, resp)
+		if resp != nil {
+			discard(resp)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// bearerToken returns the token, logging in first when there is none and
+// there are credentials.
+func (c *Client) bearerToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" && c.opts.Credentials != nil {
+		if _, err := c.login(ctx, *c.opts.Credentials); err != nil {
+			return "", err
+		}
+	}
+	return c.token, nil
+}
+
+// forgetToken drops a rejected token unless another request already
+// replaced it.
+func (c *Client) forgetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	delay := c.opts.MinBackoff
+	for i := 0; i < attempt && delay < c.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if resp != nil {
+		if after := retryAfter(resp); after > 0 {
+			delay = after
+		}
+	}
+	if delay > c.opts.MaxBackoff {
+		delay = c.opts.MaxBackoff
+	}
+	return delay
+}
+
+func retryable(method string, resp *http.Response, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	idempotent := method == http.MethodGet || method == http.MethodHead ||
+		method == http.MethodPut || method == http.MethodDelete
+	if err != nil {
+		return idempotent
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	return idempotent && resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// discard drains and closes the body of a response that is not returned,
+// so its connection can be reused.
+func discard(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -1,40 +1,426 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/grpcapi"
 	"InterviewBackendSawitProGolang/handler"
+	"InterviewBackendSawitProGolang/migrations"
+	"InterviewBackendSawitProGolang/pkg/blobstore"
+	"InterviewBackendSawitProGolang/pkg/cache"
+	"InterviewBackendSawitProGolang/pkg/config"
+	"InterviewBackendSawitProGolang/pkg/health"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/logging"
+	"InterviewBackendSawitProGolang/pkg/mail"
+	"InterviewBackendSawitProGolang/pkg/metrics"
 	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/pkg/migrate"
+	"InterviewBackendSawitProGolang/pkg/operation"
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/pkg/signedlink"
+	"InterviewBackendSawitProGolang/pkg/tracing"
+	"InterviewBackendSawitProGolang/pkg/validator"
+	"InterviewBackendSawitProGolang/pkg/webhook"
 	"InterviewBackendSawitProGolang/repository"
 
 	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
-	"log"
-	// "net/http"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
+// blobsPath is where files of the local blob store are served.
+const blobsPath = "/blobs"
+
+// Probe endpoints, served outside the spec and without authentication.
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	metricsPath   = "/metrics"
+)
+
+var databaseBackoff = repository.Backoff{Initial: 250 * time.Millisecond, Max: 5 * time.Second}
+
+const usage = `usage: main [command] [flags]
+
+commands:
+  serve                            run the HTTP server (default)
+  migrate up|down|status|create    manage database migrations, see "main migrate"
+  config print                     print the resolved configuration with secrets redacted
+
+Every command accepts -config FILE and one flag per setting, e.g.
+-server.listen_addr=:8080, see "main serve -h".
+`
+
 func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || len(args[0]) > 0 && args[0][0] == '-' {
+		exitOnError(serve(args))
+		return
+	}
+	switch args[0] {
+	case "serve":
+		exitOnError(serve(args[1:]))
+	case "migrate":
+		runMigrate(args[1:])
+	case "config":
+		runConfig(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// exitOnError exits with status 1 when err, which was already logged, is
+// set. It is called after the deferred cleanup of the command ran.
+func exitOnError(err error) {
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadConfig parses the flags registered on flags so far together with the
+// config flags and returns the validated configuration.
+func loadConfig(flags *flag.FlagSet, args []string) config.Config {
+	loader := config.NewLoader(flags)
+	flags.Parse(args)
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading config")
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid config")
+	}
+	if err := logging.Setup(cfg.LogOptions()); err != nil {
+		log.Fatal().Err(err).Msg("error setting up logging")
+	}
+	return cfg
+}
+
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cfg := loadConfig(flag.NewFlagSet("config print", flag.ExitOnError), args[1:])
+	fmt.Print(cfg)
+}
+
+// serve runs the servers until SIGTERM or SIGINT. It returns an error, after
+// logging it and cleaning up, when a server cannot listen.
+func serve(args []string) error {
+	cfg := loadConfig(flag.NewFlagSet("serve", flag.ExitOnError), args)
+	if cfg.UsesDevelopmentKey() {
+		log.Warn().Msg("auth.private_key is not set, signing tokens with the development key")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracingOptions())
+	if err != nil {
+		log.Fatal().Err(err).Msg("error setting up tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("error flushing traces")
+		}
+	}()
+
+	checks := health.NewRegistry(cfg.Server.ReadinessTimeout)
+	repo, closeRepo := openStore(ctx, cfg, checks)
+	defer closeRepo()
+	// The workers stop with ctx, before the repository is closed.
+	var workers sync.WaitGroup
+	defer func() {
+		stop()
+		workers.Wait()
+	}()
+	var sinks []outbox.Sink
+	if cfg.Outbox.Sink != config.OutboxSinkNone {
+		sinks = append(sinks, outbox.LogSink{Logger: log.Logger})
+	}
+	if cfg.Webhook.Enabled {
+		sinks = append(sinks, webhook.Sink{Repository: repo})
+		deliverer := webhook.NewDeliverer(webhook.DelivererOptions{
+			Repository:   repo,
+			Timeout:      cfg.Webhook.Timeout,
+			PollInterval: cfg.Webhook.PollInterval,
+			MaxAttempts:  cfg.Webhook.MaxAttempts,
+			MinBackoff:   cfg.Webhook.MinBackoff,
+			MaxBackoff:   cfg.Webhook.MaxBackoff,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			deliverer.Run(log.Logger.WithContext(ctx))
+		}()
+	}
+	if len(sinks) > 0 {
+		dispatcher := outbox.NewDispatcher(outbox.DispatcherOptions{
+			Repository:   repo,
+			Sinks:        sinks,
+			PollInterval: cfg.Outbox.PollInterval,
+			BatchSize:    cfg.Outbox.BatchSize,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			dispatcher.Run(log.Logger.WithContext(ctx))
+		}()
+	}
+	repo, closeCache := withCache(ctx, cfg, repo)
+	defer closeCache()
+
+	signer, err := jwt.NewSigner(cfg.SignerOptions())
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating token signer")
+	}
+	jwsValidator, err := middleware.NewJWSValidator(middleware.Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     cfg.Auth.KeyID,
+		Issuer:    cfg.Auth.Issuer,
+		Audience:  cfg.Auth.Audience,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating token validator")
+	}
+	h := newServer(cfg, repo, signer, jwsValidator)
+
 	e := echo.New()
-	mw, err := middleware.NewMiddleware()
+	e.HideBanner = true
+	e.HidePort = true
+	mw, err := middleware.NewMiddleware(middleware.Options{
+		PublicKey:            signer.PublicKey(),
+		KeyID:                cfg.Auth.KeyID,
+		Issuer:               cfg.Auth.Issuer,
+		Audience:             cfg.Auth.Audience,
+		AdminToken:           cfg.Admin.Token,
+		SkipPrefixes:         []string{blobsPath + "/"},
+		SkipPaths:            []string{livenessPath, readinessPath, metricsPath},
+		IntrospectionClients: cfg.IntrospectionClients(),
+		CheckUser:            h.Service.TokenActive,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("error creating middleware")
+	}
+	validator.SetPasswordPolicy(cfg.Password)
+	spec, err := generated.GetSwagger()
 	if err != nil {
-		log.Fatalln("error creating middleware:", err)
+		log.Fatal().Err(err).Msg("error loading spec")
 	}
-	e.Use(echoMiddleware.Logger())
+
+	checks.Register("signing_key", signer)
+
+	e.Use(tracing.Middleware(cfg.Tracing.ServiceName, livenessPath, readinessPath, metricsPath))
+	e.Use(logging.Middleware(log.Logger, operation.Resolver(spec)))
+	e.Use(metrics.Middleware(spec))
+	e.Use(echoMiddleware.BodyLimit(cfg.Server.BodyLimit))
+	e.Use(readYourWrites)
 	e.Use(mw)
-	var server generated.ServerInterface = newServer()
+	var server generated.ServerInterface = h
+	e.Static(blobsPath, cfg.Storage.BlobDir)
+	e.GET(livenessPath, health.LivenessHandler)
+	e.GET(readinessPath, checks.ReadinessHandler)
+	e.GET(metricsPath, echo.WrapHandler(metrics.Handler()))
 
 	generated.RegisterHandlers(e, server)
-	e.Logger.Fatal(e.Start(":1323"))
+
+	errs := make(chan error, 2)
+	var grpcServer *grpc.Server
+	if cfg.GRPC.ListenAddr != "" {
+		grpcServer = grpcapi.NewServer(grpcapi.Options{
+			Service:       h.Service,
+			Validator:     jwsValidator,
+			InternalToken: cfg.GRPC.InternalToken,
+			BlobStore:     h.BlobStore,
+			Logger:        log.Logger,
+		})
+		lis, err := net.Listen("tcp", cfg.GRPC.ListenAddr)
+		if err != nil {
+			log.Error().Err(err).Msg("error starting gRPC server")
+			return err
+		}
+		log.Info().Str("addr", cfg.GRPC.ListenAddr).Msg("listening for gRPC")
+		go func() {
+			errs <- grpcServer.Serve(lis)
+		}()
+	}
+	log.Info().Str("addr", cfg.Server.ListenAddr).Msg("listening")
+	go func() {
+		errs <- e.Start(cfg.Server.ListenAddr)
+	}()
+	select {
+	case err := <-errs:
+		// Start and Serve only return early when they cannot listen.
+		log.Error().Err(err).Msg("error starting server")
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Info().Dur("timeout", cfg.Server.ShutdownTimeout).Msg("shutting down, draining requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if grpcServer != nil {
+		// GracefulStop waits for the calls in flight, Stop cancels those
+		// still running when the timeout expires.
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-shutdownCtx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("error draining requests")
+	}
+	return nil
 }
 
-func newServer() *handler.Server {
-	dbDsn := os.Getenv("DATABASE_URL")
-	var repo repository.RepositoryInterface = repository.NewRepository(repository.NewRepositoryOptions{
-		Dsn: dbDsn,
-	})
+// openStore opens the repository of database.driver and registers its
+// health checks and pool metrics. The returned function closes it.
+func openStore(ctx context.Context, cfg config.Config, checks *health.Registry) (repository.RepositoryInterface, func() error) {
+	switch cfg.DatabaseDriver() {
+	case repository.DriverMemory:
+		log.Warn().Msg("database.driver is memory, users are lost on exit")
+		return repository.NewMemoryRepository(), func() error { return nil }
+	case repository.DriverSQLite:
+		repo, err := repository.NewSQLiteRepository(ctx, cfg.SQLitePath())
+		if err != nil {
+			log.Fatal().Err(err).Msg("error opening database")
+		}
+		if err := metrics.RegisterDB(repo.Db, "users"); err != nil {
+			log.Fatal().Err(err).Msg("error registering database metrics")
+		}
+		checks.Register("database", health.CheckerFunc(repo.Db.PingContext))
+		return repo, repo.Close
+	}
+
+	repo := openRepository(ctx, cfg)
+	migrator, err := migrate.New(repo.Db, migrations.FS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading migrations")
+	}
+	if err := metrics.RegisterDB(repo.Db, "users"); err != nil {
+		log.Fatal().Err(err).Msg("error registering database metrics")
+	}
+	for i, db := range repo.ReplicaDBs() {
+		if err := metrics.RegisterDB(db, fmt.Sprintf("users_replica_%d", i)); err != nil {
+			log.Fatal().Err(err).Msg("error registering database metrics")
+		}
+	}
+	checks.Register("database", health.CheckerFunc(repo.Db.PingContext))
+	checks.Register("migrations", migrator)
+	return repo, repo.Close
+}
+
+// withCache wraps repo in the cache of cache.backend. The returned function
+// closes the cache.
+func withCache(ctx context.Context, cfg config.Config, repo repository.RepositoryInterface) (repository.RepositoryInterface, func() error) {
+	switch cfg.Cache.Backend {
+	case config.CacheMemory:
+		return repository.NewCachedRepository(repo, cache.NewLRU(cfg.Cache.Size), cfg.Cache.TTL), func() error { return nil }
+	case config.CacheRedis:
+		redis := cache.NewRedis(cfg.RedisOptions())
+		// Lookups fall back to the database, an unreachable cache only
+		// makes them slower and is not worth failing readiness for.
+		if err := redis.Ping(ctx); err != nil {
+			log.Warn().Err(err).Msg("cache is unreachable, reading users from the database")
+		}
+		return repository.NewCachedRepository(repo, redis, cfg.Cache.TTL), redis.Close
+	}
+	return repo, func() error { return nil }
+}
+
+// readYourWrites gives every request a repository session, so the reads
+// following a write of the request go to the primary rather than a replica
+// that may not have the write yet.
+func readYourWrites(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := ctx.Request()
+		ctx.SetRequest(req.WithContext(repository.WithSession(req.Context())))
+		return next(ctx)
+	}
+}
+
+// openRepository opens the connection pool and waits for the database to
+// accept connections.
+func openRepository(ctx context.Context, cfg config.Config) *repository.Repository {
+	repo, err := repository.NewRepository(cfg.RepositoryOptions())
+	if err != nil {
+		log.Fatal().Err(err).Msg("error opening database")
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	defer cancel()
+	if err := repo.WaitReady(ctx, databaseBackoff); err != nil {
+		repo.Close()
+		log.Fatal().Err(err).Msg("error connecting to database")
+	}
+	return repo
+}
+
+func newServer(cfg config.Config, repo repository.RepositoryInterface, signer *jwt.Signer, validator middleware.JWSValidator) *handler.Server {
 	opts := handler.NewServerOptions{
-		Repository: repo,
+		Repository:  repo,
+		TokenSigner: signer,
+		Validator:   validator,
+		BcryptCost:  cfg.Auth.BcryptCost,
+		TokenTTL:    cfg.Auth.TokenTTL,
+		Mailer:      newMailer(cfg.Email),
+		LinkSigner:  signedlink.NewSigner(linkSigningKey(cfg.Email)),
+		PublicURL:   cfg.Server.PublicURL,
+	}
+	opts.BlobStore = &blobstore.LocalStore{
+		Root:    cfg.Storage.BlobDir,
+		BaseURL: opts.PublicURL + blobsPath,
 	}
 	return handler.NewServer(opts)
 }
+
+func newMailer(cfg config.EmailConfig) mail.Sender {
+	if cfg.SMTPAddr == "" {
+		log.Warn().Msg("email.smtp_addr is not set, emails are written to the log")
+		return mail.LogSender{}
+	}
+	return &mail.SMTPSender{
+		Addr:     cfg.SMTPAddr,
+		From:     cfg.SMTPFrom,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+	}
+}
+
+func linkSigningKey(cfg config.EmailConfig) []byte {
+	if cfg.LinkSigningKey != "" {
+		return []byte(cfg.LinkSigningKey)
+	}
+	log.Warn().Msg("email.link_signing_key is not set, using a random key; links will not survive a restart")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal().Err(err).Msg("error generating link signing key")
+	}
+	return key
+}
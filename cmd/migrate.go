@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"InterviewBackendSawitProGolang/migrations"
+	"InterviewBackendSawitProGolang/pkg/config"
+	"InterviewBackendSawitProGolang/pkg/migrate"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/rs/zerolog/log"
+)
+
+const migrateUsage = `usage: main migrate <command> [flags]
+
+commands:
+  up                apply all pending migrations
+  down [-steps N]   revert the last N applied migrations (default 1)
+  status            list migrations and whether they are applied
+  create [-dir D] NAME
+                    write empty migration files to D (default "migrations")
+
+up, down and status accept the config flags of "main serve".
+`
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+
+	switch args[0] {
+	case "create":
+		dir := flags.String("dir", "migrations", "directory holding the migration files")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		up, down, err := migrate.Create(*dir, flags.Arg(0))
+		if err != nil {
+			log.Fatal().Err(err).Msg("error creating migration")
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return
+	case "up":
+		cfg := loadConfig(flags, args[1:])
+		applied, err := newMigrator(cfg).Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("error applying migrations")
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		cfg := loadConfig(flags, args[1:])
+		reverted, err := newMigrator(cfg).Down(ctx, *steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("error reverting migrations")
+		}
+	case "status":
+		cfg := loadConfig(flags, args[1:])
+		statuses, err := newMigrator(cfg).Status(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error reading migration status")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func newMigrator(cfg config.Config) *migrate.Migrator {
+	if driver := cfg.DatabaseDriver(); driver != repository.DriverPostgres {
+		log.Fatal().Str("driver", driver).Msg("migrations only apply to postgres, the other drivers create their schema on startup")
+	}
+	repo := openRepository(context.Background(), cfg)
+	m, err := migrate.New(repo.Db, migrations.FS)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error loading migrations")
+	}
+	return m
+}
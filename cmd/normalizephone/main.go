@@ -0,0 +1,83 @@
+// Command normalizephone is a one-off migration that rewrites the phone
+// numbers of existing users to E.164 so they match what the service stores
+// and looks up since numbers are normalized on input. Run "main migrate up"
+// first, E.164 numbers need the wider phone_number column. Phone numbers are
+// logged masked like in the service logs.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"InterviewBackendSawitProGolang/pkg/config"
+	"InterviewBackendSawitProGolang/pkg/logging"
+	"InterviewBackendSawitProGolang/pkg/phone"
+	"InterviewBackendSawitProGolang/repository"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report the changes without writing them")
+	loader := config.NewLoader(flag.CommandLine)
+	flag.Parse()
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalln("error loading config:", err)
+	}
+
+	ctx := context.Background()
+	repo, err := repository.NewRepository(cfg.RepositoryOptions())
+	if err != nil {
+		log.Fatalln("error opening database:", err)
+	}
+	defer repo.Close()
+
+	users, err := repo.GetUsersPhoneNumber(ctx)
+	if err != nil {
+		log.Fatalln("error listing users:", err)
+	}
+
+	var updated, invalid, failed int
+	seen := map[string]string{}
+	for _, user := range users {
+		normalized, err := phone.Normalize(user.PhoneNumber)
+		if err != nil {
+			invalid++
+			log.Printf("skip %s: %q cannot be normalized: %v", user.ID, logging.MaskPhone(user.PhoneNumber), err)
+			continue
+		}
+		if err := phone.Validate(normalized); err != nil {
+			log.Printf("warn %s: %s is not a valid mobile number: %v", user.ID, logging.MaskPhone(normalized), err)
+		}
+		if owner, ok := seen[normalized]; ok {
+			failed++
+			log.Printf("skip %s: %q normalizes to %s already used by %s", user.ID, logging.MaskPhone(user.PhoneNumber), logging.MaskPhone(normalized), owner)
+			continue
+		}
+		seen[normalized] = user.ID.String()
+		if normalized == user.PhoneNumber {
+			continue
+		}
+
+		log.Printf("update %s: %q -> %s", user.ID, logging.MaskPhone(user.PhoneNumber), logging.MaskPhone(normalized))
+		if *dryRun {
+			updated++
+			continue
+		}
+		if err := repo.UpdateUserPhoneNumber(ctx, repository.UpdateUserPhoneNumberInput{
+			ID:          user.ID,
+			PhoneNumber: normalized,
+		}); err != nil {
+			failed++
+			log.Printf("error updating %s: %v", user.ID, err)
+			continue
+		}
+		updated++
+	}
+
+	log.Printf("done: %d users, %d updated, %d invalid, %d failed (dry run: %t)", len(users), updated, invalid, failed, *dryRun)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,591 @@
+// Command userctl manages users from the command line: creating them,
+// resetting passwords, suspending them, looking them up, minting
+// short-lived tokens for testing and importing or exporting them in bulk.
+// It works on the database of the service configuration, see
+// "userctl <command> -h" for the flags.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/bulk"
+	"InterviewBackendSawitProGolang/pkg/cache"
+	"InterviewBackendSawitProGolang/pkg/config"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/phone"
+	"InterviewBackendSawitProGolang/pkg/validator"
+	"InterviewBackendSawitProGolang/repository"
+	"InterviewBackendSawitProGolang/service"
+
+	"github.com/google/uuid"
+)
+
+const usage = `usage: userctl <command> [flags]
+
+commands:
+  create -phone P -name N [-password-stdin]
+                    create a user, with a generated password unless one is
+                    read from stdin
+  reset-password (-id ID | -phone P) [-password-stdin]
+                    replace the password, with a generated one unless one is
+                    read from stdin, and revoke the issued tokens
+  suspend (-id ID | -phone P)
+                    block the logins and revoke the issued tokens of a user
+  unsuspend (-id ID | -phone P)
+                    lift a suspension, the revoked tokens stay revoked
+  get (-id ID | -phone P)
+                    show a user
+  list [-limit N]   list the most recent registrations
+  token (-id ID | -phone P) [-ttl D]
+                    mint a token for the user expiring after D (default 15m)
+  import -file F [-format csv|jsonl] [-dry-run] [-batch-size N]
+                    import the users of a file, "-" reads stdin; rows that
+                    fail are reported and skipped
+  export -file F [-format csv|jsonl]
+                    write every user, password hashes included, to a new
+                    file
+
+Every command accepts -output json|table and the config flags of
+"main serve", e.g. -database.url.
+`
+
+// maxTokenTTL bounds the lifetime of the tokens minted by userctl, they are
+// meant for testing.
+const maxTokenTTL = 24 * time.Hour
+
+var (
+	// errUsage is returned for invalid command lines.
+	errUsage = errors.New("invalid usage")
+	// errFlags is returned for flags the flag set could not parse, it
+	// already reported them.
+	errFlags = errors.New("invalid flags")
+)
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout)
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errFlags):
+		os.Exit(2)
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "userctl:", err)
+		os.Exit(1)
+	}
+}
+
+// command holds the flags shared by every command and what they open.
+type command struct {
+	flags  *flag.FlagSet
+	loader *config.Loader
+	output *string
+	id     *string
+	phone  *string
+
+	cfg     config.Config
+	repo    repository.RepositoryInterface
+	service *service.Service
+	close   func()
+}
+
+func newCommand(name string, lookup bool) *command {
+	c := &command{flags: flag.NewFlagSet("userctl "+name, flag.ContinueOnError)}
+	c.output = c.flags.String("output", formatTable, "output format, json or table")
+	if lookup {
+		c.id = c.flags.String("id", "", "ID of the user")
+		c.phone = c.flags.String("phone", "", "phone number of the user")
+	}
+	c.loader = config.NewLoader(c.flags)
+	return c
+}
+
+// parse parses args and opens the repository of the configuration.
+func (c *command) parse(ctx context.Context, args []string) error {
+	if err := c.flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errFlags
+	}
+	if c.flags.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, c.flags.Arg(0))
+	}
+	if *c.output != formatJSON && *c.output != formatTable {
+		return fmt.Errorf("%w: -output must be json or table", errUsage)
+	}
+	cfg, err := c.loader.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	validator.SetPasswordPolicy(cfg.Password)
+	c.cfg = cfg
+
+	repo, closeRepo, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	c.repo, c.close = repo, closeRepo
+	c.service = service.NewService(service.NewServiceOptions{
+		Repository: repo,
+		BcryptCost: cfg.Auth.BcryptCost,
+	})
+	return nil
+}
+
+// openStore opens the database of the configuration. Users cached in Redis
+// are dropped on change, so the service sees the changes right away.
+func openStore(ctx context.Context, cfg config.Config) (repository.RepositoryInterface, func(), error) {
+	var repo repository.RepositoryInterface
+	var closers []func() error
+	switch cfg.DatabaseDriver() {
+	case repository.DriverMemory:
+		return nil, nil, errors.New("database.driver is memory, userctl needs the database of the service")
+	case repository.DriverSQLite:
+		sqlite, err := repository.NewSQLiteRepository(ctx, cfg.SQLitePath())
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening database: %w", err)
+		}
+		repo, closers = sqlite, append(closers, sqlite.Close)
+	default:
+		postgres, err := repository.NewRepository(cfg.RepositoryOptions())
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening database: %w", err)
+		}
+		repo, closers = postgres, append(closers, postgres.Close)
+	}
+	if cfg.Cache.Backend == config.CacheRedis {
+		redis := cache.NewRedis(cfg.RedisOptions())
+		repo, closers = repository.NewCachedRepository(repo, redis, cfg.Cache.TTL), append(closers, redis.Close)
+	}
+	return repo, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}, nil
+}
+
+// userID returns the ID of the user given by -id or -phone.
+func (c *command) userID(ctx context.Context) (uuid.UUID, error) {
+	switch {
+	case *c.id != "" && *c.phone != "":
+		return uuid.Nil, fmt.Errorf("%w: -id and -phone are mutually exclusive", errUsage)
+	case *c.id != "":
+		id, err := uuid.Parse(*c.id)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%w: -id must be a UUID", errUsage)
+		}
+		return id, nil
+	case *c.phone != "":
+		user, err := c.repo.GetUserByPhoneNumber(ctx, repository.GetUserByPhoneNumberInput{
+			PhoneNumber: phone.Canonical(*c.phone),
+		})
+		if err != nil {
+			return uuid.Nil, lookupError(err)
+		}
+		return user.ID, nil
+	}
+	return uuid.Nil, fmt.Errorf("%w: -id or -phone is required", errUsage)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "create":
+		return create(ctx, args, stdin, stdout)
+	case "reset-password":
+		return resetPassword(ctx, args, stdin, stdout)
+	case "suspend":
+		return setSuspended(ctx, name, args, stdout, true)
+	case "unsuspend":
+		return setSuspended(ctx, name, args, stdout, false)
+	case "get":
+		return get(ctx, args, stdout)
+	case "list":
+		return list(ctx, args, stdout)
+	case "token":
+		return token(ctx, args, stdout)
+	case "import":
+		return importUsers(ctx, args, stdin, stdout)
+	case "export":
+		return exportUsers(ctx, args, stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, name)
+}
+
+func create(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	c := newCommand("create", false)
+	phoneNumber := c.flags.String("phone", "", "phone number of the user")
+	fullName := c.flags.String("name", "", "full name of the user")
+	passwordStdin := c.flags.Bool("password-stdin", false, "read the password from the first line of stdin")
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	if *phoneNumber == "" || *fullName == "" {
+		return fmt.Errorf("%w: -phone and -name are required", errUsage)
+	}
+
+	password, generated, err := readPassword(stdin, *passwordStdin, c.cfg)
+	if err != nil {
+		return err
+	}
+	id, err := c.service.Register(ctx, service.RegisterInput{
+		PhoneNumber: *phoneNumber,
+		FullName:    *fullName,
+		Password:    password,
+	})
+	if err != nil {
+		return serviceError(err)
+	}
+	out := createdUser{ID: id, PhoneNumber: phone.Canonical(*phoneNumber), FullName: *fullName}
+	if generated {
+		out.Password = password
+	}
+	return write(stdout, *c.output, out)
+}
+
+func resetPassword(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	c := newCommand("reset-password", true)
+	passwordStdin := c.flags.Bool("password-stdin", false, "read the password from the first line of stdin")
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	id, err := c.userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := readPassword(stdin, *passwordStdin, c.cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.service.ResetPassword(ctx, id, password); err != nil {
+		return serviceError(err)
+	}
+	out := passwordReset{ID: id}
+	if generated {
+		out.Password = password
+	}
+	return write(stdout, *c.output, out)
+}
+
+func setSuspended(ctx context.Context, name string, args []string, stdout io.Writer, suspended bool) error {
+	c := newCommand(name, true)
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	id, err := c.userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.service.SetSuspended(ctx, id, suspended); err != nil {
+		return serviceError(err)
+	}
+	info, err := c.repo.GetUserByID(ctx, repository.GetUserByIDInput{ID: id})
+	if err != nil {
+		return lookupError(err)
+	}
+	return write(stdout, *c.output, newUserDetail(id, info))
+}
+
+func get(ctx context.Context, args []string, stdout io.Writer) error {
+	c := newCommand("get", true)
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	id, err := c.userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.repo.GetUserByID(ctx, repository.GetUserByIDInput{ID: id})
+	if err != nil {
+		return lookupError(err)
+	}
+	return write(stdout, *c.output, newUserDetail(id, info))
+}
+
+func list(ctx context.Context, args []string, stdout io.Writer) error {
+	c := newCommand("list", false)
+	limit := c.flags.Int("limit", 20, "number of users to list")
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	if *limit < 1 {
+		return fmt.Errorf("%w: -limit must be positive", errUsage)
+	}
+
+	users, err := c.repo.GetRecentUsers(ctx, repository.GetRecentUsersInput{Limit: *limit})
+	if err != nil {
+		return fmt.Errorf("listing users: %w", err)
+	}
+	out := make(userList, 0, len(users))
+	for _, user := range users {
+		out = append(out, newUserSummary(user))
+	}
+	return write(stdout, *c.output, out)
+}
+
+func token(ctx context.Context, args []string, stdout io.Writer) error {
+	c := newCommand("token", true)
+	ttl := c.flags.Duration("ttl", 15*time.Minute, fmt.Sprintf("lifetime of the token, at most %s", maxTokenTTL))
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	if *ttl <= 0 || *ttl > maxTokenTTL {
+		return fmt.Errorf("%w: -ttl must be positive and at most %s", errUsage, maxTokenTTL)
+	}
+	id, err := c.userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.repo.GetUserByID(ctx, repository.GetUserByIDInput{ID: id})
+	if err != nil {
+		return lookupError(err)
+	}
+	if info.SuspendedAt != nil {
+		return errors.New("user is suspended")
+	}
+	signer, err := jwt.NewSigner(c.cfg.SignerOptions())
+	if err != nil {
+		return fmt.Errorf("creating signer: %w", err)
+	}
+	expiresAt := time.Now().Add(*ttl)
+	signed, err := signer.CreateExpiringJWS(ctx, map[string]interface{}{"id": id.String()}, *ttl)
+	if err != nil {
+		return fmt.Errorf("creating token: %w", err)
+	}
+	return write(stdout, *c.output, mintedToken{ID: id, Token: string(signed), ExpiresAt: expiresAt.UTC().Truncate(time.Second)})
+}
+
+func importUsers(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	c := newCommand("import", false)
+	path := c.flags.String("file", "", `file to import, "-" for stdin`)
+	format := c.flags.String("format", "", "csv or jsonl, by default from the file extension")
+	dryRun := c.flags.Bool("dry-run", false, "check the file and report the errors without importing")
+	batchSize := c.flags.Int("batch-size", bulk.DefaultBatchSize, "number of users inserted at once")
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	if *path == "" {
+		return fmt.Errorf("%w: -file is required", errUsage)
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("%w: -batch-size must be positive", errUsage)
+	}
+	if *format == "" {
+		if *format = fileFormat(*path); *format == "" {
+			return fmt.Errorf("%w: -format is required for %q", errUsage, *path)
+		}
+	}
+
+	in := stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	result, importErr := bulk.Import(ctx, in, *format, bulk.ImportOptions{
+		Repository: c.repo,
+		BcryptCost: c.cfg.Auth.BcryptCost,
+		BatchSize:  *batchSize,
+		DryRun:     *dryRun,
+	})
+	report := newImportReport(result, *dryRun)
+	if err := write(stdout, *c.output, report); err != nil {
+		return err
+	}
+	if *c.output == formatTable && len(report.Errors) > 0 {
+		fmt.Fprintln(stdout)
+		if err := write(stdout, formatTable, report.Errors); err != nil {
+			return err
+		}
+	}
+	if importErr != nil {
+		return importErr
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows were not imported", report.Failed, report.Rows)
+	}
+	return nil
+}
+
+func exportUsers(ctx context.Context, args []string, stdout io.Writer) error {
+	c := newCommand("export", false)
+	path := c.flags.String("file", "", "file to create")
+	format := c.flags.String("format", "", "csv or jsonl, by default from the file extension")
+	if err := c.parse(ctx, args); err != nil {
+		return err
+	}
+	defer c.close()
+	if *path == "" {
+		return fmt.Errorf("%w: -file is required", errUsage)
+	}
+	if *format == "" {
+		if *format = fileFormat(*path); *format == "" {
+			return fmt.Errorf("%w: -format is required for %q", errUsage, *path)
+		}
+	}
+
+	// The file holds password hashes, so only the owner may read it and an
+	// existing file is never replaced.
+	f, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	n, err := bulk.Export(ctx, w, *format, bulk.ExportOptions{Repository: c.repo})
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("exporting users: %w", err)
+	}
+	return write(stdout, *c.output, exportReport{File: *path, Users: n})
+}
+
+// fileFormat returns the bulk format of path from its extension, "" when
+// it has none of theirs.
+func fileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return bulk.FormatCSV
+	case ".jsonl", ".ndjson":
+		return bulk.FormatJSONL
+	}
+	return ""
+}
+
+// readPassword returns the first line of stdin when fromStdin is set and a
+// generated password otherwise.
+func readPassword(stdin io.Reader, fromStdin bool, cfg config.Config) (password string, generated bool, err error) {
+	if !fromStdin {
+		password, err = generatePassword(cfg)
+		return password, true, err
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, fmt.Errorf("reading password: %w", err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", false, errors.New("reading password: stdin is empty")
+	}
+	return password, false, nil
+}
+
+// Character classes of generated passwords.
+const (
+	upperChars   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	lowerChars   = "abcdefghijkmnopqrstuvwxyz"
+	digitChars   = "23456789"
+	specialChars = "!#$%&*+-=?@_"
+)
+
+// generatePassword returns a random password of at least 16 characters
+// with every character class and no character twice in a row, so it meets
+// any password policy the length allows. It fails when password.max_length
+// is too short to hold every class.
+func generatePassword(cfg config.Config) (string, error) {
+	length := 16
+	if cfg.Password.MinLength > length {
+		length = cfg.Password.MinLength
+	}
+	if cfg.Password.MaxLength > 0 && cfg.Password.MaxLength < length {
+		length = cfg.Password.MaxLength
+	}
+	classes := []string{upperChars, lowerChars, digitChars, specialChars}
+	if length < len(classes) {
+		return "", fmt.Errorf("password.max_length %d is too short to generate a password, use -password-stdin", length)
+	}
+	all := strings.Join(classes, "")
+	password := make([]byte, length)
+	for {
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(all))))
+			if err != nil {
+				return "", fmt.Errorf("generating password: %w", err)
+			}
+			password[i] = all[n.Int64()]
+		}
+		if hasClasses(password, classes) && !hasRepeats(password) {
+			return string(password), nil
+		}
+	}
+}
+
+func hasClasses(password []byte, classes []string) bool {
+	for _, class := range classes {
+		if !strings.ContainsAny(string(password), class) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRepeats(password []byte) bool {
+	for i := 1; i < len(password); i++ {
+		if password[i] == password[i-1] {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return errors.New("user not found")
+	}
+	return fmt.Errorf("looking up user: %w", err)
+}
+
+func serviceError(err error) error {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return invalid
+	case errors.Is(err, repository.ErrPhoneNumberTaken):
+		return errors.New("phone number already exists")
+	case errors.Is(err, repository.ErrNotFound):
+		return errors.New("user not found")
+	}
+	return err
+}
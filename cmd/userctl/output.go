@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/bulk"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+)
+
+// Output formats of -output.
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+)
+
+// tabular is a command output that can be printed as a table.
+type tabular interface {
+	table() (header []string, rows [][]string)
+}
+
+// write prints out as indented JSON or as a table.
+func write(w io.Writer, format string, out tabular) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	header, rows := out.table()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+type createdUser struct {
+	ID          uuid.UUID `json:"id"`
+	PhoneNumber string    `json:"phoneNumber"`
+	FullName    string    `json:"fullName"`
+	// Password is only set when it was generated.
+	Password string `json:"password,omitempty"`
+}
+
+func (u createdUser) table() ([]string, [][]string) {
+	header := []string{"ID", "PHONE NUMBER", "FULL NAME"}
+	row := []string{u.ID.String(), u.PhoneNumber, u.FullName}
+	if u.Password != "" {
+		header, row = append(header, "PASSWORD"), append(row, u.Password)
+	}
+	return header, [][]string{row}
+}
+
+type passwordReset struct {
+	ID uuid.UUID `json:"id"`
+	// Password is only set when it was generated.
+	Password string `json:"password,omitempty"`
+}
+
+func (p passwordReset) table() ([]string, [][]string) {
+	header := []string{"ID"}
+	row := []string{p.ID.String()}
+	if p.Password != "" {
+		header, row = append(header, "PASSWORD"), append(row, p.Password)
+	}
+	return header, [][]string{row}
+}
+
+type userDetail struct {
+	ID            uuid.UUID  `json:"id"`
+	PhoneNumber   string     `json:"phoneNumber"`
+	FullName      string     `json:"fullName"`
+	Email         *string    `json:"email,omitempty"`
+	EmailVerified bool       `json:"emailVerified"`
+	SuspendedAt   *time.Time `json:"suspendedAt,omitempty"`
+}
+
+func newUserDetail(id uuid.UUID, info repository.UserInfo) userDetail {
+	return userDetail{
+		ID:            id,
+		PhoneNumber:   info.PhoneNumber,
+		FullName:      info.FullName,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerifiedAt != nil,
+		SuspendedAt:   utc(info.SuspendedAt),
+	}
+}
+
+func (u userDetail) table() ([]string, [][]string) {
+	return []string{"ID", "PHONE NUMBER", "FULL NAME", "EMAIL", "EMAIL VERIFIED", "SUSPENDED AT"},
+		[][]string{{u.ID.String(), u.PhoneNumber, u.FullName, optional(u.Email), fmt.Sprint(u.EmailVerified), timestamp(u.SuspendedAt)}}
+}
+
+type userSummary struct {
+	ID          uuid.UUID  `json:"id"`
+	PhoneNumber string     `json:"phoneNumber"`
+	FullName    string     `json:"fullName"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
+}
+
+func newUserSummary(user repository.RecentUser) userSummary {
+	return userSummary{
+		ID:          user.ID,
+		PhoneNumber: user.PhoneNumber,
+		FullName:    user.FullName,
+		Email:       user.Email,
+		CreatedAt:   user.CreatedAt.UTC(),
+		SuspendedAt: utc(user.SuspendedAt),
+	}
+}
+
+type userList []userSummary
+
+func (l userList) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(l))
+	for _, u := range l {
+		rows = append(rows, []string{u.ID.String(), u.PhoneNumber, u.FullName, optional(u.Email), timestamp(&u.CreatedAt), timestamp(u.SuspendedAt)})
+	}
+	return []string{"ID", "PHONE NUMBER", "FULL NAME", "EMAIL", "CREATED AT", "SUSPENDED AT"}, rows
+}
+
+type mintedToken struct {
+	ID        uuid.UUID `json:"id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (t mintedToken) table() ([]string, [][]string) {
+	return []string{"ID", "EXPIRES AT", "TOKEN"},
+		[][]string{{t.ID.String(), timestamp(&t.ExpiresAt), t.Token}}
+}
+
+type importReport struct {
+	Rows     int          `json:"rows"`
+	Imported int          `json:"imported"`
+	Failed   int          `json:"failed"`
+	DryRun   bool         `json:"dryRun"`
+	Errors   rowErrorList `json:"errors"`
+}
+
+func newImportReport(result bulk.ImportResult, dryRun bool) importReport {
+	report := importReport{
+		Rows:     result.Rows,
+		Imported: result.Imported,
+		Failed:   len(result.Errors),
+		DryRun:   dryRun,
+		Errors:   rowErrorList{},
+	}
+	for _, rowErr := range result.Errors {
+		report.Errors = append(report.Errors, rowError{Line: rowErr.Line, Fields: rowErr.Fields})
+	}
+	return report
+}
+
+func (r importReport) table() ([]string, [][]string) {
+	return []string{"ROWS", "IMPORTED", "FAILED", "DRY RUN"},
+		[][]string{{fmt.Sprint(r.Rows), fmt.Sprint(r.Imported), fmt.Sprint(r.Failed), fmt.Sprint(r.DryRun)}}
+}
+
+type rowError struct {
+	Line   int                 `json:"line"`
+	Fields map[string][]string `json:"fields"`
+}
+
+type rowErrorList []rowError
+
+func (l rowErrorList) table() ([]string, [][]string) {
+	var rows [][]string
+	for _, e := range l {
+		fields := make([]string, 0, len(e.Fields))
+		for field := range e.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			name := field
+			if name == "" {
+				name = "-"
+			}
+			for _, message := range e.Fields[field] {
+				rows = append(rows, []string{fmt.Sprint(e.Line), name, message})
+			}
+		}
+	}
+	return []string{"LINE", "FIELD", "ERROR"}, rows
+}
+
+type exportReport struct {
+	File  string `json:"file"`
+	Users int    `json:"users"`
+}
+
+func (r exportReport) table() ([]string, [][]string) {
+	return []string{"FILE", "USERS"}, [][]string{{r.File, fmt.Sprint(r.Users)}}
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return *s
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,134 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/logging"
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/pkg/tracing"
+	userv1 "InterviewBackendSawitProGolang/proto/user/v1"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// InternalTokenMetadata carries the token of GetUser.
+const InternalTokenMetadata = "x-internal-token"
+
+type userIDKey struct{}
+
+// userID returns the ID of the user authenticated by authenticate.
+func userID(ctx context.Context) uuid.UUID {
+	id, _ := ctx.Value(userIDKey{}).(uuid.UUID)
+	return id
+}
+
+// authenticate checks the bearer token of the methods acting on a user, and
+// its user with check, and the internal token of GetUser, the other methods
+// are public.
+func authenticate(v middleware.JWSValidator, check middleware.UserCheck, internalToken string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		switch info.FullMethod {
+		case userv1.UserService_GetProfile_FullMethodName, userv1.UserService_UpdateProfile_FullMethodName:
+			id, err := middleware.AuthenticateBearer(ctx, v, check, firstMetadata(ctx, "authorization"))
+			if errors.Is(err, middleware.ErrUserCheckFailed) {
+				return nil, statusError(ctx, err)
+			}
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			userUUID, err := uuid.Parse(id)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "token has no user")
+			}
+			ctx = context.WithValue(logging.WithUserID(ctx, id), userIDKey{}, userUUID)
+		case userv1.UserService_GetUser_FullMethodName:
+			token := firstMetadata(ctx, InternalTokenMetadata)
+			if internalToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(internalToken)) != 1 {
+				return nil, status.Error(codes.PermissionDenied, InternalTokenMetadata+" is missing or invalid")
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// logRequests traces and logs every call like the REST middleware does,
+// continuing the trace of the traceparent metadata and taking the request
+// ID from the x-request-id metadata.
+func logRequests(logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		ctx, span := tracing.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer))
+		requestID := firstMetadata(ctx, "x-request-id")
+		if _, err := uuid.Parse(requestID); err != nil {
+			requestID = uuid.NewString()
+		}
+		fields := logger.With().
+			Str("request_id", requestID).
+			Str("operation", info.FullMethod)
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			fields = fields.Str("trace_id", traceID)
+		}
+		ctx = fields.Logger().WithContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+		metrics.GRPCRequests.WithLabelValues(info.FullMethod, code.String()).Inc()
+		var spanErr error
+		entry := zerolog.Ctx(ctx).Info()
+		switch code {
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			spanErr = err
+			entry = zerolog.Ctx(ctx).Error()
+		}
+		tracing.End(span, spanErr)
+		entry.
+			Str("code", code.String()).
+			Dur("latency", time.Since(start)).
+			Msg("request")
+		return resp, err
+	}
+}
+
+// metadataCarrier lets the propagators read and write gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+// Package grpcapi serves the user API over gRPC, see proto/user/v1. It
+// shares the domain logic of the REST handlers through package service and
+// authenticates users with the same bearer tokens.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/avatar"
+	"InterviewBackendSawitProGolang/pkg/blobstore"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	userv1 "InterviewBackendSawitProGolang/proto/user/v1"
+	"InterviewBackendSawitProGolang/repository"
+	"InterviewBackendSawitProGolang/service"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type Options struct {
+	Service *service.Service
+	// Validator checks the bearer tokens of GetProfile and UpdateProfile,
+	// whose users must also be active, see service.TokenActive.
+	Validator middleware.JWSValidator
+	// InternalToken authenticates GetUser, which is refused when it is
+	// empty.
+	InternalToken string
+	BlobStore     blobstore.BlobStore
+	Logger        zerolog.Logger
+}
+
+type Server struct {
+	userv1.UnimplementedUserServiceServer
+	Service   *service.Service
+	BlobStore blobstore.BlobStore
+}
+
+// NewServer returns a gRPC server with the UserService and the standard
+// health service registered.
+func NewServer(opts Options) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		logRequests(opts.Logger),
+		authenticate(opts.Validator, opts.Service.TokenActive, opts.InternalToken),
+	))
+	userv1.RegisterUserServiceServer(s, &Server{Service: opts.Service, BlobStore: opts.BlobStore})
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	return s
+}
+
+func (s *Server) Register(ctx context.Context, req *userv1.RegisterRequest) (*userv1.RegisterResponse, error) {
+	id, err := s.Service.Register(ctx, service.RegisterInput{
+		PhoneNumber: req.PhoneNumber,
+		FullName:    req.FullName,
+		Password:    req.Password,
+	})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &userv1.RegisterResponse{Id: id.String()}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	input := service.LoginInput{Password: req.Password}
+	switch identifier := req.Identifier.(type) {
+	case *userv1.LoginRequest_PhoneNumber:
+		input.PhoneNumber = &identifier.PhoneNumber
+	case *userv1.LoginRequest_Email:
+		input.Email = &identifier.Email
+	}
+	output, err := s.Service.Login(ctx, input)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &userv1.LoginResponse{Id: output.ID.String(), Token: output.Token}, nil
+}
+
+func (s *Server) GetProfile(ctx context.Context, req *userv1.GetProfileRequest) (*userv1.Profile, error) {
+	return s.profile(ctx, userID(ctx))
+}
+
+func (s *Server) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.Profile, error) {
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "id must be a UUID")
+	}
+	return s.profile(ctx, id)
+}
+
+func (s *Server) UpdateProfile(ctx context.Context, req *userv1.UpdateProfileRequest) (*userv1.UpdateProfileResponse, error) {
+	id := userID(ctx)
+	input := repository.UpdateUserInput{
+		ID:          id,
+		PhoneNumber: req.PhoneNumber,
+		FullName:    req.FullName,
+		Profile: repository.Profile{
+			Email:  req.Email,
+			Gender: req.Gender,
+		},
+	}
+	if req.DateOfBirth != nil {
+		dateOfBirth, err := time.Parse("2006-01-02", *req.DateOfBirth)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "date_of_birth must be YYYY-MM-DD")
+		}
+		input.DateOfBirth = &dateOfBirth
+	}
+	if req.Address != nil {
+		input.Address = &repository.Address{
+			Street:     req.Address.Street,
+			City:       req.Address.City,
+			Province:   req.Address.Province,
+			PostalCode: req.Address.PostalCode,
+			Country:    req.Address.Country,
+		}
+	}
+	if err := s.Service.UpdateProfile(ctx, input); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &userv1.UpdateProfileResponse{Id: id.String()}, nil
+}
+
+func (s *Server) profile(ctx context.Context, id uuid.UUID) (*userv1.Profile, error) {
+	user, err := s.Service.GetProfile(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	resp := &userv1.Profile{
+		Id:            id.String(),
+		PhoneNumber:   user.PhoneNumber,
+		FullName:      user.FullName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Gender:        user.Gender,
+	}
+	if user.DateOfBirth != nil {
+		dateOfBirth := user.DateOfBirth.Format("2006-01-02")
+		resp.DateOfBirth = &dateOfBirth
+	}
+	if user.Address != nil {
+		resp.Address = &userv1.Address{
+			Street:     user.Address.Street,
+			City:       user.Address.City,
+			Province:   user.Address.Province,
+			PostalCode: user.Address.PostalCode,
+			Country:    user.Address.Country,
+		}
+	}
+	if user.AvatarKey != nil && s.BlobStore != nil {
+		resp.Avatar = &userv1.Avatar{
+			Url:        s.BlobStore.URL(avatar.OriginalKey(*user.AvatarKey)),
+			Thumbnails: map[string]string{},
+		}
+		for _, size := range avatar.ThumbnailSizes {
+			resp.Avatar.Thumbnails[strconv.Itoa(size)] = s.BlobStore.URL(avatar.ThumbnailKey(*user.AvatarKey, size))
+		}
+	}
+	return resp, nil
+}
+
+// statusError turns an error of the service into the status with the code
+// of the matching REST response, logging the unexpected ones.
+func statusError(ctx context.Context, err error) error {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		st := status.New(codes.InvalidArgument, invalid.Error())
+		details := &errdetails.BadRequest{}
+		for field, messages := range invalid.Fields {
+			for _, message := range messages {
+				details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+					Field:       field,
+					Description: message,
+				})
+			}
+		}
+		if withDetails, err := st.WithDetails(details); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	case errors.Is(err, service.ErrIdentifierRequired):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrWrongCredentials):
+		return status.Error(codes.Unauthenticated, "phone number, email or password is wrong")
+	case errors.Is(err, service.ErrSuspended):
+		return status.Error(codes.PermissionDenied, "account is suspended")
+	case errors.Is(err, repository.ErrPhoneNumberTaken):
+		return status.Error(codes.AlreadyExists, "phone number already exists")
+	case errors.Is(err, repository.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, "email already exists")
+	case errors.Is(err, repository.ErrNotFound):
+		return status.Error(codes.NotFound, "profile not found")
+	case errors.Is(err, repository.ErrUnavailable):
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Database unavailable")
+		return status.Error(codes.Unavailable, "service unavailable")
+	}
+	zerolog.Ctx(ctx).Error().Err(err).Msg("Request failed")
+	return status.Error(codes.Internal, "internal server error")
+}
@@ -0,0 +1,294 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/pkg/tracing"
+	userv1 "InterviewBackendSawitProGolang/proto/user/v1"
+	"InterviewBackendSawitProGolang/repository"
+	"InterviewBackendSawitProGolang/service"
+
+	"github.com/google/uuid"
+	jwxjwt "github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const internalToken = "internal-token"
+
+func newClient(t *testing.T) userv1.UserServiceClient {
+	client, _ := newClientAndService(t)
+	return client
+}
+
+func newClientAndService(t *testing.T) (userv1.UserServiceClient, *service.Service) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	validator, err := middleware.NewJWSValidator(middleware.Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+
+	svc := service.NewService(service.NewServiceOptions{
+		Repository:  repository.NewMemoryRepository(),
+		TokenSigner: signer,
+		BcryptCost:  bcrypt.MinCost,
+	})
+	s := NewServer(Options{
+		Service:       svc,
+		Validator:     validator,
+		InternalToken: internalToken,
+		Logger:        zerolog.Nop(),
+	})
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return userv1.NewUserServiceClient(conn), svc
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestRegisterLoginAndUpdateProfile(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	registered, err := client.Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "081234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+
+	login, err := client.Login(ctx, &userv1.LoginRequest{
+		Identifier: &userv1.LoginRequest_PhoneNumber{PhoneNumber: "+6281234567890"},
+		Password:   "Passw0rd!",
+	})
+	require.NoError(t, err)
+	require.Equal(t, registered.Id, login.Id)
+
+	profile, err := client.GetProfile(withToken(login.Token), &userv1.GetProfileRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "+6281234567890", profile.PhoneNumber)
+	require.Equal(t, "Test User", profile.FullName)
+	require.Nil(t, profile.Email)
+
+	email, dateOfBirth, gender := "test@example.com", "1990-05-17", "other"
+	_, err = client.UpdateProfile(withToken(login.Token), &userv1.UpdateProfileRequest{
+		PhoneNumber: "+6281234567890",
+		FullName:    "Renamed User",
+		Email:       &email,
+		DateOfBirth: &dateOfBirth,
+		Gender:      &gender,
+		Address:     &userv1.Address{Street: "Jl. Sudirman 1", City: "Jakarta", Country: "ID"},
+	})
+	require.NoError(t, err)
+
+	profile, err = client.GetUser(
+		metadata.AppendToOutgoingContext(ctx, InternalTokenMetadata, internalToken),
+		&userv1.GetUserRequest{Id: registered.Id})
+	require.NoError(t, err)
+	require.Equal(t, "Renamed User", profile.FullName)
+	require.Equal(t, email, profile.GetEmail())
+	require.False(t, profile.EmailVerified)
+	require.Equal(t, dateOfBirth, profile.GetDateOfBirth())
+	require.Equal(t, gender, profile.GetGender())
+	require.Equal(t, "Jakarta", profile.Address.City)
+}
+
+func TestLoginWithWrongPassword(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	_, err := client.Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "+6281234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+
+	_, err = client.Login(ctx, &userv1.LoginRequest{
+		Identifier: &userv1.LoginRequest_PhoneNumber{PhoneNumber: "+6281234567890"},
+		Password:   "Wrong0rd!",
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Login(ctx, &userv1.LoginRequest{Password: "Passw0rd!"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestContinuesTraceOfTraceparent(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	traceID := "4bf92f3577b34da6a3ce929d0eaf5c58"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	_, err = newClient(t).Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "+6281234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+
+	var found bool
+	for _, span := range recorder.Ended() {
+		if span.Name() == userv1.UserService_Register_FullMethodName {
+			found = true
+			require.Equal(t, traceID, span.SpanContext().TraceID().String())
+			require.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+		}
+	}
+	require.True(t, found)
+}
+
+func TestRegisterTakenPhoneNumber(t *testing.T) {
+	client := newClient(t)
+	req := &userv1.RegisterRequest{PhoneNumber: "+6281234567890", FullName: "Test User", Password: "Passw0rd!"}
+	_, err := client.Register(context.Background(), req)
+	require.NoError(t, err)
+
+	_, err = client.Register(context.Background(), req)
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestRegisterInvalidInputHasFieldViolations(t *testing.T) {
+	client := newClient(t)
+	_, err := client.Register(context.Background(), &userv1.RegisterRequest{
+		PhoneNumber: "12345", FullName: "Test User", Password: "Passw0rd!",
+	})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.NotEmpty(t, badRequest.FieldViolations)
+}
+
+func TestUpdateProfileInvalidDateOfBirth(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	_, err := client.Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "+6281234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+	login, err := client.Login(ctx, &userv1.LoginRequest{
+		Identifier: &userv1.LoginRequest_PhoneNumber{PhoneNumber: "+6281234567890"},
+		Password:   "Passw0rd!",
+	})
+	require.NoError(t, err)
+
+	dateOfBirth := "17/05/1990"
+	_, err = client.UpdateProfile(withToken(login.Token), &userv1.UpdateProfileRequest{
+		PhoneNumber: "+6281234567890", FullName: "Test User", DateOfBirth: &dateOfBirth,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetProfileRequiresToken(t *testing.T) {
+	client := newClient(t)
+
+	_, err := client.GetProfile(context.Background(), &userv1.GetProfileRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetProfile(withToken("not-a-token"), &userv1.GetProfileRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestSuspendedUserTokenIsRejected(t *testing.T) {
+	client, svc := newClientAndService(t)
+	ctx := context.Background()
+
+	registered, err := client.Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "081234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+	login, err := client.Login(ctx, &userv1.LoginRequest{
+		Identifier: &userv1.LoginRequest_PhoneNumber{PhoneNumber: "+6281234567890"},
+		Password:   "Passw0rd!",
+	})
+	require.NoError(t, err)
+	token, err := jwxjwt.ParseString(login.Token)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(jwt.DefaultTokenTTL), token.Expiration(), time.Minute)
+
+	_, err = client.GetProfile(withToken(login.Token), &userv1.GetProfileRequest{})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.SetSuspended(ctx, uuid.MustParse(registered.Id), true))
+	_, err = client.GetProfile(withToken(login.Token), &userv1.GetProfileRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestResetPasswordRevokesTokens(t *testing.T) {
+	client, svc := newClientAndService(t)
+	ctx := context.Background()
+	login := func(password string) string {
+		res, err := client.Login(ctx, &userv1.LoginRequest{
+			Identifier: &userv1.LoginRequest_PhoneNumber{PhoneNumber: "+6281234567890"},
+			Password:   password,
+		})
+		require.NoError(t, err)
+		return res.Token
+	}
+
+	registered, err := client.Register(ctx, &userv1.RegisterRequest{
+		PhoneNumber: "081234567890", FullName: "Test User", Password: "Passw0rd!",
+	})
+	require.NoError(t, err)
+	old := login("Passw0rd!")
+
+	require.NoError(t, svc.ResetPassword(ctx, uuid.MustParse(registered.Id), "N3wPassw0rd!"))
+	_, err = client.GetProfile(withToken(old), &userv1.GetProfileRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// iat has second precision, tokens of the second of the reset are
+	// revoked too.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	_, err = client.GetProfile(withToken(login("N3wPassw0rd!")), &userv1.GetProfileRequest{})
+	require.NoError(t, err)
+}
+
+func TestGetUserRequiresInternalToken(t *testing.T) {
+	client := newClient(t)
+	req := &userv1.GetUserRequest{Id: uuid.NewString()}
+
+	_, err := client.GetUser(context.Background(), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.GetUser(metadata.AppendToOutgoingContext(context.Background(), InternalTokenMetadata, "wrong"), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), InternalTokenMetadata, internalToken)
+	_, err = client.GetUser(ctx, req)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetUser(ctx, &userv1.GetUserRequest{Id: "42"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
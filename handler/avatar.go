@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/avatar"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+func (s *Server) UploadAvatar(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.UploadAvatar").End()
+	userUUID, err := s.convertUserIDtoUUID(ctx)
+	if err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to convert uuid")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+
+	fileHeader, err := ctx.FormFile("avatar")
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "avatar file is required",
+		})
+	}
+	if fileHeader.Size > avatar.MaxBytes {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, generated.ErrorResponse{
+			Message: avatar.ErrTooLarge.Error(),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to open uploaded avatar")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, avatar.MaxBytes+1))
+	if err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to read uploaded avatar")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+
+	images, err := avatar.Process(data)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, avatar.ErrTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		return ctx.JSON(status, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// Every upload gets a new key so caches never serve a stale picture.
+	key := fmt.Sprintf("avatars/%s/%s", userUUID, uuid.New())
+	if err := s.putAvatar(ctx, key, images); err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Failed to store avatar")
+		s.deleteAvatar(ctx, key)
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+
+	output, err := s.Repository.UpdateUserAvatar(ctx.Request().Context(), repository.UpdateUserAvatarInput{
+		ID:        userUUID,
+		AvatarKey: key,
+	})
+	if err != nil {
+		s.deleteAvatar(ctx, key)
+		return repositoryError(ctx, err, "Failed to update avatar")
+	}
+	if output.PreviousAvatarKey != nil {
+		s.deleteAvatar(ctx, *output.PreviousAvatarKey)
+	}
+
+	return ctx.JSON(http.StatusOK, s.avatarResponse(key))
+}
+
+func (s *Server) putAvatar(ctx echo.Context, key string, images avatar.Result) error {
+	if err := s.BlobStore.Put(ctx.Request().Context(), avatar.OriginalKey(key), bytes.NewReader(images.Original), avatar.ContentType); err != nil {
+		return err
+	}
+	for size, thumbnail := range images.Thumbnails {
+		if err := s.BlobStore.Put(ctx.Request().Context(), avatar.ThumbnailKey(key, size), bytes.NewReader(thumbnail), avatar.ContentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAvatar removes every image stored under key. Failures only leave
+// orphaned files behind, so they are logged and otherwise ignored.
+func (s *Server) deleteAvatar(ctx echo.Context, key string) {
+	keys := []string{avatar.OriginalKey(key)}
+	for _, size := range avatar.ThumbnailSizes {
+		keys = append(keys, avatar.ThumbnailKey(key, size))
+	}
+	for _, k := range keys {
+		if err := s.BlobStore.Delete(ctx.Request().Context(), k); err != nil {
+			log.Ctx(ctx.Request().Context()).Error().Err(err).Str("key", k).Msg("Failed to delete avatar")
+		}
+	}
+}
+
+func (s *Server) avatarResponse(key string) generated.Avatar {
+	resp := generated.Avatar{
+		Url:        s.BlobStore.URL(avatar.OriginalKey(key)),
+		Thumbnails: map[string]string{},
+	}
+	for _, size := range avatar.ThumbnailSizes {
+		resp.Thumbnails[strconv.Itoa(size)] = s.BlobStore.URL(avatar.ThumbnailKey(key, size))
+	}
+	return resp
+}
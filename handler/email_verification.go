@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/mail"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// emailVerificationTTL is how long a verification link stays valid.
+const emailVerificationTTL = 24 * time.Hour
+
+func (s *Server) SendEmailVerification(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.SendEmailVerification").End()
+	userUUID, err := s.convertUserIDtoUUID(ctx)
+	if err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to convert uuid")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+
+	user, err := s.Repository.GetUserByID(ctx.Request().Context(), repository.GetUserByIDInput{
+		ID: userUUID,
+	})
+	if err != nil {
+		return repositoryError(ctx, err, "Failed get profile")
+	}
+
+	if user.Email == nil {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "profile has no email",
+		})
+	}
+	if user.EmailVerifiedAt != nil {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "email is already verified",
+		})
+	}
+
+	token := s.LinkSigner.Sign(url.Values{
+		"id":    {userUUID.String()},
+		"email": {*user.Email},
+	}, time.Now().Add(emailVerificationTTL))
+	link := fmt.Sprintf("%s/users/email/verify?token=%s", s.PublicURL, url.QueryEscape(token))
+
+	if err := s.Mailer.Send(ctx.Request().Context(), mail.Message{
+		To:      *user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Hi %s,\n\nPlease verify your email by opening the link below within 24 hours:\n\n%s\n", user.FullName, link),
+	}); err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Failed to send verification email")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.MessageResponse{
+		Message: "verification link sent",
+	})
+}
+
+func (s *Server) VerifyEmail(ctx echo.Context, params generated.VerifyEmailParams) error {
+	defer startSpan(ctx, "Server.VerifyEmail").End()
+	invalid := generated.ErrorResponse{
+		Message: "verification link is invalid or has expired",
+	}
+
+	values, err := s.LinkSigner.Verify(params.Token, time.Now())
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, invalid)
+	}
+	userUUID, err := uuid.Parse(values.Get("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, invalid)
+	}
+
+	output, err := s.Repository.VerifyEmail(ctx.Request().Context(), repository.VerifyEmailInput{
+		ID:         userUUID,
+		Email:      values.Get("email"),
+		VerifiedAt: time.Now(),
+	})
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to verify email")
+	}
+	if !output.Verified {
+		return ctx.JSON(http.StatusBadRequest, invalid)
+	}
+
+	return ctx.JSON(http.StatusOK, generated.MessageResponse{
+		Message: "email verified",
+	})
+}
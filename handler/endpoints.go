@@ -4,186 +4,153 @@ import (
 	"net/http"
 
 	"InterviewBackendSawitProGolang/generated"
-	"InterviewBackendSawitProGolang/pkg/jwt"
-	"InterviewBackendSawitProGolang/pkg/validator"
 	"InterviewBackendSawitProGolang/repository"
-	"database/sql"
-	"encoding/json"
-	"fmt"
+	"InterviewBackendSawitProGolang/service"
+	goerrors "errors"
+	openapi_types "github.com/deepmap/oapi-codegen/pkg/types"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
-	"github.com/thanhpk/randstr"
-	"golang.org/x/crypto/bcrypt"
-	"time"
 )
 
 var err error
 
 func (s *Server) Hello(ctx echo.Context) error {
-	fmt.Println(ctx.Request().Context().Value("user_id"))
+	defer startSpan(ctx, "Server.Hello").End()
 	return ctx.JSON(http.StatusOK, "hello world!")
 }
 func (s *Server) GetProfile(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.GetProfile").End()
 	var resp generated.GetProfileResponse
 	userUUID, err := s.convertUserIDtoUUID(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("Unable to convert uuid")
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to convert uuid")
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: "internal server error",
 		})
 	}
 
-	var input repository.GetUserByIDInput = repository.GetUserByIDInput{
-		ID: userUUID,
+	output, err := s.Service.GetProfile(ctx.Request().Context(), userUUID)
+	if goerrors.Is(err, repository.ErrNotFound) {
+		return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+			Message: "profile not found",
+		})
 	}
-
-	output, err := s.Repository.GetUserByID(ctx.Request().Context(), input)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed get profile")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
+		return repositoryError(ctx, err, "Failed get profile")
 	}
 
 	resp.FullName = &output.FullName
 	resp.PhoneNumber = &output.PhoneNumber
+	resp.Email = (*openapi_types.Email)(output.Email)
+	if output.Email != nil {
+		emailVerified := output.EmailVerifiedAt != nil
+		resp.EmailVerified = &emailVerified
+	}
+	resp.Gender = (*generated.Gender)(output.Gender)
+	if output.DateOfBirth != nil {
+		resp.DateOfBirth = &openapi_types.Date{Time: *output.DateOfBirth}
+	}
+	if output.AvatarKey != nil {
+		avatar := s.avatarResponse(*output.AvatarKey)
+		resp.Avatar = &avatar
+	}
+	if output.Address != nil {
+		resp.Address = &generated.Address{
+			Street:     output.Address.Street,
+			City:       output.Address.City,
+			Province:   optionalString(output.Address.Province),
+			PostalCode: optionalString(output.Address.PostalCode),
+			Country:    output.Address.Country,
+		}
+	}
 
 	return ctx.JSON(http.StatusOK, resp)
 }
 
 func (s *Server) Register(ctx echo.Context) error {
-	var req generated.RegisterJSONBody
-	var resp generated.RegisterResponse
-	errors := map[string]interface{}{}
+	defer startSpan(ctx, "Server.Register").End()
+	var req generated.RegisterJSONRequestBody
 	if err := ctx.Bind(&req); err != nil {
-		log.Error().Err(err).Msg("Unable to bind request")
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to bind request")
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: "internal server error",
 		})
 	}
-	passwordSalt := randstr.String(10)
-	var input repository.User = repository.User{
-		UserInfo: repository.UserInfo{
-			PhoneNumber: *req.PhoneNumber,
-			FullName:    *req.FullName,
-		},
-		UserSecret: repository.UserSecret{
-			Password:     *req.Password,
-			PasswordSalt: passwordSalt,
-		},
-	}
 
-	err = validator.Validate(input)
-	if err != nil {
-		json.Unmarshal([]byte(err.Error()), &errors)
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorBadRequestResponse{
-			Errors: &errors,
-		})
-	}
-
-	hashPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(*req.Password+passwordSalt), bcrypt.MinCost)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to hash password")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
-	}
-
-	input.Password = string(hashPasswordBytes)
-
-	user, err := s.getProfileByPhoneNumber(ctx, input.PhoneNumber)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
+	id, err := s.Service.Register(ctx.Request().Context(), service.RegisterInput{
+		PhoneNumber: *req.PhoneNumber,
+		FullName:    *req.FullName,
+		Password:    *req.Password,
+	})
+	var invalid *service.ValidationError
+	if goerrors.As(err, &invalid) {
+		return validationError(ctx, invalid)
 	}
-
-	if user.PhoneNumber != "" {
-		errors["phoneNumber"] = []string{
-			"Phonenumber already exists",
-		}
-
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorBadRequestResponse{
-			Errors: &errors,
+	if goerrors.Is(err, repository.ErrPhoneNumberTaken) {
+		return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+			Message: "Phonenumber already exists",
 		})
 	}
-
-	output, err := s.Repository.InsertUser(ctx.Request().Context(), input)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to insert user")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
+		return repositoryError(ctx, err, "Failed to insert user")
 	}
-	resp.Id = &output.ID
 
-	return ctx.JSON(http.StatusCreated, resp)
+	return ctx.JSON(http.StatusCreated, generated.RegisterResponse{Id: &id})
 }
 
 func (s *Server) Login(ctx echo.Context) error {
-	curr := time.Now()
-	var resp generated.LoginResponse
+	defer startSpan(ctx, "Server.Login").End()
 	var req generated.LoginJSONBody
-
 	if err := ctx.Bind(&req); err != nil {
-		log.Error().Err(err).Msg("Unable to bind request")
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to bind request")
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: "internal server error",
 		})
 	}
 
-	user, err := s.getProfileByPhoneNumber(ctx, *req.PhoneNumber)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
+	input := service.LoginInput{
+		PhoneNumber: req.PhoneNumber,
+		Email:       (*string)(req.Email),
 	}
-
-	if user.ID.String() == "" {
+	if req.Password != nil {
+		input.Password = *req.Password
+	}
+	output, err := s.Service.Login(ctx.Request().Context(), input)
+	if goerrors.Is(err, service.ErrIdentifierRequired) {
 		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "phonenumber or password is wrong",
+			Message: "phonenumber or email is required",
 		})
 	}
-
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(*req.Password+user.PasswordSalt))
-	if err != nil {
+	if goerrors.Is(err, service.ErrWrongCredentials) {
+		wrongCredentials := "phonenumber or password is wrong"
+		if req.PhoneNumber == nil {
+			wrongCredentials = "email or password is wrong"
+		}
 		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
-			Message: "phonenumber or password is wrong",
+			Message: wrongCredentials,
 		})
 	}
-
-	resp.Id = &user.ID
-	token, err := jwt.CreateJWSWithClaims(map[string]interface{}{"id": user.ID.String()})
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to create JWT Token")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
+	if goerrors.Is(err, service.ErrSuspended) {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "account is suspended",
 		})
 	}
-
-	sToken := string(token)
-	resp.Token = &sToken
-
-	if err := s.Repository.UpdateLastLoginAndSuccessfullyLogin(ctx.Request().Context(), repository.UpdateLastLoginAndSuccessfullyLoginInput{
-		ID:        user.ID,
-		LastLogin: &curr,
-	}); err != nil {
-		log.Error().Err(err).Msg("Failed update successfully login and last login")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
-		})
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to login")
 	}
-	return ctx.JSON(http.StatusOK, resp)
+
+	return ctx.JSON(http.StatusOK, generated.LoginResponse{
+		Id:    &output.ID,
+		Token: &output.Token,
+	})
 }
 
 func (s *Server) UpdateProfile(ctx echo.Context) error {
-	var resp generated.UpdateProfileResponse
-	var req generated.UpdateProfileJSONBody
-	errors := map[string]interface{}{}
-
+	defer startSpan(ctx, "Server.UpdateProfile").End()
+	var req generated.UpdateProfileJSONRequestBody
 	if err := ctx.Bind(&req); err != nil {
-		log.Error().Err(err).Msg("Unable to bind request")
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to bind request")
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: "internal server error",
 		})
@@ -191,50 +158,61 @@ func (s *Server) UpdateProfile(ctx echo.Context) error {
 
 	userUUID, err := s.convertUserIDtoUUID(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("Unable to convert uuid")
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to convert uuid")
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: "internal server error",
 		})
 	}
 
-	var input repository.UpdateUserInput = repository.UpdateUserInput{
-		ID:          userUUID,
-		PhoneNumber: *req.PhoneNumber,
-		FullName:    *req.FullName,
+	input := repository.UpdateUserInput{
+		ID:      userUUID,
+		Profile: profileFromRequest(req),
 	}
-
-	err = validator.Validate(input)
-	if err != nil {
-		json.Unmarshal([]byte(err.Error()), &errors)
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorBadRequestResponse{
-			Errors: &errors,
-		})
+	if req.PhoneNumber == nil || req.FullName == nil {
+		// Omitted fields keep their current value.
+		current, err := s.Service.GetProfile(ctx.Request().Context(), userUUID)
+		if goerrors.Is(err, repository.ErrNotFound) {
+			return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+				Message: "profile not found",
+			})
+		}
+		if err != nil {
+			return repositoryError(ctx, err, "Failed to Update Profile")
+		}
+		input.PhoneNumber, input.FullName = current.PhoneNumber, current.FullName
+	}
+	if req.PhoneNumber != nil {
+		input.PhoneNumber = *req.PhoneNumber
+	}
+	if req.FullName != nil {
+		input.FullName = *req.FullName
 	}
 
-	user, err := s.getProfileByPhoneNumber(ctx, input.PhoneNumber)
-	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
+	err = s.Service.UpdateProfile(ctx.Request().Context(), input)
+	var invalid *service.ValidationError
+	if goerrors.As(err, &invalid) {
+		return validationError(ctx, invalid)
+	}
+	if goerrors.Is(err, repository.ErrNotFound) {
+		return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+			Message: "profile not found",
 		})
 	}
-
-	if user.PhoneNumber != "" {
-		if user.ID != userUUID {
-			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
-				Message: "Phonenumber already exists",
-			})
-		}
+	if goerrors.Is(err, repository.ErrPhoneNumberTaken) {
+		return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+			Message: "Phonenumber already exists",
+		})
 	}
-
-	if err := s.Repository.UpdateUser(ctx.Request().Context(), input); err != nil {
-		log.Error().Err(err).Msg("Failed to Update Profile")
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: "internal server error",
+	if goerrors.Is(err, repository.ErrEmailTaken) {
+		return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+			Message: "Email already exists",
 		})
 	}
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to Update Profile")
+	}
 
-	resp.Id = &userUUID
-	return ctx.JSON(http.StatusOK, resp)
+	return ctx.JSON(http.StatusOK, generated.UpdateProfileResponse{Id: &userUUID})
 }
 
 func (s *Server) convertUserIDtoUUID(ctx echo.Context) (uuid.UUID, error) {
@@ -242,18 +220,59 @@ func (s *Server) convertUserIDtoUUID(ctx echo.Context) (uuid.UUID, error) {
 	return uuid.Parse(userID)
 }
 
-func (s *Server) getProfileByPhoneNumber(ctx echo.Context, phoneNumber string) (repository.User, error) {
-	var getUserByPhoneInput repository.GetUserByPhoneNumberInput = repository.GetUserByPhoneNumberInput{
-		PhoneNumber: phoneNumber,
+// repositoryError logs err of a repository call and answers 503 when the
+// database is unavailable and 500 otherwise.
+func repositoryError(ctx echo.Context, err error, msg string) error {
+	log.Ctx(ctx.Request().Context()).Error().Err(err).Msg(msg)
+	if goerrors.Is(err, repository.ErrUnavailable) {
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, "5")
+		return ctx.JSON(http.StatusServiceUnavailable, generated.ErrorResponse{
+			Message: "service unavailable",
+		})
+	}
+	return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+		Message: "internal server error",
+	})
+}
+
+// validationError answers 400 with the messages of every invalid field.
+func validationError(ctx echo.Context, invalid *service.ValidationError) error {
+	errors := map[string]interface{}{}
+	for field, messages := range invalid.Fields {
+		errors[field] = messages
 	}
+	return ctx.JSON(http.StatusBadRequest, generated.ErrorBadRequestResponse{
+		Errors: &errors,
+	})
+}
 
-	outputUser, err := s.Repository.GetUserByPhoneNumber(ctx.Request().Context(), getUserByPhoneInput)
-	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Error().Err(err).Msg("Failed to get profile")
-			return repository.User{}, err
+func profileFromRequest(req generated.UpdateProfileRequest) repository.Profile {
+	profile := repository.Profile{
+		Email:  (*string)(req.Email),
+		Gender: (*string)(req.Gender),
+	}
+	if req.DateOfBirth != nil {
+		profile.DateOfBirth = &req.DateOfBirth.Time
+	}
+	if req.Address != nil {
+		profile.Address = &repository.Address{
+			Street:  req.Address.Street,
+			City:    req.Address.City,
+			Country: req.Address.Country,
+		}
+		if req.Address.Province != nil {
+			profile.Address.Province = *req.Address.Province
+		}
+		if req.Address.PostalCode != nil {
+			profile.Address.PostalCode = *req.Address.PostalCode
 		}
 	}
+	return profile
+}
 
-	return outputUser, nil
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
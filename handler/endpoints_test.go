@@ -1,7 +1,194 @@
 package handler
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
 
 func TestHello(t *testing.T) {
 
 }
+
+func TestRegisterPhoneNumberTakenByConcurrentRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+	// The number is free when checked, another registration inserts it
+	// before us.
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), gomock.Any()).Return(repository.User{}, repository.ErrNotFound)
+	repo.EXPECT().InsertUser(gomock.Any(), gomock.Any()).Return(repository.InsertUserOutput{}, repository.ErrPhoneNumberTaken)
+
+	s := NewServer(NewServerOptions{Repository: repo, BcryptCost: bcrypt.MinCost})
+	req := httptest.NewRequest(http.MethodPost, "/register",
+		strings.NewReader(`{"phoneNumber":"+628123456789","fullName":"Test test","password":"Passw0rd!"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, s.Register(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.JSONEq(t, `{"message":"Phonenumber already exists"}`, rec.Body.String())
+}
+
+func TestGetProfileNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	repo.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).Return(repository.UserInfo{}, repository.ErrNotFound)
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/profile", nil), rec)
+	c.Set("user_id", uuid.NewString())
+
+	require.NoError(t, s.GetProfile(c))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetProfileDatabaseUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	repo.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).
+		Return(repository.UserInfo{}, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/profile", nil), rec)
+	c.Set("user_id", uuid.NewString())
+
+	require.NoError(t, s.GetProfile(c))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	repo.EXPECT().GetUserByLoginIdentifier(gomock.Any(), gomock.Any()).Return(repository.User{}, repository.ErrNotFound)
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	req := httptest.NewRequest(http.MethodPost, "/users/login",
+		strings.NewReader(`{"phoneNumber":"+628123456789","password":"Passw0rd!"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, s.Login(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.JSONEq(t, `{"message":"phonenumber or password is wrong"}`, rec.Body.String())
+}
+
+func TestLoginSuspendedUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	hash, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!"+"salt"), bcrypt.MinCost)
+	require.NoError(t, err)
+	suspendedAt := time.Now()
+	repo.EXPECT().GetUserByLoginIdentifier(gomock.Any(), gomock.Any()).Return(repository.User{
+		ID:         uuid.New(),
+		UserInfo:   repository.UserInfo{PhoneNumber: "+628123456789", SuspendedAt: &suspendedAt},
+		UserSecret: repository.UserSecret{Password: string(hash), PasswordSalt: "salt"},
+	}, nil)
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	req := httptest.NewRequest(http.MethodPost, "/users/login",
+		strings.NewReader(`{"phoneNumber":"+628123456789","password":"Passw0rd!"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	lockedOut := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginLockedOut))
+	failures := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginFailure))
+
+	require.NoError(t, s.Login(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.JSONEq(t, `{"message":"account is suspended"}`, rec.Body.String())
+	require.Equal(t, lockedOut+1, testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginLockedOut)))
+	require.Equal(t, failures, testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.LoginFailure)))
+}
+
+func TestUpdateProfileRecordsPhoneNumberChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	userID := uuid.New()
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), gomock.Any()).Return(repository.User{}, repository.ErrNotFound)
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+	repo.EXPECT().GetUserByID(gomock.Any(), repository.GetUserByIDInput{ID: userID}).
+		Return(repository.UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"}, nil)
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
+	var events []repository.InsertOutboxEventInput
+	repo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(_ context.Context, input repository.InsertOutboxEventInput) error {
+			events = append(events, input)
+			return nil
+		})
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	req := httptest.NewRequest(http.MethodPut, "/users",
+		strings.NewReader(`{"phoneNumber":"0812 9876 5432","fullName":"Test test"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_id", userID.String())
+
+	require.NoError(t, s.UpdateProfile(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, events, 2)
+	require.Equal(t, outbox.ProfileUpdated, events[0].Type)
+	require.JSONEq(t, `{"phoneNumber":"+6281298765432","fullName":"Test test"}`, string(events[0].Payload))
+	require.Equal(t, outbox.PhoneNumberChanged, events[1].Type)
+	require.Equal(t, userID, events[1].UserID)
+	require.JSONEq(t, `{"previousPhoneNumber":"+628123456789","phoneNumber":"+6281298765432"}`, string(events[1].Payload))
+}
+
+func TestUpdateProfileKeepsOmittedFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	userID := uuid.New()
+	current := repository.UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"}
+	repo.EXPECT().GetUserByID(gomock.Any(), repository.GetUserByIDInput{ID: userID}).Return(current, nil).Times(2)
+	repo.EXPECT().GetUserByPhoneNumber(gomock.Any(), gomock.Any()).
+		Return(repository.User{ID: userID, UserInfo: current}, nil)
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+	var updated repository.UpdateUserInput
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input repository.UpdateUserInput) error {
+			updated = input
+			return nil
+		})
+	repo.EXPECT().InsertOutboxEvent(gomock.Any(), gomock.Any()).Return(nil)
+
+	s := NewServer(NewServerOptions{Repository: repo})
+	req := httptest.NewRequest(http.MethodPut, "/users",
+		strings.NewReader(`{"gender":"female"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user_id", userID.String())
+
+	require.NoError(t, s.UpdateProfile(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "+628123456789", updated.PhoneNumber)
+	require.Equal(t, "Test test", updated.FullName)
+	require.Equal(t, "female", *updated.Gender)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// IntrospectToken tells the client authenticated by the middleware whether
+// a token is active, see RFC 7662. A token is active when the validator
+// accepts it and the service does too, see service.TokenActive, so deleting
+// a user revokes their tokens. Inactive tokens are answered with active
+// alone.
+func (s *Server) IntrospectToken(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.IntrospectToken").End()
+	inactive := generated.IntrospectionResponse{Active: false}
+	token, err := s.Validator.ValidateJws(ctx.FormValue("token"))
+	if err != nil {
+		return ctx.JSON(http.StatusOK, inactive)
+	}
+	claimedID, err := middleware.GetClaimsFromToken(token)
+	if err != nil {
+		return ctx.JSON(http.StatusOK, inactive)
+	}
+	userID, err := uuid.Parse(claimedID)
+	if err != nil {
+		return ctx.JSON(http.StatusOK, inactive)
+	}
+
+	active, err := s.Service.TokenActive(ctx.Request().Context(), userID, token.IssuedAt())
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to introspect token")
+	}
+	if !active {
+		return ctx.JSON(http.StatusOK, inactive)
+	}
+
+	tokenType := generated.Bearer
+	resp := generated.IntrospectionResponse{
+		Active:    true,
+		Sub:       &userID,
+		TokenType: &tokenType,
+	}
+	if issuer := token.Issuer(); issuer != "" {
+		resp.Iss = &issuer
+	}
+	if audience := token.Audience(); len(audience) > 0 {
+		resp.Aud = &audience[0]
+	}
+	if exp := token.Expiration(); !exp.IsZero() {
+		unix := exp.Unix()
+		resp.Exp = &unix
+	}
+	if iat := token.IssuedAt(); !iat.IsZero() {
+		unix := iat.Unix()
+		resp.Iat = &unix
+	}
+	if scope, ok := token.Get("scope"); ok {
+		if scope, ok := scope.(string); ok {
+			resp.Scope = &scope
+		}
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
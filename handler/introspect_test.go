@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func introspect(t *testing.T, s *Server, token string) generated.IntrospectionResponse {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/introspect",
+		strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, s.IntrospectToken(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp generated.IntrospectionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestIntrospectToken(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	validator, err := middleware.NewJWSValidator(middleware.Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	repo := repository.NewMockRepositoryInterface(ctrl)
+	s := NewServer(NewServerOptions{Repository: repo, Validator: validator})
+
+	activeID, suspendedID, deletedID := uuid.New(), uuid.New(), uuid.New()
+	suspendedAt := time.Now()
+	repo.EXPECT().GetUserByID(gomock.Any(), repository.GetUserByIDInput{ID: activeID}).
+		Return(repository.UserInfo{PhoneNumber: "+628123456789"}, nil)
+	repo.EXPECT().GetUserByID(gomock.Any(), repository.GetUserByIDInput{ID: suspendedID}).
+		Return(repository.UserInfo{SuspendedAt: &suspendedAt}, nil)
+	repo.EXPECT().GetUserByID(gomock.Any(), repository.GetUserByIDInput{ID: deletedID}).
+		Return(repository.UserInfo{}, repository.ErrNotFound)
+	token := func(id uuid.UUID, ttl time.Duration) string {
+		signed, err := signer.CreateExpiringJWS(context.Background(), map[string]interface{}{"id": id.String()}, ttl)
+		require.NoError(t, err)
+		return string(signed)
+	}
+
+	resp := introspect(t, s, token(activeID, time.Minute))
+	require.True(t, resp.Active)
+	require.Equal(t, activeID, *resp.Sub)
+	require.Equal(t, jwt.DefaultIssuer, *resp.Iss)
+	require.Equal(t, jwt.DefaultAudience, *resp.Aud)
+	require.InDelta(t, time.Now().Add(time.Minute).Unix(), *resp.Exp, 2)
+	require.Equal(t, generated.Bearer, *resp.TokenType)
+	require.Nil(t, resp.Scope)
+
+	for name, token := range map[string]string{
+		"expired":   token(activeID, -time.Minute),
+		"suspended": token(suspendedID, time.Minute),
+		"deleted":   token(deletedID, time.Minute),
+		"malformed": "not-a-token",
+	} {
+		require.Equal(t, generated.IntrospectionResponse{Active: false}, introspect(t, s, token), name)
+	}
+}
@@ -1,19 +1,73 @@
 package handler
 
 import (
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/blobstore"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/mail"
+	"InterviewBackendSawitProGolang/pkg/middleware"
+	"InterviewBackendSawitProGolang/pkg/signedlink"
+	"InterviewBackendSawitProGolang/pkg/tracing"
 	"InterviewBackendSawitProGolang/repository"
+	"InterviewBackendSawitProGolang/service"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
-	Repository repository.RepositoryInterface
+	// Service holds the domain logic shared with the gRPC API.
+	Service     *service.Service
+	Repository  repository.RepositoryInterface
+	TokenSigner *jwt.Signer
+	// BcryptCost is the cost new password hashes are generated with.
+	BcryptCost int
+	Mailer     mail.Sender
+	LinkSigner *signedlink.Signer
+	BlobStore  blobstore.BlobStore
+	// Validator checks the tokens of IntrospectToken.
+	Validator middleware.JWSValidator
+	// PublicURL is the externally reachable base URL used in links sent to
+	// users, e.g. "https://users.example.com".
+	PublicURL string
 }
 
 type NewServerOptions struct {
-	Repository repository.RepositoryInterface
+	Repository  repository.RepositoryInterface
+	TokenSigner *jwt.Signer
+	BcryptCost  int
+	TokenTTL    time.Duration
+	Mailer      mail.Sender
+	LinkSigner  *signedlink.Signer
+	BlobStore   blobstore.BlobStore
+	Validator   middleware.JWSValidator
+	PublicURL   string
 }
 
 func NewServer(opts NewServerOptions) *Server {
 	return &Server{
-		Repository: opts.Repository,
+		Service: service.NewService(service.NewServiceOptions{
+			Repository:  opts.Repository,
+			TokenSigner: opts.TokenSigner,
+			BcryptCost:  opts.BcryptCost,
+			TokenTTL:    opts.TokenTTL,
+		}),
+		Repository:  opts.Repository,
+		TokenSigner: opts.TokenSigner,
+		BcryptCost:  opts.BcryptCost,
+		Mailer:      opts.Mailer,
+		LinkSigner:  opts.LinkSigner,
+		BlobStore:   opts.BlobStore,
+		Validator:   opts.Validator,
+		PublicURL:   opts.PublicURL,
 	}
 }
+
+// startSpan starts a span for a handler and makes it the parent of the spans
+// of everything the handler calls with the request context.
+func startSpan(ctx echo.Context, name string) trace.Span {
+	spanCtx, span := tracing.Start(ctx.Request().Context(), name)
+	ctx.SetRequest(ctx.Request().WithContext(spanCtx))
+	return span
+}
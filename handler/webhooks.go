@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/webhook"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultDeliveriesLimit is the number of deliveries listed when the
+// request does not set one.
+const defaultDeliveriesLimit = 50
+
+func (s *Server) CreateWebhook(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.CreateWebhook").End()
+	var req generated.CreateWebhookJSONRequestBody
+	if err := ctx.Bind(&req); err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to bind request")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+	if u, err := url.Parse(req.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "url must be an absolute http or https URL",
+		})
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to generate webhook secret")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+	input := repository.InsertWebhookInput{URL: req.Url, Secret: secret}
+	if req.Events != nil {
+		for _, event := range *req.Events {
+			input.Events = append(input.Events, string(event))
+		}
+	}
+	output, err := s.Repository.InsertWebhook(ctx.Request().Context(), input)
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to create webhook")
+	}
+
+	created := webhookResponse(repository.Webhook{ID: output.ID, URL: input.URL, Events: input.Events, CreatedAt: output.CreatedAt})
+	return ctx.JSON(http.StatusCreated, generated.CreatedWebhook{
+		Id:        created.Id,
+		Url:       created.Url,
+		Events:    created.Events,
+		CreatedAt: created.CreatedAt,
+		Secret:    secret,
+	})
+}
+
+func (s *Server) ListWebhooks(ctx echo.Context) error {
+	defer startSpan(ctx, "Server.ListWebhooks").End()
+	webhooks, err := s.Repository.GetWebhooks(ctx.Request().Context())
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to list webhooks")
+	}
+	resp := generated.WebhookList{Webhooks: []generated.Webhook{}}
+	for _, w := range webhooks {
+		resp.Webhooks = append(resp.Webhooks, webhookResponse(w))
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) GetWebhook(ctx echo.Context, id generated.WebhookID) error {
+	defer startSpan(ctx, "Server.GetWebhook").End()
+	w, err := s.Repository.GetWebhookByID(ctx.Request().Context(), repository.GetWebhookByIDInput{ID: id})
+	if err != nil {
+		return webhookError(ctx, err, "Failed to get webhook")
+	}
+	return ctx.JSON(http.StatusOK, webhookResponse(w))
+}
+
+func (s *Server) DeleteWebhook(ctx echo.Context, id generated.WebhookID) error {
+	defer startSpan(ctx, "Server.DeleteWebhook").End()
+	if err := s.Repository.DeleteWebhook(ctx.Request().Context(), repository.DeleteWebhookInput{ID: id}); err != nil {
+		return webhookError(ctx, err, "Failed to delete webhook")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) ListWebhookDeliveries(ctx echo.Context, id generated.WebhookID, params generated.ListWebhookDeliveriesParams) error {
+	defer startSpan(ctx, "Server.ListWebhookDeliveries").End()
+	if _, err := s.Repository.GetWebhookByID(ctx.Request().Context(), repository.GetWebhookByIDInput{ID: id}); err != nil {
+		return webhookError(ctx, err, "Failed to get webhook")
+	}
+	input := repository.GetWebhookDeliveriesInput{WebhookID: id, Limit: defaultDeliveriesLimit}
+	if params.Status != nil {
+		input.Status = (*string)(params.Status)
+	}
+	if params.Limit != nil {
+		input.Limit = *params.Limit
+	}
+	deliveries, err := s.Repository.GetWebhookDeliveries(ctx.Request().Context(), input)
+	if err != nil {
+		return repositoryError(ctx, err, "Failed to list webhook deliveries")
+	}
+	resp := generated.WebhookDeliveryList{Deliveries: []generated.WebhookDelivery{}}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, deliveryResponse(delivery))
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) GetWebhookDelivery(ctx echo.Context, id generated.WebhookID, deliveryId generated.DeliveryID) error {
+	defer startSpan(ctx, "Server.GetWebhookDelivery").End()
+	output, err := s.Repository.GetWebhookDelivery(ctx.Request().Context(), repository.GetWebhookDeliveryInput{WebhookID: id, ID: deliveryId})
+	if err != nil {
+		return webhookError(ctx, err, "Failed to get webhook delivery")
+	}
+
+	delivery := deliveryResponse(output.WebhookDelivery)
+	resp := generated.WebhookDeliveryDetail{
+		Id:             delivery.Id,
+		EventId:        delivery.EventId,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+		Log:            []generated.WebhookDeliveryAttempt{},
+	}
+	if err := json.Unmarshal(output.Payload, &resp.Payload); err != nil {
+		log.Ctx(ctx.Request().Context()).Error().Err(err).Msg("Unable to decode webhook payload")
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: "internal server error",
+		})
+	}
+	for _, attempt := range output.Log {
+		resp.Log = append(resp.Log, generated.WebhookDeliveryAttempt{
+			AttemptedAt: attempt.AttemptedAt,
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			DurationMs:  attempt.Duration.Milliseconds(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) RedeliverWebhookDelivery(ctx echo.Context, id generated.WebhookID, deliveryId generated.DeliveryID) error {
+	defer startSpan(ctx, "Server.RedeliverWebhookDelivery").End()
+	// Deliveries of deleted webhooks would die again right away.
+	if _, err := s.Repository.GetWebhookByID(ctx.Request().Context(), repository.GetWebhookByIDInput{ID: id}); err != nil {
+		return webhookError(ctx, err, "Failed to get webhook")
+	}
+	err := s.Repository.RedeliverWebhookDelivery(ctx.Request().Context(), repository.RedeliverWebhookDeliveryInput{
+		WebhookID: id,
+		ID:        deliveryId,
+		At:        time.Now(),
+	})
+	if err != nil {
+		return webhookError(ctx, err, "Failed to redeliver webhook delivery")
+	}
+	return ctx.JSON(http.StatusAccepted, generated.MessageResponse{
+		Message: "delivery queued",
+	})
+}
+
+// webhookError responds 404 to ErrNotFound and like repositoryError to
+// the other errors.
+func webhookError(ctx echo.Context, err error, msg string) error {
+	if goerrors.Is(err, repository.ErrNotFound) {
+		return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+			Message: "webhook not found",
+		})
+	}
+	return repositoryError(ctx, err, msg)
+}
+
+func webhookResponse(w repository.Webhook) generated.Webhook {
+	resp := generated.Webhook{
+		Id:        w.ID,
+		Url:       w.URL,
+		Events:    []generated.EventType{},
+		CreatedAt: w.CreatedAt,
+	}
+	for _, event := range w.Events {
+		resp.Events = append(resp.Events, generated.EventType(event))
+	}
+	return resp
+}
+
+func deliveryResponse(delivery repository.WebhookDelivery) generated.WebhookDelivery {
+	return generated.WebhookDelivery{
+		Id:             delivery.ID,
+		EventId:        delivery.EventID,
+		EventType:      generated.EventType(delivery.EventType),
+		Status:         generated.WebhookDeliveryStatus(delivery.Status),
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhookReturnsItsSecretOnce(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := NewServer(NewServerOptions{Repository: repo})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks",
+		strings.NewReader(`{"url":"https://example.com/hooks","events":["UserRegistered"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	require.NoError(t, s.CreateWebhook(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created generated.CreatedWebhook
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+	require.Equal(t, []generated.EventType{"UserRegistered"}, created.Events)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, s.GetWebhook(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), created.Id))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), created.Secret)
+}
+
+func TestCreateWebhookRejectsRelativeURL(t *testing.T) {
+	s := NewServer(NewServerOptions{Repository: repository.NewMemoryRepository()})
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"/hooks"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, s.CreateWebhook(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRedeliverDeadDelivery(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	webhook, err := repo.InsertWebhook(ctx, repository.InsertWebhookInput{URL: "https://example.com/hooks", Secret: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, repo.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{
+		WebhookIDs: []uuid.UUID{webhook.ID}, EventID: 1, EventType: "UserLoggedIn", Payload: []byte(`{"id":1}`), NextAttemptAt: time.Now(),
+	}))
+	claimed, err := repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Now: time.Now(), LeaseUntil: time.Now().Add(time.Minute), Limit: 1})
+	require.NoError(t, err)
+	message := "connection refused"
+	require.NoError(t, repo.RecordWebhookDeliveryAttempt(ctx, repository.RecordWebhookDeliveryAttemptInput{
+		DeliveryID: claimed[0].ID,
+		Attempt:    repository.WebhookDeliveryAttempt{AttemptedAt: time.Now(), Error: &message},
+		Status:     repository.WebhookDeliveryDead,
+	}))
+	s := NewServer(NewServerOptions{Repository: repo})
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, s.GetWebhookDelivery(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), webhook.ID, claimed[0].ID))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var delivery generated.WebhookDeliveryDetail
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &delivery))
+	require.Equal(t, generated.WebhookDeliveryStatus("dead"), delivery.Status)
+	require.Equal(t, map[string]interface{}{"id": float64(1)}, delivery.Payload)
+	require.Len(t, delivery.Log, 1)
+	require.Equal(t, message, *delivery.Log[0].Error)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, s.RedeliverWebhookDelivery(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec), webhook.ID, claimed[0].ID))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	pending := repository.WebhookDeliveryPending
+	deliveries, err := repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{WebhookID: webhook.ID, Status: &pending, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, s.RedeliverWebhookDelivery(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec), uuid.New(), claimed[0].ID))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -1,14 +1,8 @@
-/**
-  This is the SQL script that will be used to initialize the database schema.
-  We will evaluate you based on how well you design your database.
-  1. How you design the tables.
-  2. How you choose the data types and keys.
-  3. How you name the fields.
-  In this assignment we will use PostgreSQL as the database.
-  */
+-- Users table as originally created from database.sql. IF NOT EXISTS lets
+-- databases initialized from that script adopt the migrations as is.
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
 	id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
 	phone_number VARCHAR (13) UNIQUE NOT NULL,
 	full_name VARCHAR ( 60 ) NOT NULL,
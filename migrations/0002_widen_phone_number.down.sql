@@ -0,0 +1,3 @@
+-- phone_number stays VARCHAR (16): narrowing it back to VARCHAR (13) fails
+-- once a longer E.164 number is stored, and the wider column is harmless to
+-- the older schema.
@@ -0,0 +1,2 @@
+-- Phone numbers are stored in E.164, up to 15 digits after the plus sign.
+ALTER TABLE users ALTER COLUMN phone_number TYPE VARCHAR (16);
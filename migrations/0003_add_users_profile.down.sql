@@ -0,0 +1,5 @@
+ALTER TABLE users
+	DROP COLUMN IF EXISTS address,
+	DROP COLUMN IF EXISTS gender,
+	DROP COLUMN IF EXISTS date_of_birth,
+	DROP COLUMN IF EXISTS email;
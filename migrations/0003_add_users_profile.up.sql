@@ -0,0 +1,6 @@
+-- Optional profile fields.
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS email VARCHAR (254),
+	ADD COLUMN IF NOT EXISTS date_of_birth date,
+	ADD COLUMN IF NOT EXISTS gender VARCHAR (16) CHECK (gender IN ('male', 'female', 'other')),
+	ADD COLUMN IF NOT EXISTS address jsonb;
@@ -0,0 +1,5 @@
+-- Verified emails log in like phone numbers, so they are unique regardless
+-- of case.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (LOWER(email));
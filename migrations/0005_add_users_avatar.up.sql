@@ -0,0 +1,2 @@
+-- Storage key of the uploaded avatar, see pkg/avatar.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR (255);
@@ -0,0 +1,15 @@
+-- Domain events written in the transaction of the change they describe and
+-- delivered by the outbox dispatcher, see pkg/outbox.
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id bigserial PRIMARY KEY,
+	user_id uuid NOT NULL,
+	type VARCHAR (64) NOT NULL,
+	payload jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	attempts int NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz,
+	last_error text,
+	dispatched_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending ON outbox_events (id) WHERE dispatched_at IS NULL;
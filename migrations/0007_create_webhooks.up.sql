@@ -0,0 +1,39 @@
+-- Webhook subscriptions, the deliveries of events to them and the log of
+-- every delivery attempt, see pkg/webhook.
+CREATE TABLE IF NOT EXISTS webhooks (
+	id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+	url text NOT NULL,
+	events text[] NOT NULL DEFAULT '{}',
+	secret text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	deleted_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id bigserial PRIMARY KEY,
+	webhook_id uuid NOT NULL REFERENCES webhooks (id),
+	event_id bigint NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	payload jsonb NOT NULL,
+	status VARCHAR (16) NOT NULL,
+	attempts int NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz,
+	last_status_code int,
+	last_error text,
+	created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	delivered_at timestamptz,
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+	id bigserial PRIMARY KEY,
+	delivery_id bigint NOT NULL REFERENCES webhook_deliveries (id),
+	attempted_at timestamptz NOT NULL,
+	status_code int,
+	error text,
+	duration_ms int NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id);
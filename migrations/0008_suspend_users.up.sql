@@ -0,0 +1,4 @@
+-- Suspended users cannot log in, userctl lists the recent registrations.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at DESC) WHERE deleted_at IS NULL;
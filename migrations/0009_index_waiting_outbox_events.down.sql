@@ -0,0 +1 @@
+DROP INDEX IF EXISTS outbox_events_waiting;
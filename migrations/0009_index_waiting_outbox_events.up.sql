@@ -0,0 +1,3 @@
+-- Events waiting for a retry hold back the later events of their user, see
+-- GetPendingOutboxEvents.
+CREATE INDEX IF NOT EXISTS outbox_events_waiting ON outbox_events (user_id, id) WHERE dispatched_at IS NULL AND next_attempt_at IS NOT NULL;
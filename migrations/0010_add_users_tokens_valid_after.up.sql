@@ -0,0 +1,3 @@
+-- Tokens issued before tokens_valid_after are rejected, it is set when the
+-- password is reset or the user suspended.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after timestamptz;
@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL migrations of the users
+// database. Files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, see pkg/migrate.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,166 @@
+// Package avatar validates uploaded profile pictures and turns them into the
+// re-encoded original and square thumbnails that are stored. Re-encoding
+// drops EXIF and any other metadata embedded in the upload.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// MaxBytes is the largest upload accepted.
+	MaxBytes = 5 << 20
+	// MaxPixels bounds the width times height of uploads, 24 megapixels,
+	// so decoding a small but huge image cannot exhaust memory.
+	MaxPixels = 24_000_000
+	// OriginalSize is the largest width or height of the stored original.
+	OriginalSize = 1024
+	// ContentType is the type of every stored image.
+	ContentType = "image/jpeg"
+
+	jpegQuality = 85
+)
+
+// ThumbnailSizes are the edge lengths of the square thumbnails generated for
+// every avatar.
+var ThumbnailSizes = []int{64, 256}
+
+// OriginalKey is the blob key of the original of the avatar stored under
+// key.
+func OriginalKey(key string) string {
+	return key + "/original.jpg"
+}
+
+// ThumbnailKey is the blob key of the thumbnail of edge length size.
+func ThumbnailKey(key string, size int) string {
+	return fmt.Sprintf("%s/%d.jpg", key, size)
+}
+
+var (
+	ErrTooLarge           = errors.New("avatar must not be larger than 5 MB")
+	ErrUnsupportedType    = errors.New("avatar must be a JPEG, PNG or GIF image")
+	ErrInvalidImage       = errors.New("avatar is not a valid image")
+	ErrDimensionsTooLarge = errors.New("avatar must not be larger than 24 megapixels")
+)
+
+var allowedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Result holds the JPEG encoded images generated from an upload.
+type Result struct {
+	Original   []byte
+	Thumbnails map[int][]byte
+}
+
+// Process validates an uploaded image and generates the stored variants. The
+// content type is sniffed from the data, the one claimed by the client is
+// ignored.
+func Process(data []byte) (Result, error) {
+	if len(data) > MaxBytes {
+		return Result{}, ErrTooLarge
+	}
+	if !allowedContentTypes[http.DetectContentType(data)] {
+		return Result{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Result{}, ErrDimensionsTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrInvalidImage
+	}
+	img = orient(img, exifOrientation(data))
+
+	var result Result
+	result.Original, err = encode(fit(img, OriginalSize))
+	if err != nil {
+		return Result{}, err
+	}
+	result.Thumbnails = make(map[int][]byte, len(ThumbnailSizes))
+	for _, size := range ThumbnailSizes {
+		result.Thumbnails[size], err = encode(square(img, size))
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// fit scales img down so neither side exceeds size.
+func fit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		h = h * size / w
+		w = size
+	} else {
+		w = w * size / h
+		h = size
+	}
+	return scale(img, b, max(w, 1), max(h, 1))
+}
+
+// square crops the center of img to a square and scales it to size.
+func square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	edge := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-edge)/2
+	y := b.Min.Y + (b.Dy()-edge)/2
+	return scale(img, image.Rect(x, y, x+edge, y+edge), size, size)
+}
+
+func scale(img image.Image, src image.Rectangle, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, src, xdraw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image) ([]byte, error) {
+	// JPEG has no alpha channel, flatten transparent images onto white.
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// withSize rewrites the IHDR chunk of a PNG to claim w by h pixels, the
+// image data is left as is.
+func withSize(data []byte, w, h int) []byte {
+	data = bytes.Clone(data)
+	binary.BigEndian.PutUint32(data[16:], uint32(w))
+	binary.BigEndian.PutUint32(data[20:], uint32(h))
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func decodeJPEG(t *testing.T, data []byte) image.Image {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return img
+}
+
+func TestProcess(t *testing.T) {
+	result, err := Process(encodePNG(t, 300, 200))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 300, 200), decodeJPEG(t, result.Original).Bounds())
+	for _, size := range ThumbnailSizes {
+		require.Equal(t, image.Rect(0, 0, size, size), decodeJPEG(t, result.Thumbnails[size]).Bounds())
+	}
+
+	result, err = Process(encodePNG(t, 2048, 512))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, OriginalSize, 256), decodeJPEG(t, result.Original).Bounds())
+}
+
+func TestProcessRejectsInvalidUploads(t *testing.T) {
+	_, err := Process([]byte("<html>not an image</html>"))
+	require.ErrorIs(t, err, ErrUnsupportedType)
+
+	_, err = Process(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	require.ErrorIs(t, err, ErrInvalidImage)
+
+	_, err = Process(make([]byte, MaxBytes+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	// Both sides are small, the pixel count is not.
+	_, err = Process(withSize(encodePNG(t, 1, 1), 6000, 5000))
+	require.ErrorIs(t, err, ErrDimensionsTooLarge)
+	// Within the bound the image is decoded, and found to be truncated.
+	_, err = Process(withSize(encodePNG(t, 1, 1), 4000, 1000))
+	require.ErrorIs(t, err, ErrInvalidImage)
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying orientation right
+// after the SOI marker of a JPEG.
+func withOrientation(jpg []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+	return append(append([]byte{0xFF, 0xD8}, segment...), jpg[2:]...)
+}
+
+func TestProcessAppliesExifOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil))
+	data := withOrientation(buf.Bytes(), 6)
+	require.Equal(t, 6, exifOrientation(data))
+
+	result, err := Process(data)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 200, 300), decodeJPEG(t, result.Original).Bounds())
+	require.Equal(t, 1, exifOrientation(result.Original))
+}
@@ -0,0 +1,16 @@
+// Package blobstore stores binary objects such as user avatars.
+package blobstore
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore persists objects under slash separated keys, e.g.
+// "avatars/<user id>/<version>/256.jpg", and knows the public URL they are
+// served from.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
@@ -0,0 +1,28 @@
+package blobstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store := &LocalStore{Root: t.TempDir(), BaseURL: "http://localhost:8080/blobs/"}
+
+	require.NoError(t, store.Put(ctx, "avatars/1/original.jpg", strings.NewReader("image"), "image/jpeg"))
+	content, err := os.ReadFile(filepath.Join(store.Root, "avatars", "1", "original.jpg"))
+	require.NoError(t, err)
+	require.Equal(t, "image", string(content))
+	require.Equal(t, "http://localhost:8080/blobs/avatars/1/original.jpg", store.URL("avatars/1/original.jpg"))
+
+	require.NoError(t, store.Delete(ctx, "avatars/1/original.jpg"))
+	require.NoError(t, store.Delete(ctx, "avatars/1/original.jpg"))
+
+	require.Error(t, store.Put(ctx, "../escape", strings.NewReader("x"), "text/plain"))
+	require.Error(t, store.Put(ctx, "", strings.NewReader("x"), "text/plain"))
+}
@@ -0,0 +1,127 @@
+package bulk
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+)
+
+// ExportRecord is an exported user. Its fields but ID and CreatedAt are
+// those of Record, so the file can be imported again.
+type ExportRecord struct {
+	ID              uuid.UUID  `json:"id"`
+	PhoneNumber     string     `json:"phoneNumber"`
+	FullName        string     `json:"fullName"`
+	Email           *string    `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	PasswordHash    string     `json:"passwordHash"`
+	PasswordSalt    string     `json:"passwordSalt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	SuspendedAt     *time.Time `json:"suspendedAt,omitempty"`
+}
+
+var exportColumns = []string{
+	"id", columnPhoneNumber, columnFullName, columnEmail, columnEmailVerifiedAt,
+	columnPasswordHash, columnPasswordSalt, "created_at", columnSuspendedAt,
+}
+
+func newExportRecord(user repository.StoredUser) ExportRecord {
+	return ExportRecord{
+		ID:              user.ID,
+		PhoneNumber:     user.PhoneNumber,
+		FullName:        user.FullName,
+		Email:           user.Email,
+		EmailVerifiedAt: utc(user.EmailVerifiedAt),
+		PasswordHash:    user.Password,
+		PasswordSalt:    user.PasswordSalt,
+		CreatedAt:       user.CreatedAt.UTC(),
+		SuspendedAt:     utc(user.SuspendedAt),
+	}
+}
+
+func (r ExportRecord) row() []string {
+	email := ""
+	if r.Email != nil {
+		email = *r.Email
+	}
+	return []string{
+		r.ID.String(), r.PhoneNumber, r.FullName, email, timestamp(r.EmailVerifiedAt),
+		r.PasswordHash, r.PasswordSalt, timestamp(&r.CreatedAt), timestamp(r.SuspendedAt),
+	}
+}
+
+type ExportOptions struct {
+	Repository repository.RepositoryInterface
+	// BatchSize is the number of users read by one query.
+	BatchSize int
+}
+
+// Export writes every user that is not deleted to w in format, ordered by
+// ID, and returns how many it wrote. The file holds the password hashes and
+// salts of the users, so it must be kept as safe as the database.
+func Export(ctx context.Context, w io.Writer, format string, opts ExportOptions) (int, error) {
+	var write func(ExportRecord) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return 0, err
+		}
+		write = func(r ExportRecord) error { return cw.Write(r.row()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(r ExportRecord) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	default:
+		return 0, fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	exported := 0
+	input := repository.GetUsersInput{Limit: opts.BatchSize}
+	for {
+		users, err := opts.Repository.GetUsers(ctx, input)
+		if err != nil {
+			return exported, fmt.Errorf("reading users after %s: %w", input.AfterID, err)
+		}
+		for _, user := range users {
+			if err := write(newExportRecord(user)); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		if len(users) < input.Limit {
+			return exported, flush()
+		}
+		input.AfterID = users[len(users)-1].ID
+	}
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
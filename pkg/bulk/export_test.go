@@ -0,0 +1,74 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCanBeImported(t *testing.T) {
+	ctx := context.Background()
+	source := repository.NewMemoryRepository()
+	file := "phone_number,full_name,email,email_verified_at,suspended_at,password\n" +
+		"08123456789,Alice Doe,alice@example.com,2024-01-02T03:04:05.5Z,,Str0ng!Pass\n" +
+		"08129876543,Bob Doe,,,2024-01-03T03:04:05Z,Str0ng!Pass\n" +
+		"08111111111,Carol Doe,,,,Str0ng!Pass\n"
+	_, err := Import(ctx, strings.NewReader(file), FormatCSV, importOptions(source))
+	require.NoError(t, err)
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var exported bytes.Buffer
+			n, err := Export(ctx, &exported, format, ExportOptions{Repository: source, BatchSize: 2})
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+
+			target := repository.NewMemoryRepository()
+			result, err := Import(ctx, &exported, format, importOptions(target))
+			require.NoError(t, err)
+			require.Empty(t, result.Errors)
+			require.Equal(t, 3, result.Imported)
+
+			want, err := source.GetUsers(ctx, repository.GetUsersInput{Limit: 10})
+			require.NoError(t, err)
+			got, err := target.GetUsers(ctx, repository.GetUsersInput{Limit: 10})
+			require.NoError(t, err)
+			require.ElementsMatch(t, secrets(want), secrets(got))
+		})
+	}
+}
+
+func TestExportCSVHeader(t *testing.T) {
+	var exported bytes.Buffer
+	n, err := Export(context.Background(), &exported, FormatCSV, ExportOptions{Repository: repository.NewMemoryRepository()})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	rows, err := csv.NewReader(&exported).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{exportColumns}, rows)
+}
+
+// secrets returns the phone numbers, emails, password hashes, email
+// verifications and suspensions of users, which survive an export and
+// import unlike their IDs.
+func secrets(users []repository.StoredUser) []string {
+	out := make([]string, len(users))
+	for i, user := range users {
+		email := ""
+		if user.Email != nil {
+			email = *user.Email
+		}
+		out[i] = strings.Join([]string{
+			user.PhoneNumber, email, user.Password, user.PasswordSalt,
+			timestamp(user.EmailVerifiedAt), timestamp(user.SuspendedAt),
+		}, " ")
+	}
+	return out
+}
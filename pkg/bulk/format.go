@@ -0,0 +1,215 @@
+// Package bulk imports users from CSV and JSONL files and exports them in
+// the same formats, e.g. to migrate them from another system.
+//
+// CSV files start with a header naming the columns, JSONL files hold one
+// JSON object per line. Import reads the columns, or fields, of Record and
+// ignores the others, so an export can be imported again with the email
+// verifications and suspensions of its users.
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of the files.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// maxLineSize bounds the length of a JSONL line.
+const maxLineSize = 1 << 20
+
+// Record is a user to import. Exactly one of Password and PasswordHash is
+// set.
+type Record struct {
+	PhoneNumber string  `json:"phoneNumber"`
+	FullName    string  `json:"fullName"`
+	Email       *string `json:"email,omitempty"`
+	// Password is a plain text password, it must meet the password policy.
+	Password string `json:"password,omitempty"`
+	// PasswordHash is a bcrypt hash of the password followed by
+	// PasswordSalt, see the supported formats of Import.
+	PasswordHash string `json:"passwordHash,omitempty"`
+	PasswordSalt string `json:"passwordSalt,omitempty"`
+	// EmailVerifiedAt marks Email as verified, it requires Email.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	// SuspendedAt imports the user suspended.
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
+}
+
+// CSV columns of Record.
+const (
+	columnPhoneNumber  = "phone_number"
+	columnFullName     = "full_name"
+	columnEmail        = "email"
+	columnPassword     = "password"
+	columnPasswordHash = "password_hash"
+	columnPasswordSalt = "password_salt"
+	// Timestamps are in RFC 3339.
+	columnEmailVerifiedAt = "email_verified_at"
+	columnSuspendedAt     = "suspended_at"
+)
+
+// RowError reports why a row was not imported.
+type RowError struct {
+	Line int
+	// Fields holds the messages by field name, the messages about the row
+	// as a whole are under "".
+	Fields map[string][]string
+}
+
+func rowError(line int, field, message string) *RowError {
+	return &RowError{Line: line, Fields: map[string][]string{field: {message}}}
+}
+
+func (e *RowError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var messages []string
+	for _, field := range fields {
+		for _, message := range e.Fields[field] {
+			if field != "" {
+				message = field + ": " + message
+			}
+			messages = append(messages, message)
+		}
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, strings.Join(messages, "; "))
+}
+
+// reader reads the records of a file.
+type reader interface {
+	// read returns the next record and the line it starts on, io.EOF at
+	// the end. A *RowError reports a malformed record, the following ones
+	// can still be read.
+	read() (Record, int, error)
+}
+
+func newReader(r io.Reader, format string) (reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineSize)
+		return &jsonlReader{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("reading CSV header: file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{columnPhoneNumber, columnFullName} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header has no %s column", name)
+		}
+	}
+	_, password := columns[columnPassword]
+	_, hash := columns[columnPasswordHash]
+	if !password && !hash {
+		return nil, fmt.Errorf("CSV header has no %s or %s column", columnPassword, columnPasswordHash)
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) read() (Record, int, error) {
+	row, err := r.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Record{}, parseErr.StartLine, rowError(parseErr.StartLine, "", parseErr.Err.Error())
+		}
+		return Record{}, 0, err
+	}
+	line, _ := r.r.FieldPos(0)
+	record := Record{
+		PhoneNumber:  r.value(row, columnPhoneNumber),
+		FullName:     r.value(row, columnFullName),
+		Password:     r.value(row, columnPassword),
+		PasswordHash: r.value(row, columnPasswordHash),
+		PasswordSalt: r.value(row, columnPasswordSalt),
+	}
+	if email := r.value(row, columnEmail); email != "" {
+		record.Email = &email
+	}
+	if record.EmailVerifiedAt, err = r.timestamp(row, columnEmailVerifiedAt); err != nil {
+		return Record{}, line, rowError(line, "emailVerifiedAt", "EmailVerifiedAt must be an RFC 3339 timestamp")
+	}
+	if record.SuspendedAt, err = r.timestamp(row, columnSuspendedAt); err != nil {
+		return Record{}, line, rowError(line, "suspendedAt", "SuspendedAt must be an RFC 3339 timestamp")
+	}
+	return record, line, nil
+}
+
+func (r *csvReader) value(row []string, column string) string {
+	i, ok := r.columns[column]
+	if !ok {
+		return ""
+	}
+	return row[i]
+}
+
+// timestamp parses the RFC 3339 timestamp of column, nil when it is empty.
+func (r *csvReader) timestamp(row []string, column string) (*time.Time, error) {
+	value := r.value(row, column)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) read() (Record, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return Record{}, r.line, rowError(r.line, "", "malformed JSON: "+err.Error())
+		}
+		return record, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, 0, fmt.Errorf("reading line %d: %w", r.line+1, err)
+	}
+	return Record{}, 0, io.EOF
+}
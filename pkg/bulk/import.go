@@ -0,0 +1,334 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"InterviewBackendSawitProGolang/pkg/phone"
+	"InterviewBackendSawitProGolang/pkg/validator"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/thanhpk/randstr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBatchSize is the number of users inserted at once when
+// ImportOptions.BatchSize is zero.
+const DefaultBatchSize = 500
+
+// maxSaltLength is the size of the users.password_salt column.
+const maxSaltLength = 15
+
+// hashPrefixes are the bcrypt versions accepted in PasswordHash. $2x$ marks
+// hashes of a broken implementation and is left out.
+var hashPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+// springPrefix marks the bcrypt hashes of Spring Security's
+// DelegatingPasswordEncoder.
+const springPrefix = "{bcrypt}"
+
+// errDryRun rolls back the batches of a dry run.
+var errDryRun = errors.New("dry run")
+
+type ImportOptions struct {
+	Repository repository.RepositoryInterface
+	// BcryptCost is the cost plain text passwords are hashed with.
+	BcryptCost int
+	// BatchSize is the number of users inserted by one statement.
+	BatchSize int
+	// DryRun checks every row and inserts the batches, but rolls them back.
+	DryRun bool
+}
+
+type ImportResult struct {
+	// Rows is the number of rows read.
+	Rows int
+	// Imported is the number of users inserted, or that would have been in
+	// a dry run.
+	Imported int
+	// Errors holds the rows that were skipped, in the order of the file.
+	Errors []*RowError
+}
+
+// pendingUser is a checked row waiting for its batch.
+type pendingUser struct {
+	line int
+	user repository.User
+	// hashed is false while user.Password is plain text.
+	hashed bool
+}
+
+// Import reads the users of a file in format and inserts them in batches.
+// Every row is checked like a registration: the phone number is normalized,
+// then the row goes through pkg/validator, plain text passwords included.
+// Rows that fail, or whose phone number or email is taken, are reported in
+// the result and skipped, the others are imported. An error is only
+// returned when the import could not go on, the batches before it stay
+// imported.
+//
+// PasswordHash takes a bcrypt hash ($2a$, $2b$ or $2y$, optionally with the
+// {bcrypt} prefix of Spring Security) of the password followed by
+// PasswordSalt, so hashes of other systems are imported with an empty salt.
+//
+// Emails are imported verified when EmailVerifiedAt is set, and users
+// suspended when SuspendedAt is. No outbox events are recorded, so webhook
+// subscribers are not told about imported users.
+func Import(ctx context.Context, r io.Reader, format string, opts ImportOptions) (result ImportResult, err error) {
+	records, err := newReader(r, format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	// The errors found while inserting a batch come after the rows read
+	// past it.
+	defer func() {
+		sort.SliceStable(result.Errors, func(i, j int) bool {
+			return result.Errors[i].Line < result.Errors[j].Line
+		})
+	}()
+	phoneNumbers := map[string]int{}
+	emails := map[string]int{}
+	batch := make([]pendingUser, 0, opts.BatchSize)
+	for {
+		record, line, err := records.read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			result.Rows++
+			result.Errors = append(result.Errors, rowErr)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Rows++
+
+		pending, rowErr := check(line, record)
+		if rowErr == nil {
+			rowErr = duplicate(pending, phoneNumbers, emails)
+		}
+		if rowErr != nil {
+			result.Errors = append(result.Errors, rowErr)
+			continue
+		}
+		batch = append(batch, pending)
+		if len(batch) == opts.BatchSize {
+			if err := importBatch(ctx, opts, batch, &result); err != nil {
+				return result, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := importBatch(ctx, opts, batch, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// check normalizes and validates a record.
+func check(line int, record Record) (pendingUser, *RowError) {
+	info := repository.UserInfo{
+		PhoneNumber:     phone.Canonical(record.PhoneNumber),
+		FullName:        record.FullName,
+		EmailVerifiedAt: record.EmailVerifiedAt,
+		SuspendedAt:     record.SuspendedAt,
+		Profile:         repository.Profile{Email: record.Email},
+	}
+	if record.Password != "" && record.PasswordHash != "" {
+		return pendingUser{}, rowError(line, "password", "only one of Password and PasswordHash may be set")
+	}
+	if record.EmailVerifiedAt != nil && record.Email == nil {
+		return pendingUser{}, rowError(line, "emailVerifiedAt", "EmailVerifiedAt requires Email")
+	}
+
+	if record.PasswordHash == "" {
+		user := repository.User{
+			UserInfo: info,
+			UserSecret: repository.UserSecret{
+				Password:     record.Password,
+				PasswordSalt: randstr.String(10),
+			},
+		}
+		if err := validate(line, user); err != nil {
+			return pendingUser{}, err
+		}
+		return pendingUser{line: line, user: user}, nil
+	}
+
+	rowErr := validate(line, info)
+	if rowErr == nil {
+		rowErr = &RowError{Line: line, Fields: map[string][]string{}}
+	}
+	hash := strings.TrimPrefix(record.PasswordHash, springPrefix)
+	if !isBcrypt(hash) {
+		rowErr.Fields["passwordHash"] = append(rowErr.Fields["passwordHash"], "PasswordHash must be a bcrypt hash")
+	}
+	if len(record.PasswordSalt) > maxSaltLength {
+		rowErr.Fields["passwordSalt"] = append(rowErr.Fields["passwordSalt"], fmt.Sprintf("PasswordSalt must be at most %d characters long", maxSaltLength))
+	}
+	if len(rowErr.Fields) > 0 {
+		return pendingUser{}, rowErr
+	}
+	return pendingUser{
+		line: line,
+		user: repository.User{
+			UserInfo: info,
+			UserSecret: repository.UserSecret{
+				Password:     hash,
+				PasswordSalt: record.PasswordSalt,
+			},
+		},
+		hashed: true,
+	}, nil
+}
+
+// validate runs pkg/validator, returning its field errors as a RowError.
+func validate(line int, input interface{}) *RowError {
+	err := validator.Validate(input)
+	if err == nil {
+		return nil
+	}
+	var fields map[string][]string
+	if json.Unmarshal([]byte(err.Error()), &fields) != nil {
+		return rowError(line, "", err.Error())
+	}
+	return &RowError{Line: line, Fields: fields}
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range hashPrefixes {
+		if strings.HasPrefix(hash, prefix) {
+			_, err := bcrypt.Cost([]byte(hash))
+			return err == nil
+		}
+	}
+	return false
+}
+
+// duplicate reports a phone number or email used by an earlier row of the
+// file, which the database would only report for the whole batch.
+func duplicate(pending pendingUser, phoneNumbers, emails map[string]int) *RowError {
+	if line, ok := phoneNumbers[pending.user.PhoneNumber]; ok {
+		return rowError(pending.line, "phoneNumber", fmt.Sprintf("phone number is also on line %d", line))
+	}
+	if pending.user.Email != nil {
+		email := strings.ToLower(*pending.user.Email)
+		if line, ok := emails[email]; ok {
+			return rowError(pending.line, "email", fmt.Sprintf("email is also on line %d", line))
+		}
+		emails[email] = pending.line
+	}
+	phoneNumbers[pending.user.PhoneNumber] = pending.line
+	return nil
+}
+
+// importBatch hashes the plain text passwords of batch and inserts it. When
+// a user of the batch conflicts with a stored one, its users are inserted
+// one by one to find out which.
+func importBatch(ctx context.Context, opts ImportOptions, batch []pendingUser, result *ImportResult) error {
+	if err := hashPasswords(batch, opts); err != nil {
+		return err
+	}
+	users := make([]repository.User, len(batch))
+	for i, pending := range batch {
+		users[i] = pending.user
+	}
+	err := insert(ctx, opts, users)
+	if err == nil {
+		result.Imported += len(batch)
+		return nil
+	}
+	if !errors.Is(err, repository.ErrConflict) {
+		return fmt.Errorf("importing lines %d to %d: %w", batch[0].line, batch[len(batch)-1].line, err)
+	}
+
+	for _, pending := range batch {
+		err := insert(ctx, opts, []repository.User{pending.user})
+		switch {
+		case err == nil:
+			result.Imported++
+		case errors.Is(err, repository.ErrPhoneNumberTaken):
+			result.Errors = append(result.Errors, rowError(pending.line, "phoneNumber", "phone number already exists"))
+		case errors.Is(err, repository.ErrEmailTaken):
+			result.Errors = append(result.Errors, rowError(pending.line, "email", "email already exists"))
+		case errors.Is(err, repository.ErrConflict):
+			result.Errors = append(result.Errors, rowError(pending.line, "", "user already exists"))
+		default:
+			return fmt.Errorf("importing line %d: %w", pending.line, err)
+		}
+	}
+	return nil
+}
+
+// insert inserts users in one transaction, rolling it back in a dry run.
+func insert(ctx context.Context, opts ImportOptions, users []repository.User) error {
+	err := opts.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		if _, err := repo.InsertUsers(ctx, repository.InsertUsersInput{Users: users}); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// hashPasswords hashes the plain text passwords of batch on every CPU. A
+// dry run stores a placeholder instead, as nothing is kept.
+func hashPasswords(batch []pendingUser, opts ImportOptions) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	next := make(chan *pendingUser)
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pending := range next {
+				if opts.DryRun {
+					pending.user.Password = "dry run"
+					continue
+				}
+				hash, err := bcrypt.GenerateFromPassword([]byte(pending.user.Password+pending.user.PasswordSalt), opts.BcryptCost)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("hashing password of line %d: %w", pending.line, err)
+					}
+					mu.Unlock()
+					continue
+				}
+				pending.user.Password = string(hash)
+			}
+		}()
+	}
+	for i := range batch {
+		if !batch[i].hashed {
+			batch[i].hashed = true
+			next <- &batch[i]
+		}
+	}
+	close(next)
+	wg.Wait()
+	return firstErr
+}
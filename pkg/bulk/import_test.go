@@ -0,0 +1,120 @@
+package bulk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func importOptions(repo repository.RepositoryInterface) ImportOptions {
+	return ImportOptions{Repository: repo, BcryptCost: bcrypt.MinCost, BatchSize: 2}
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	hash, err := bcrypt.GenerateFromPassword([]byte("legacy-secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	file := "phone_number,full_name,email,password,password_hash,suspended_at,extra\n" +
+		"08123456789,Alice Doe,alice@example.com,Str0ng!Pass,,,ignored\n" +
+		"+628129876543,Bob Doe,,,{bcrypt}" + string(hash) + ",2024-01-02T03:04:05Z,ignored\n" +
+		"08111111111,Carol Doe,,weak,,,ignored\n" +
+		"08122222222,Dan Doe,,Str0ng!Pass,,yesterday,ignored\n"
+	result, err := Import(ctx, strings.NewReader(file), FormatCSV, importOptions(repo))
+	require.NoError(t, err)
+	require.Equal(t, 4, result.Rows)
+	require.Equal(t, 2, result.Imported)
+	require.Len(t, result.Errors, 2)
+	require.Equal(t, 4, result.Errors[0].Line)
+	require.Contains(t, result.Errors[0].Fields, "password")
+	require.Equal(t, 5, result.Errors[1].Line)
+	require.Contains(t, result.Errors[1].Fields, "suspendedAt")
+
+	alice, err := repo.GetUserByLoginIdentifier(ctx, repository.GetUserByLoginIdentifierInput{
+		Type:  repository.LoginIdentifierPhoneNumber,
+		Value: "+628123456789",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Alice Doe", alice.FullName)
+	require.Nil(t, alice.EmailVerifiedAt)
+	require.Nil(t, alice.SuspendedAt)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(alice.Password), []byte("Str0ng!Pass"+alice.PasswordSalt)))
+
+	bob, err := repo.GetUserByLoginIdentifier(ctx, repository.GetUserByLoginIdentifierInput{
+		Type:  repository.LoginIdentifierPhoneNumber,
+		Value: "+628129876543",
+	})
+	require.NoError(t, err)
+	require.Empty(t, bob.PasswordSalt)
+	require.NotNil(t, bob.SuspendedAt)
+	require.True(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Equal(*bob.SuspendedAt))
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(bob.Password), []byte("legacy-secret")))
+}
+
+func TestImportJSONLReportsRowErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	_, err := repo.InsertUser(ctx, repository.User{
+		UserInfo:   repository.UserInfo{PhoneNumber: "+628111111111", FullName: "Taken"},
+		UserSecret: repository.UserSecret{Password: "hash"},
+	})
+	require.NoError(t, err)
+
+	file := `{"phoneNumber":"08123456789","fullName":"Alice Doe","password":"Str0ng!Pass"}
+{"phoneNumber":"08111111111","fullName":"Taken Again","password":"Str0ng!Pass"}
+
+not json
+{"phoneNumber":"+628123456789","fullName":"Alice Again","password":"Str0ng!Pass"}
+{"phoneNumber":"08122222222","fullName":"Dan Doe","passwordHash":"md5:abc"}
+{"phoneNumber":"08133333333","fullName":"Eve Doe","password":"Str0ng!Pass","passwordHash":"$2a$04$abc"}
+{"phoneNumber":"08144444444","fullName":"Fay Doe","password":"Str0ng!Pass"}
+{"phoneNumber":"08155555555","fullName":"Gus Doe","password":"Str0ng!Pass","emailVerifiedAt":"2024-01-02T03:04:05Z"}
+`
+	result, err := Import(ctx, strings.NewReader(file), FormatJSONL, importOptions(repo))
+	require.NoError(t, err)
+	require.Equal(t, 8, result.Rows)
+	require.Equal(t, 2, result.Imported)
+
+	lines := make([]int, len(result.Errors))
+	for i, rowErr := range result.Errors {
+		lines[i] = rowErr.Line
+	}
+	require.Equal(t, []int{2, 4, 5, 6, 7, 9}, lines)
+	require.Equal(t, []string{"phone number already exists"}, result.Errors[0].Fields["phoneNumber"])
+	require.Equal(t, []string{"phone number is also on line 1"}, result.Errors[2].Fields["phoneNumber"])
+	require.Contains(t, result.Errors[3].Fields, "passwordHash")
+	require.Contains(t, result.Errors[4].Fields, "password")
+	require.Contains(t, result.Errors[5].Fields, "emailVerifiedAt")
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	opts := importOptions(repo)
+	opts.DryRun = true
+
+	file := "phone_number,full_name,password\n" +
+		"08123456789,Alice Doe,Str0ng!Pass\n" +
+		"08129876543,Bob Doe,Str0ng!Pass\n" +
+		"08111111111,Carol Doe,Str0ng!Pass\n"
+	result, err := Import(ctx, strings.NewReader(file), FormatCSV, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Imported)
+	require.Empty(t, result.Errors)
+
+	users, err := repo.GetUsers(ctx, repository.GetUsersInput{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
+
+func TestImportRejectsBadHeader(t *testing.T) {
+	_, err := Import(context.Background(), strings.NewReader("phone_number,full_name\n"), FormatCSV, importOptions(repository.NewMemoryRepository()))
+	require.EqualError(t, err, "CSV header has no password or password_hash column")
+}
@@ -0,0 +1,17 @@
+// Package cache stores short lived values in process or in a server
+// speaking the Redis protocol.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values by key until their time to live passes. A cache may
+// drop values earlier, callers have to be able to recompute them.
+type Cache interface {
+	// Get returns the value of key, ok is false when there is none.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
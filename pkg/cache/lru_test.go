@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = c.Get(ctx, "b")
+	require.False(t, ok)
+	value, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), value)
+	require.Equal(t, 2, c.Len())
+
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, ok, _ = c.Get(ctx, "a")
+	require.False(t, ok)
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+
+	now = now.Add(59 * time.Second)
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	now = now.Add(time.Second)
+	_, ok, _ = c.Get(ctx, "a")
+	require.False(t, ok)
+	require.Zero(t, c.Len())
+}
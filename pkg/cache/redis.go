@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultRedisTimeout bounds dialing and every command when
+// RedisOptions.Timeout is not set.
+const DefaultRedisTimeout = time.Second
+
+type RedisOptions struct {
+	// Addr is the host:port of the server.
+	Addr     string
+	Password string
+	DB       int
+	Timeout  time.Duration
+	// MaxIdleConns is how many connections are kept open between commands.
+	MaxIdleConns int
+}
+
+// Redis is a Cache stored in a server speaking the Redis protocol, e.g.
+// Redis or Valkey, so that several instances share it.
+type Redis struct {
+	opts RedisOptions
+	idle chan *redisConn
+}
+
+var _ Cache = (*Redis)(nil)
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis returns a client of the server at opts.Addr. It connects on the
+// first command.
+func NewRedis(opts RedisOptions) *Redis {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultRedisTimeout
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 8
+	}
+	return &Redis{opts: opts, idle: make(chan *redisConn, opts.MaxIdleConns)}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Ping checks that the server answers, it satisfies health.Checker.
+func (c *Redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections.
+func (c *Redis) Close() error {
+	var errs []error
+	for {
+		select {
+		case conn := <-c.idle:
+			errs = append(errs, conn.Close())
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// do sends a command and returns its reply: nil, a string, an int64, a
+// []byte or a []interface{} of those.
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, c.opts.Timeout, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection may be halfway through a reply.
+		conn.Close()
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one.
+func (c *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.opts.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+	if c.opts.Password != "" {
+		if _, err := conn.do(ctx, c.opts.Timeout, []string{"AUTH", c.opts.Password}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := conn.do(ctx, c.opts.Timeout, []string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis SELECT: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (conn *redisConn) do(ctx context.Context, timeout time.Duration, args []string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// Commands are sent as an array of bulk strings.
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(conn.r)
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				// Keep reading so the connection stays usable.
+				item, err = replyErr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("malformed reply %q", line)
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves GET, SET, DEL, AUTH and PING from a map.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string]string
+	commands [][]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeRedis{values: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		var out string
+		switch args[0] {
+		case "PING", "AUTH":
+			out = "+OK\r\n"
+		case "SET":
+			s.values[args[1]] = args[2]
+			out = "+OK\r\n"
+		case "GET":
+			if value, ok := s.values[args[1]]; ok {
+				out = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+			} else {
+				out = "$-1\r\n"
+			}
+		case "DEL":
+			for _, key := range args[1:] {
+				delete(s.values, key)
+			}
+			out = ":" + strconv.Itoa(len(args)-1) + "\r\n"
+		default:
+			out = "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		s.mu.Unlock()
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	server, addr := startFakeRedis(t)
+	c := NewRedis(RedisOptions{Addr: addr, Password: "secret"})
+	defer c.Close()
+
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("line\r\nbreak"), 1500*time.Millisecond))
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("line\r\nbreak"), value)
+
+	require.NoError(t, c.Delete(ctx, "a", "b"))
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.NoError(t, c.Ping(ctx))
+
+	// One connection is authenticated once and reused.
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Equal(t, []string{"AUTH", "secret"}, server.commands[0])
+	require.Equal(t, []string{"SET", "a", "line\r\nbreak", "PX", "1500"}, server.commands[2])
+	require.Len(t, server.commands, 7)
+}
+
+func TestRedisErrorReply(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	c := NewRedis(RedisOptions{Addr: addr, DB: 1})
+	defer c.Close()
+
+	err := c.Ping(context.Background())
+	require.ErrorContains(t, err, "redis SELECT: redis: ERR unknown command 'SELECT'")
+}
+
+func TestRedisUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, _, err = NewRedis(RedisOptions{Addr: addr}).Get(context.Background(), "a")
+	require.Error(t, err)
+}
@@ -0,0 +1,455 @@
+// Package config holds the typed service configuration. Values are resolved
+// from, in increasing order of precedence, the defaults, a YAML file, the
+// environment and command line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/cache"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/logging"
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/pkg/password"
+	"InterviewBackendSawitProGolang/pkg/tracing"
+	"InterviewBackendSawitProGolang/pkg/webhook"
+	"InterviewBackendSawitProGolang/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Fields are addressed by their YAML path, e.g. "server.listen_addr", which
+// is also the name of their flag. Fields with an env tag can be set from
+// that environment variable and fields tagged secret are redacted when the
+// configuration is printed.
+type Config struct {
+	Server   ServerConfig    `yaml:"server"`
+	GRPC     GRPCConfig      `yaml:"grpc"`
+	Database DatabaseConfig  `yaml:"database"`
+	Cache    CacheConfig     `yaml:"cache"`
+	Outbox   OutboxConfig    `yaml:"outbox"`
+	Webhook  WebhookConfig   `yaml:"webhook"`
+	Admin    AdminConfig     `yaml:"admin"`
+	Auth     AuthConfig      `yaml:"auth"`
+	Password password.Policy `yaml:"password"`
+	Email    EmailConfig     `yaml:"email"`
+	Storage  StorageConfig   `yaml:"storage"`
+	Tracing  TracingConfig   `yaml:"tracing"`
+	Log      LogConfig       `yaml:"log"`
+}
+
+type ServerConfig struct {
+	ListenAddr string `yaml:"listen_addr" env:"LISTEN_ADDR"`
+	// PublicURL is the externally reachable base URL used in links.
+	PublicURL string `yaml:"public_url" env:"PUBLIC_URL"`
+	BodyLimit string `yaml:"body_limit" env:"BODY_LIMIT"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on
+	// SIGTERM before the server exits anyway.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// ReadinessTimeout bounds each /readyz check.
+	ReadinessTimeout time.Duration `yaml:"readiness_timeout" env:"READINESS_TIMEOUT"`
+}
+
+type GRPCConfig struct {
+	// ListenAddr is where the gRPC API is served, it is disabled when
+	// empty.
+	ListenAddr string `yaml:"listen_addr" env:"GRPC_LISTEN_ADDR"`
+	// InternalToken authenticates the internal GetUser method in the
+	// x-internal-token metadata, it is refused when empty.
+	InternalToken string `yaml:"internal_token" env:"GRPC_INTERNAL_TOKEN" secret:"true"`
+}
+
+type DatabaseConfig struct {
+	// Driver is postgres, sqlite or memory. When empty it is picked by the
+	// scheme of URL, "sqlite:path" and "memory:" select those and anything
+	// else is a Postgres DSN.
+	Driver string `yaml:"driver" env:"DATABASE_DRIVER"`
+	URL    string `yaml:"url" env:"DATABASE_URL" secret:"true"`
+	// ReplicaURLs are Postgres read replicas of URL that serve the reads.
+	ReplicaURLs []string `yaml:"replica_urls" env:"DATABASE_REPLICA_URLS" secret:"true"`
+	// ReplicaCheckInterval is how often replicas are pinged.
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DATABASE_REPLICA_CHECK_INTERVAL"`
+	MaxOpenConns         int           `yaml:"max_open_conns" env:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns         int           `yaml:"max_idle_conns" env:"DATABASE_MAX_IDLE_CONNS"`
+	ConnMaxLifetime      time.Duration `yaml:"conn_max_lifetime" env:"DATABASE_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime      time.Duration `yaml:"conn_max_idle_time" env:"DATABASE_CONN_MAX_IDLE_TIME"`
+	// ConnectTimeout bounds how long startup waits for the database to
+	// accept connections.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DATABASE_CONNECT_TIMEOUT"`
+}
+
+// Cache backends.
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+type CacheConfig struct {
+	// Backend is none, memory for a cache per instance or redis for one
+	// shared by all instances.
+	Backend string        `yaml:"backend" env:"CACHE_BACKEND"`
+	TTL     time.Duration `yaml:"ttl" env:"CACHE_TTL"`
+	// Size is how many users the memory backend holds.
+	Size          int    `yaml:"size" env:"CACHE_SIZE"`
+	RedisAddr     string `yaml:"redis_addr" env:"CACHE_REDIS_ADDR"`
+	RedisPassword string `yaml:"redis_password" env:"CACHE_REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `yaml:"redis_db" env:"CACHE_REDIS_DB"`
+}
+
+// Outbox sinks.
+const (
+	OutboxSinkNone = "none"
+	OutboxSinkLog  = "log"
+)
+
+type OutboxConfig struct {
+	// Sink is log to log the events, or none to leave them in the
+	// outbox_events table for another consumer. Enabling webhooks adds
+	// them as a sink.
+	Sink         string        `yaml:"sink" env:"OUTBOX_SINK"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+}
+
+type WebhookConfig struct {
+	// Enabled queues the events for the registered webhooks and delivers
+	// them.
+	Enabled bool `yaml:"enabled" env:"WEBHOOK_ENABLED"`
+	// Timeout bounds a delivery request.
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL"`
+	// MaxAttempts is the number of failed attempts after which a delivery
+	// is dead.
+	MaxAttempts int `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	// MinBackoff and MaxBackoff bound the delay between attempts.
+	MinBackoff time.Duration `yaml:"min_backoff" env:"WEBHOOK_MIN_BACKOFF"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF"`
+}
+
+type AdminConfig struct {
+	// Token authenticates the /admin endpoints in the X-Admin-Token
+	// header, they are disabled when it is empty.
+	Token string `yaml:"token" env:"ADMIN_TOKEN" secret:"true"`
+}
+
+type AuthConfig struct {
+	// PrivateKeyFile is read into PrivateKey when set.
+	PrivateKeyFile string `yaml:"private_key_file" env:"AUTH_PRIVATE_KEY_FILE"`
+	PrivateKey     string `yaml:"private_key" env:"AUTH_PRIVATE_KEY" secret:"true"`
+	KeyID          string `yaml:"key_id" env:"AUTH_KEY_ID"`
+	Issuer         string `yaml:"issuer" env:"AUTH_ISSUER"`
+	Audience       string `yaml:"audience" env:"AUTH_AUDIENCE"`
+	BcryptCost     int    `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+	// TokenTTL is how long the tokens handed out on login are valid.
+	TokenTTL time.Duration `yaml:"token_ttl" env:"AUTH_TOKEN_TTL"`
+	// IntrospectionClients lists the "client_id:secret" credentials of the
+	// services allowed to call /oauth/introspect, it refuses every call
+	// when empty.
+	IntrospectionClients []string `yaml:"introspection_clients" env:"AUTH_INTROSPECTION_CLIENTS" secret:"true"`
+}
+
+type EmailConfig struct {
+	// LinkSigningKey signs verification links, a random key is used when
+	// empty so links do not survive a restart.
+	LinkSigningKey string `yaml:"link_signing_key" env:"LINK_SIGNING_KEY" secret:"true"`
+	// SMTPAddr is the relay host:port, emails are logged when empty.
+	SMTPAddr     string `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPFrom     string `yaml:"smtp_from" env:"SMTP_FROM"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type StorageConfig struct {
+	BlobDir string `yaml:"blob_dir" env:"BLOB_DIR"`
+}
+
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp.
+	Exporter    string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	// Endpoint is the OTLP/HTTP collector host:port, the
+	// OTEL_EXPORTER_OTLP_* variables apply when empty.
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	// Format is json or console.
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			ListenAddr:       ":1323",
+			PublicURL:        "http://localhost:8080",
+			BodyLimit:        "6M",
+			ShutdownTimeout:  15 * time.Second,
+			ReadinessTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+
+			ReplicaCheckInterval: repository.DefaultReplicaCheckInterval,
+		},
+		Cache: CacheConfig{
+			Backend: CacheNone,
+			TTL:     time.Minute,
+			Size:    10000,
+		},
+		Outbox: OutboxConfig{
+			Sink:         OutboxSinkLog,
+			PollInterval: outbox.DefaultPollInterval,
+			BatchSize:    outbox.DefaultBatchSize,
+		},
+		Webhook: WebhookConfig{
+			Timeout:      webhook.DefaultTimeout,
+			PollInterval: webhook.DefaultPollInterval,
+			MaxAttempts:  webhook.DefaultMaxAttempts,
+			MinBackoff:   webhook.DefaultMinBackoff,
+			MaxBackoff:   webhook.DefaultMaxBackoff,
+		},
+		Auth: AuthConfig{
+			PrivateKey: jwt.DevelopmentPrivateKey,
+			KeyID:      jwt.DefaultKeyID,
+			Issuer:     jwt.DefaultIssuer,
+			Audience:   jwt.DefaultAudience,
+			BcryptCost: bcrypt.DefaultCost,
+			TokenTTL:   jwt.DefaultTokenTTL,
+		},
+		Password: password.DefaultPolicy(),
+		Storage: StorageConfig{
+			BlobDir: "data/blobs",
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "user-service",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+	}
+}
+
+// UsesDevelopmentKey reports whether tokens are signed with the key checked
+// into the repository.
+func (c *Config) UsesDevelopmentKey() bool {
+	return c.Auth.PrivateKey == jwt.DevelopmentPrivateKey
+}
+
+// Validate checks the configuration and prepares derived values such as the
+// password policy. It returns every problem found.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.ListenAddr == "" {
+		errs = append(errs, errors.New("server.listen_addr is required"))
+	}
+	if u, err := url.Parse(c.Server.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("server.public_url %q must be an absolute URL", c.Server.PublicURL))
+	}
+	if c.GRPC.ListenAddr != "" && c.GRPC.ListenAddr == c.Server.ListenAddr {
+		errs = append(errs, errors.New("grpc.listen_addr must differ from server.listen_addr"))
+	}
+	if c.Server.ShutdownTimeout <= 0 || c.Server.ReadinessTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout and server.readiness_timeout must be positive"))
+	}
+	switch c.DatabaseDriver() {
+	case repository.DriverPostgres, repository.DriverSQLite:
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("database.url is required"))
+		}
+	case repository.DriverMemory:
+	default:
+		errs = append(errs, fmt.Errorf("database.driver %q must be postgres, sqlite or memory", c.Database.Driver))
+	}
+	if len(c.Database.ReplicaURLs) > 0 && c.DatabaseDriver() != repository.DriverPostgres {
+		errs = append(errs, errors.New("database.replica_urls requires the postgres driver"))
+	}
+	if c.Database.ReplicaCheckInterval <= 0 {
+		errs = append(errs, errors.New("database.replica_check_interval must be positive"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("database pool settings must not be negative"))
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("database.connect_timeout must be positive"))
+	}
+	switch c.Cache.Backend {
+	case CacheNone, CacheMemory:
+	case CacheRedis:
+		if c.Cache.RedisAddr == "" {
+			errs = append(errs, errors.New("cache.redis_addr is required for the redis backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend %q must be none, memory or redis", c.Cache.Backend))
+	}
+	if c.Cache.Backend != CacheNone && (c.Cache.TTL <= 0 || c.Cache.Size <= 0) {
+		errs = append(errs, errors.New("cache.ttl and cache.size must be positive"))
+	}
+	switch c.Outbox.Sink {
+	case OutboxSinkNone, OutboxSinkLog:
+	default:
+		errs = append(errs, fmt.Errorf("outbox.sink %q must be none or log", c.Outbox.Sink))
+	}
+	if c.Outbox.PollInterval <= 0 || c.Outbox.BatchSize <= 0 {
+		errs = append(errs, errors.New("outbox.poll_interval and outbox.batch_size must be positive"))
+	}
+	if c.Webhook.Enabled {
+		if c.Webhook.Timeout <= 0 || c.Webhook.PollInterval <= 0 || c.Webhook.MaxAttempts <= 0 {
+			errs = append(errs, errors.New("webhook.timeout, webhook.poll_interval and webhook.max_attempts must be positive"))
+		}
+		if c.Webhook.MinBackoff <= 0 || c.Webhook.MaxBackoff < c.Webhook.MinBackoff {
+			errs = append(errs, errors.New("webhook.min_backoff must be positive and at most webhook.max_backoff"))
+		}
+	}
+
+	if c.Auth.PrivateKeyFile != "" {
+		key, err := os.ReadFile(c.Auth.PrivateKeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("auth.private_key_file: %w", err))
+		} else {
+			c.Auth.PrivateKey = string(key)
+		}
+	}
+	if _, err := jwt.NewSigner(c.SignerOptions()); err != nil {
+		errs = append(errs, fmt.Errorf("auth.private_key: %w", err))
+	}
+	if c.Auth.KeyID == "" || c.Auth.Issuer == "" || c.Auth.Audience == "" {
+		errs = append(errs, errors.New("auth.key_id, auth.issuer and auth.audience are required"))
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.token_ttl must be positive"))
+	}
+	clients := map[string]bool{}
+	for i, client := range c.Auth.IntrospectionClients {
+		id, secret, _ := strings.Cut(client, ":")
+		switch {
+		case id == "" || secret == "":
+			errs = append(errs, fmt.Errorf("auth.introspection_clients[%d] must be client_id:secret", i))
+		case clients[id]:
+			errs = append(errs, fmt.Errorf("auth.introspection_clients has client %q twice", id))
+		}
+		clients[id] = true
+	}
+
+	if err := c.Password.Init(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Email.SMTPAddr != "" && c.Email.SMTPFrom == "" {
+		errs = append(errs, errors.New("email.smtp_from is required when email.smtp_addr is set"))
+	}
+	if c.Storage.BlobDir == "" {
+		errs = append(errs, errors.New("storage.blob_dir is required"))
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be none, stdout or otlp", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if _, err := logging.New(c.LogOptions(), io.Discard); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) SignerOptions() jwt.SignerOptions {
+	return jwt.SignerOptions{
+		PrivateKey: c.Auth.PrivateKey,
+		KeyID:      c.Auth.KeyID,
+		Issuer:     c.Auth.Issuer,
+		Audience:   c.Auth.Audience,
+	}
+}
+
+// IntrospectionClients returns the secrets of auth.introspection_clients by
+// client ID.
+func (c *Config) IntrospectionClients() map[string]string {
+	clients := make(map[string]string, len(c.Auth.IntrospectionClients))
+	for _, client := range c.Auth.IntrospectionClients {
+		id, secret, _ := strings.Cut(client, ":")
+		clients[id] = secret
+	}
+	return clients
+}
+
+func (c *Config) RepositoryOptions() repository.NewRepositoryOptions {
+	return repository.NewRepositoryOptions{
+		Dsn:                  c.Database.URL,
+		ReplicaDsns:          c.Database.ReplicaURLs,
+		ReplicaCheckInterval: c.Database.ReplicaCheckInterval,
+		Pool: repository.PoolOptions{
+			MaxOpenConns:    c.Database.MaxOpenConns,
+			MaxIdleConns:    c.Database.MaxIdleConns,
+			ConnMaxLifetime: c.Database.ConnMaxLifetime,
+			ConnMaxIdleTime: c.Database.ConnMaxIdleTime,
+		},
+	}
+}
+
+// DatabaseDriver returns database.driver, or the driver picked by the scheme
+// of database.url when it is not set.
+func (c *Config) DatabaseDriver() string {
+	if c.Database.Driver != "" {
+		return c.Database.Driver
+	}
+	scheme, _, _ := strings.Cut(c.Database.URL, ":")
+	switch scheme {
+	case repository.DriverSQLite, repository.DriverMemory:
+		return scheme
+	}
+	return repository.DriverPostgres
+}
+
+// SQLitePath returns the database file of a "sqlite:path" or
+// "sqlite://path" database.url.
+func (c *Config) SQLitePath() string {
+	path := strings.TrimPrefix(c.Database.URL, repository.DriverSQLite+":")
+	return strings.TrimPrefix(path, "//")
+}
+
+func (c *Config) RedisOptions() cache.RedisOptions {
+	return cache.RedisOptions{
+		Addr:     c.Cache.RedisAddr,
+		Password: c.Cache.RedisPassword,
+		DB:       c.Cache.RedisDB,
+	}
+}
+
+func (c *Config) TracingOptions() tracing.Options {
+	return tracing.Options{
+		ServiceName: c.Tracing.ServiceName,
+		Exporter:    c.Tracing.Exporter,
+		Endpoint:    c.Tracing.Endpoint,
+		Insecure:    c.Tracing.Insecure,
+		SampleRatio: c.Tracing.SampleRatio,
+	}
+}
+
+func (c *Config) LogOptions() logging.Options {
+	return logging.Options{
+		Level:  c.Log.Level,
+		Format: c.Log.Format,
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/stretchr/testify/require"
+)
+
+func load(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	require.NoError(t, fs.Parse(args))
+	return loader.Load()
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `
+server:
+  listen_addr: ":9000"
+  public_url: https://file.example.com
+database:
+  url: postgres://file
+password:
+  min_length: 10
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv(FileEnv, path)
+	t.Setenv("PUBLIC_URL", "https://env.example.com")
+	t.Setenv("DATABASE_URL", "postgres://env")
+
+	cfg, err := load(t, "-database.url", "postgres://flag", "-password.require_special=false")
+	require.NoError(t, err)
+	require.Equal(t, ":9000", cfg.Server.ListenAddr)
+	require.Equal(t, "https://env.example.com", cfg.Server.PublicURL)
+	require.Equal(t, "postgres://flag", cfg.Database.URL)
+	require.Equal(t, 10, cfg.Password.MinLength)
+	require.False(t, cfg.Password.RequireSpecial)
+	require.True(t, cfg.Password.RequireDigit)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := load(t, "-config", filepath.Join(t.TempDir(), "missing.yml"))
+	require.Error(t, err)
+
+	_, err = load(t, "-auth.bcrypt_cost", "high")
+	require.ErrorContains(t, err, "-auth.bcrypt_cost")
+
+	t.Setenv("BCRYPT_COST", "high")
+	_, err = load(t)
+	require.ErrorContains(t, err, "BCRYPT_COST")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Server.PublicURL = "localhost"
+	cfg.Auth.PrivateKey = "not a key"
+	cfg.Auth.BcryptCost = 100
+	cfg.Auth.TokenTTL = 0
+	cfg.Password.MaxLength = 1
+	cfg.Email.SMTPAddr = "smtp:25"
+
+	err := cfg.Validate()
+	for _, want := range []string{"server.public_url", "database.url", "auth.private_key", "auth.bcrypt_cost", "auth.token_ttl", "password policy", "email.smtp_from"} {
+		require.ErrorContains(t, err, want)
+	}
+}
+
+func TestDatabaseDriver(t *testing.T) {
+	tests := []struct {
+		driver, url    string
+		want, wantPath string
+	}{
+		{"", "postgres://user@db/users", repository.DriverPostgres, ""},
+		{"", "host=db user=postgres", repository.DriverPostgres, ""},
+		{"", "sqlite:data/users.db", repository.DriverSQLite, "data/users.db"},
+		{"", "sqlite:///var/lib/users.db", repository.DriverSQLite, "/var/lib/users.db"},
+		{"", "memory:", repository.DriverMemory, ""},
+		{repository.DriverSQLite, "users.db", repository.DriverSQLite, "users.db"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Database.Driver = tt.driver
+		cfg.Database.URL = tt.url
+		require.Equal(t, tt.want, cfg.DatabaseDriver(), tt.url)
+		if tt.wantPath != "" {
+			require.Equal(t, tt.wantPath, cfg.SQLitePath(), tt.url)
+		}
+	}
+
+	cfg := Default()
+	cfg.Database.Driver = repository.DriverMemory
+	require.NoError(t, cfg.Validate())
+	cfg.Database.Driver = "mysql"
+	require.ErrorContains(t, cfg.Validate(), "database.driver")
+}
+
+func TestReplicaURLs(t *testing.T) {
+	t.Setenv("DATABASE_REPLICA_URLS", "postgres://replica-1/users, postgres://replica-2/users")
+	cfg, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load()
+	require.NoError(t, err)
+	cfg.Database.URL = "postgres://primary/users"
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, []string{"postgres://replica-1/users", "postgres://replica-2/users"}, cfg.RepositoryOptions().ReplicaDsns)
+	require.Contains(t, cfg.String(), "replica_urls:\n        - '[redacted]'")
+	require.Equal(t, "postgres://replica-1/users", cfg.Database.ReplicaURLs[0])
+
+	cfg.Database.URL = "sqlite:users.db"
+	require.ErrorContains(t, cfg.Validate(), "database.replica_urls")
+}
+
+func TestCacheBackend(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://primary/users"
+	cfg.Cache.Backend = CacheRedis
+	require.ErrorContains(t, cfg.Validate(), "cache.redis_addr")
+	cfg.Cache.RedisAddr = "localhost:6379"
+	require.NoError(t, cfg.Validate())
+	cfg.Cache.Backend = "memcached"
+	require.ErrorContains(t, cfg.Validate(), "cache.backend")
+}
+
+func TestWebhook(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://primary/users"
+	cfg.Webhook.Enabled = true
+	require.NoError(t, cfg.Validate())
+	cfg.Webhook.MaxBackoff = cfg.Webhook.MinBackoff / 2
+	require.ErrorContains(t, cfg.Validate(), "webhook.min_backoff")
+}
+
+func TestGRPC(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://primary/users"
+	cfg.GRPC.ListenAddr = ":9090"
+	require.NoError(t, cfg.Validate())
+	cfg.GRPC.ListenAddr = cfg.Server.ListenAddr
+	require.ErrorContains(t, cfg.Validate(), "grpc.listen_addr")
+}
+
+func TestIntrospectionClients(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://primary/users"
+	cfg.Auth.IntrospectionClients = []string{"orders:s3cret", "billing:pa:ss"}
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, map[string]string{"orders": "s3cret", "billing": "pa:ss"}, cfg.IntrospectionClients())
+
+	cfg.Auth.IntrospectionClients = []string{"orders"}
+	require.ErrorContains(t, cfg.Validate(), "auth.introspection_clients[0]")
+	cfg.Auth.IntrospectionClients = []string{"orders:a", "orders:b"}
+	require.ErrorContains(t, cfg.Validate(), `client "orders" twice`)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://user:secret@db"
+
+	out := cfg.String()
+	require.NotContains(t, out, "secret@db")
+	require.NotContains(t, out, "PRIVATE KEY")
+	require.Contains(t, out, "url: '[redacted]'")
+	require.Contains(t, out, `smtp_password: ""`)
+	require.Equal(t, "postgres://user:secret@db", cfg.Database.URL)
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the config file path when
+// the -config flag is not given.
+const FileEnv = "CONFIG_FILE"
+
+const redacted = "[redacted]"
+
+// Loader resolves a Config. It registers -config and one flag per field on
+// a flag set so every command accepts the same overrides.
+type Loader struct {
+	file  *string
+	flags map[string]string
+}
+
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{flags: map[string]string{}}
+	l.file = fs.String("config", "", "path of the YAML config file (env "+FileEnv+")")
+
+	defaults := Default()
+	for _, f := range fields(reflect.ValueOf(&defaults).Elem(), "") {
+		key := f.key
+		usage := "overrides " + key
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		if f.secret {
+			usage += ", prefer the config file or env for secrets"
+		}
+		fs.Func(key, usage, func(s string) error {
+			l.flags[key] = s
+			return nil
+		})
+	}
+	return l
+}
+
+// Load resolves the configuration. It does not validate it, call
+// Config.Validate for that.
+func (l *Loader) Load() (Config, error) {
+	cfg := Default()
+
+	path := *l.file
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config: %w", err)
+		}
+		if err := yaml.Unmarshal(content, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	}
+
+	for _, f := range fields(reflect.ValueOf(&cfg).Elem(), "") {
+		if f.env != "" {
+			if value, ok := os.LookupEnv(f.env); ok {
+				if err := set(f.value, value); err != nil {
+					return Config{}, fmt.Errorf("env %s: %w", f.env, err)
+				}
+			}
+		}
+		if value, ok := l.flags[f.key]; ok {
+			if err := set(f.value, value); err != nil {
+				return Config{}, fmt.Errorf("flag -%s: %w", f.key, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of c with every non-empty secret replaced.
+func (c Config) Redacted() Config {
+	for _, f := range fields(reflect.ValueOf(&c).Elem(), "") {
+		if !f.secret || f.value.IsZero() {
+			continue
+		}
+		if f.value.Kind() == reflect.Slice {
+			f.value.Set(reflect.ValueOf([]string{redacted}))
+		} else {
+			f.value.SetString(redacted)
+		}
+	}
+	return c
+}
+
+// String renders the redacted configuration as YAML.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+type field struct {
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settable leaf fields of a struct with their YAML path.
+func fields(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if !sf.IsExported() || name == "-" || name == "" {
+			continue
+		}
+		key := prefix + name
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			out = append(out, fields(fv, key+".")...)
+			continue
+		}
+		out = append(out, field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+	return out
+}
+
+func set(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package health serves the liveness and readiness probes. Readiness runs
+// every registered Checker and reports each one's status and latency.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker reports whether a dependency the service needs is usable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Registry holds the readiness checks. Checks run concurrently and each is
+// cancelled after Timeout.
+type Registry struct {
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Checker
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		Timeout: timeout,
+		checks:  map[string]Checker{},
+	}
+}
+
+// Register adds a check, replacing any check of the same name.
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = checker
+}
+
+// Run executes every check and reports them in registration order.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Checker, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(names))}
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = r.run(ctx, names[i], checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, name string, checker Checker) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	result = Result{Name: name, Status: StatusOK}
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			result.Status = StatusFail
+			result.Error = fmt.Sprint("panic: ", p)
+		}
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	if err := checker.Check(ctx); err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ReadinessHandler responds 200 when every check passes and 503 otherwise.
+func (r *Registry) ReadinessHandler(ctx echo.Context) error {
+	report := r.Run(ctx.Request().Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	return ctx.JSON(status, report)
+}
+
+// LivenessHandler responds 200 as long as the process serves requests.
+func LivenessHandler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, Report{Status: StatusOK, Checks: []Result{}})
+}
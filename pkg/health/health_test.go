@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, handler echo.HandlerFunc) (int, Report) {
+	t.Helper()
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	require.NoError(t, handler(e.NewContext(req, rec)))
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", CheckerFunc(func(ctx context.Context) error { return nil }))
+	r.Register("keys", CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	code, report := serve(t, r.ReadinessHandler)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, StatusOK, report.Status)
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, "database", report.Checks[0].Name)
+	require.Equal(t, "keys", report.Checks[1].Name)
+}
+
+func TestReadinessFailure(t *testing.T) {
+	r := NewRegistry(10 * time.Millisecond)
+	r.Register("database", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	r.Register("migrations", CheckerFunc(func(ctx context.Context) error { return errors.New("at version 1, want 2") }))
+	r.Register("keys", CheckerFunc(func(ctx context.Context) error { panic("no key") }))
+
+	code, report := serve(t, r.ReadinessHandler)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, StatusFail, report.Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	require.GreaterOrEqual(t, report.Checks[0].LatencyMs, 10.0)
+	require.Equal(t, "at version 1, want 2", report.Checks[1].Error)
+	require.Equal(t, "panic: no key", report.Checks[2].Error)
+}
+
+func TestLiveness(t *testing.T) {
+	code, report := serve(t, LivenessHandler)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, StatusOK, report.Status)
+}
@@ -0,0 +1,67 @@
+// Package logging configures the zerolog logger of the service. Entries are
+// written as JSON or for the console, always through a Redactor, and every
+// request carries a logger tagged with its request id, operation, trace id
+// and, once authenticated, user id. Retrieve it with zerolog.Ctx.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Formats supported by New.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+type Options struct {
+	// Level is a zerolog level, e.g. debug, info or warn.
+	Level  string
+	Format string
+}
+
+// New returns a logger writing redacted entries to w.
+func New(opts Options, w io.Writer) (zerolog.Logger, error) {
+	level, err := zerolog.ParseLevel(opts.Level)
+	if err != nil {
+		return zerolog.Logger{}, fmt.Errorf("parsing log level: %w", err)
+	}
+	switch opts.Format {
+	case FormatJSON, "":
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: w}
+	default:
+		return zerolog.Logger{}, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+	return zerolog.New(Redactor{W: w}).Level(level).With().Timestamp().Logger(), nil
+}
+
+// Setup makes a logger writing to stderr the global logger and the fallback
+// of zerolog.Ctx.
+func Setup(opts Options) error {
+	logger, err := New(opts, os.Stderr)
+	if err != nil {
+		return err
+	}
+	log.Logger = logger
+	zerolog.DefaultContextLogger = &log.Logger
+	return nil
+}
+
+// With returns ctx carrying the logger of ctx extended by fields.
+func With(ctx context.Context, fields func(zerolog.Context) zerolog.Context) context.Context {
+	return fields(zerolog.Ctx(ctx).With()).Logger().WithContext(ctx)
+}
+
+// WithUserID tags the logger of ctx with the authenticated user.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return With(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("user_id", userID)
+	})
+}
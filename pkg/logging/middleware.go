@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"regexp"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/tracing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+const RequestIDHeader = echo.HeaderXRequestID
+
+// validRequestID limits the request ids accepted from clients.
+var validRequestID = regexp.MustCompile(`^[\w.-]{1,64}$`)
+
+// Middleware puts a logger tagged with the request id, the operation named
+// by operation and the trace id into the request context and writes an
+// access log entry per request. The request id is taken from the
+// X-Request-Id header when valid and returned in it. Query strings are not
+// logged since they can carry tokens.
+func Middleware(logger zerolog.Logger, operation func(echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(requestID) {
+				requestID = uuid.NewString()
+			}
+			c.Response().Header().Set(RequestIDHeader, requestID)
+
+			fields := logger.With().
+				Str("request_id", requestID).
+				Str("operation", operation(c))
+			if traceID := tracing.TraceID(req.Context()); traceID != "" {
+				fields = fields.Str("trace_id", traceID)
+			}
+			c.SetRequest(req.WithContext(fields.Logger().WithContext(req.Context())))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			// Handlers replace the request, the logger may have gained the
+			// user id since.
+			entry := zerolog.Ctx(c.Request().Context()).Info()
+			status := c.Response().Status
+			if status >= 500 {
+				entry = zerolog.Ctx(c.Request().Context()).Error()
+			}
+			path := c.Path()
+			if path == "" {
+				path = req.URL.Path
+			}
+			entry.
+				Str("method", req.Method).
+				Str("path", path).
+				Int("status", status).
+				Int64("bytes_out", c.Response().Size).
+				Str("remote_ip", c.RealIP()).
+				Dur("latency", time.Since(start)).
+				Msg("request")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(Options{Level: "info"}, &out)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(Middleware(logger, func(c echo.Context) string { return "getProfile" }))
+	e.GET("/users/profile", func(c echo.Context) error {
+		ctx := WithUserID(c.Request().Context(), "user-1")
+		c.SetRequest(c.Request().WithContext(ctx))
+		zerolog.Ctx(ctx).Info().Msg("handled")
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/profile?token=secret", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	e.ServeHTTP(rec, req)
+	require.Equal(t, "req-1", rec.Header().Get(RequestIDHeader))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		require.Equal(t, "req-1", entry["request_id"])
+		require.Equal(t, "getProfile", entry["operation"])
+		require.Equal(t, "user-1", entry["user_id"])
+	}
+
+	var access map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	require.Equal(t, "request", access["message"])
+	require.Equal(t, "/users/profile", access["path"])
+	require.Equal(t, float64(http.StatusNoContent), access["status"])
+	require.NotContains(t, lines[1], "secret")
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(zerolog.Nop(), func(c echo.Context) string { return "" }))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "not valid\n")
+	e.ServeHTTP(rec, req)
+	require.Len(t, rec.Header().Get(RequestIDHeader), 36)
+}
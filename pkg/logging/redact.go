@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const redacted = "[redacted]"
+
+// Field kinds, looked up by the field name lower cased without separators.
+var (
+	secretFields = map[string]bool{
+		"password": true, "passwordsalt": true, "token": true, "accesstoken": true,
+		"refreshtoken": true, "authorization": true, "cookie": true, "secret": true,
+	}
+	phoneFields = map[string]bool{"phone": true, "phonenumber": true}
+	nameFields  = map[string]bool{"name": true, "fullname": true}
+	emailFields = map[string]bool{"email": true}
+)
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+	tokenParam    = regexp.MustCompile(`(?i)(token=)[^&\s"]+`)
+	// phonePattern matches standalone runs of 9 to 15 digits, the length of
+	// phone numbers, so ids and hashes mixing letters are left alone.
+	phonePattern = regexp.MustCompile(`(^|[^\w+-])(\+?\d{9,15})($|[^\w-])`)
+)
+
+// Redactor masks personal data and secrets in the JSON log entries written
+// through it before passing them on to W. Fields are masked by name, e.g.
+// password, phone_number or full_name, and tokens and phone numbers are
+// masked in every string value, including messages and errors.
+type Redactor struct {
+	W io.Writer
+}
+
+func (r Redactor) Write(p []byte) (int, error) {
+	var entry map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&entry); err != nil {
+		// Not an entry written by zerolog, scrub it as text.
+		if _, err := io.WriteString(r.W, RedactText(string(p))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	for key, value := range entry {
+		entry[key] = redactValue(key, value)
+	}
+	out, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.W.Write(append(out, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactString(key, v)
+	case map[string]interface{}:
+		for k, nested := range v {
+			v[k] = redactValue(k, nested)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(key, item)
+		}
+	}
+	return value
+}
+
+func redactString(key, value string) string {
+	if value == "" {
+		return value
+	}
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	switch {
+	case secretFields[normalized]:
+		return redacted
+	case phoneFields[normalized]:
+		return MaskPhone(value)
+	case nameFields[normalized]:
+		return MaskName(value)
+	case emailFields[normalized]:
+		return MaskEmail(value)
+	}
+	return RedactText(value)
+}
+
+// RedactText masks tokens and phone numbers in free text.
+func RedactText(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = tokenParam.ReplaceAllString(s, "${1}"+redacted)
+	return phonePattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := phonePattern.FindStringSubmatch(match)
+		return parts[1] + MaskPhone(parts[2]) + parts[3]
+	})
+}
+
+// MaskPhone keeps the last four digits of a phone number, e.g.
+// +*********7890.
+func MaskPhone(s string) string {
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			if digits > 4 {
+				r = '*'
+			}
+			digits--
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MaskName keeps the first letter of every word, e.g. J*** D***.
+func MaskName(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		r, _ := utf8.DecodeRuneInString(word)
+		words[i] = string(r) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskEmail keeps the first letter and the domain, e.g. j***@example.com.
+func MaskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at < 1 {
+		return redacted
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r) + "***" + s[at:]
+}
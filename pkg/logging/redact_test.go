@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(Options{Level: "info"}, &out)
+	require.NoError(t, err)
+
+	logger.Info().
+		Str("phone_number", "+6281234567890").
+		Str("fullName", "Jane Doe").
+		Str("password", "P@ssw0rd").
+		Str("email", "jane@example.com").
+		Str("user_id", "0f8d6c52-2b5e-4c37-9d5a-5a0c1fb9d2a1").
+		Dict("request", zerolog.Dict().Str("Authorization", "Bearer abc.def")).
+		Err(errors.New(`duplicate key (phone_number)=(+6281234567890)`)).
+		Msg("sent https://example.com/users/email/verify?token=abc123&x=1 with eyJhbGciOiJFUzI1NiJ9.eyJ1c2VyIjp7fX0.sig")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	require.Equal(t, "+*********7890", entry["phone_number"])
+	require.Equal(t, "J*** D***", entry["fullName"])
+	require.Equal(t, "[redacted]", entry["password"])
+	require.Equal(t, "j***@example.com", entry["email"])
+	require.Equal(t, "0f8d6c52-2b5e-4c37-9d5a-5a0c1fb9d2a1", entry["user_id"])
+	require.Equal(t, "[redacted]", entry["request"].(map[string]interface{})["Authorization"])
+	require.Equal(t, "duplicate key (phone_number)=(+*********7890)", entry["error"])
+	require.Equal(t, "sent https://example.com/users/email/verify?token=[redacted]&x=1 with [redacted]", entry["message"])
+	require.Equal(t, "info", entry["level"])
+}
+
+func TestRedactText(t *testing.T) {
+	for in, want := range map[string]string{
+		"call 081234567890 now":          "call ********7890 now",
+		"Authorization: Bearer abc.def":  "Authorization: Bearer [redacted]",
+		"trace 4bf92f3577b34da6a3ce929d": "trace 4bf92f3577b34da6a3ce929d",
+		"took 1234 ms":                   "took 1234 ms",
+	} {
+		require.Equal(t, want, RedactText(in))
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	_, err := New(Options{Level: "loud"}, &bytes.Buffer{})
+	require.Error(t, err)
+	_, err = New(Options{Level: "info", Format: "xml"}, &bytes.Buffer{})
+	require.Error(t, err)
+}
@@ -0,0 +1,63 @@
+// Package mail sends transactional emails to users.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender delivers messages through an SMTP relay.
+type SMTPSender struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("parsing smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("sending mail: %w", err)
+	}
+	return nil
+}
+
+// LogSender writes messages to the log instead of sending them. It is used
+// for local development when no SMTP relay is configured.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Msg(msg.Body)
+	return nil
+}
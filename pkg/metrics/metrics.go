@@ -0,0 +1,153 @@
+// Package metrics defines the Prometheus metrics of the service and the
+// handler exposing them.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "user_service"
+
+// Label values of Logins.
+const (
+	LoginSuccess   = "success"
+	LoginFailure   = "failure"
+	LoginLockedOut = "locked_out"
+)
+
+// Label values of TokenValidationFailures.
+const (
+	TokenMissingHeader   = "missing_header"
+	TokenMalformedHeader = "malformed_header"
+	TokenInvalid         = "invalid_token"
+	TokenInvalidClaims   = "invalid_claims"
+	TokenMissingUser     = "missing_user"
+	TokenInactiveUser    = "inactive_user"
+)
+
+// Label values of PasswordHashDuration.
+const (
+	PasswordHash    = "hash"
+	PasswordCompare = "compare"
+)
+
+// Label values of CacheLookups.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Label values of OutboxEvents.
+const (
+	OutboxPublished = "published"
+	OutboxFailed    = "failed"
+)
+
+// Label values of WebhookDeliveries.
+const (
+	WebhookSucceeded = "succeeded"
+	WebhookFailed    = "failed"
+	WebhookDead      = "dead"
+)
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by operation, method and status code.",
+	}, []string{"operation", "method", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by operation and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "method"})
+
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Users registered.",
+	})
+
+	TokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validation_failures_total",
+		Help:      "Rejected bearer tokens by reason.",
+	}, []string{"reason"})
+
+	PasswordHashDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "Time spent in bcrypt by operation.",
+		// bcrypt is slow by design, cost 10 takes around 50ms.
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Repository cache lookups by method and result.",
+	}, []string{"method", "result"})
+
+	OutboxEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_total",
+		Help:      "Outbox event publishing attempts by event type and result.",
+	}, []string{"type", "result"})
+
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Webhook delivery attempts by result, dead ones ran out of attempts.",
+	}, []string{"result"})
+)
+
+// Registry holds every metric of the service together with the Go runtime
+// and process collectors.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		GRPCRequests,
+		HTTPRequestDuration,
+		Logins,
+		Registrations,
+		TokenValidationFailures,
+		PasswordHashDuration,
+		CacheLookups,
+		OutboxEvents,
+		WebhookDeliveries,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db, see sql.DBStats.
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
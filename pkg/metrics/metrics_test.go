@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const spec = `
+openapi: "3.0.0"
+info: {title: test, version: "1"}
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      responses:
+        "200": {description: ok}
+`
+
+func TestMiddleware(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(Middleware(doc))
+	e.GET("/users/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/healthz", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing", "/healthz", "/nope"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// The spec uses {id} while echo uses :id.
+	require.Equal(t, 2.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("getUser", "GET", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("getUser", "GET", "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("/healthz", "GET", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("unmatched", "GET", "404")))
+}
+
+func TestHandler(t *testing.T) {
+	Registrations.Inc()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "user_service_registrations_total 1")
+	require.Contains(t, rec.Body.String(), "go_goroutines")
+}
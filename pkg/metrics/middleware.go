@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/operation"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware records HTTP requests labelled by operation, see
+// operation.Resolver. It must run before middleware that can reject
+// requests so those are counted too.
+func Middleware(spec *openapi3.T) echo.MiddlewareFunc {
+	resolve := operation.Resolver(spec)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response so the status
+				// code is known.
+				c.Error(err)
+			}
+
+			method := c.Request().Method
+			name := resolve(c)
+			code := strconv.Itoa(c.Response().Status)
+			HTTPRequests.WithLabelValues(name, method, code).Inc()
+			HTTPRequestDuration.WithLabelValues(name, method).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
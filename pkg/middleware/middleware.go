@@ -2,74 +2,171 @@ package middleware
 
 import (
 	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/logging"
+	"InterviewBackendSawitProGolang/pkg/metrics"
 	"context"
+	"crypto/ecdsa"
+	"crypto/subtle"
 	"errors"
 	"fmt"
-	"github.com/deepmap/oapi-codegen/pkg/ecdsafile"
 	"github.com/deepmap/oapi-codegen/pkg/middleware"
 	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 const JWTClaimsContextKey = "jwt_claims"
-const KeyID = "backend-sawit-test-id"
-const PublicKey = `-----BEGIN PUBLIC KEY-----
-MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEEoOvsqqI0Vxww1tybdOvNHWv3NRp
-qDWkzCIL+jNh1pfDVAuFi3zE/40DtTldYDTEcBx0AWaEFbwcpYjz7A2HrQ==
------END PUBLIC KEY-----`
+
+// AdminTokenHeader carries the token of the AdminAuth security scheme.
+const AdminTokenHeader = "X-Admin-Token"
+
+// OAuthPathPrefix is the prefix of the OAuth endpoints, which answer errors
+// in the format of RFC 6749.
+const OAuthPathPrefix = "/oauth/"
 
 var (
 	ErrNoAuthHeader      = errors.New("Authorization header is missing")
 	ErrInvalidAuthHeader = errors.New("Authorization header is malformed")
 	ErrClaimsInvalid     = errors.New("Provided claims do not match expected scopes")
+	ErrAdminDisabled     = errors.New("admin API is disabled")
+	ErrInvalidAdminToken = errors.New("X-Admin-Token header is missing or invalid")
+	ErrInvalidClient     = errors.New("client credentials are missing or invalid")
+	ErrMissingUser       = errors.New("token has no user claim with an ID")
+	ErrInactiveUser      = errors.New("token was revoked or its user suspended or deleted")
+	// ErrUserCheckFailed wraps the errors of a UserCheck, the request
+	// failed rather than its token.
+	ErrUserCheckFailed = errors.New("checking token user")
 )
 
+// UserCheck tells whether a valid token issued to a user at issuedAt, zero
+// when the token has no iat claim, was not revoked since.
+type UserCheck func(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error)
+
 type JWSValidator interface {
 	ValidateJws(jwsString string) (jwt.Token, error)
 }
 
 type Authenticator struct {
-	KeySet jwk.Set
+	KeySet   jwk.Set
+	Issuer   string
+	Audience string
 }
 
+// ValidateJws verifies the signature of a token and checks its issuer,
+// audience and, when set, its expiry.
 func (a *Authenticator) ValidateJws(jwsString string) (jwt.Token, error) {
-	return jwt.Parse([]byte(jwsString), jwt.WithKeySet(a.KeySet),
-		jwt.WithAudience("backed-sawit-pro-audience"), jwt.WithIssuer("backed-sawit-pro-issuer"))
+	return jwt.Parse([]byte(jwsString), jwt.WithKeySet(a.KeySet), jwt.WithValidate(true),
+		jwt.WithAudience(a.Audience), jwt.WithIssuer(a.Issuer))
 }
 
 var _ JWSValidator = (*Authenticator)(nil)
 
-func NewMiddleware() (echo.MiddlewareFunc, error) {
+type Options struct {
+	// PublicKey verifies the ES256 signature of bearer tokens.
+	PublicKey *ecdsa.PublicKey
+	KeyID     string
+	Issuer    string
+	Audience  string
+	// AdminToken authenticates the admin endpoints, they are disabled when
+	// it is empty.
+	AdminToken string
+	// IntrospectionClients holds the secrets of the clients of the
+	// ClientAuth security scheme by client ID.
+	IntrospectionClients map[string]string
+	// SkipPrefixes lists path prefixes, e.g. of static files, that are not
+	// part of the spec and bypass the validator.
+	SkipPrefixes []string
+	// SkipPaths lists exact paths, e.g. health probes, that bypass the
+	// validator.
+	SkipPaths []string
+	// CheckUser, when set, rejects the bearer tokens that were revoked.
+	CheckUser UserCheck
+}
+
+// NewMiddleware validates requests against the OpenAPI spec and
+// authenticates them.
+func NewMiddleware(opts Options) (echo.MiddlewareFunc, error) {
 	spec, err := generated.GetSwagger()
 	if err != nil {
 		return nil, fmt.Errorf("loading spec: %w", err)
 	}
-	auth := new(Authenticator)
-	if err := auth.Init(); err != nil {
+	auth, err := NewJWSValidator(opts)
+	if err != nil {
 		return nil, err
 	}
 	validator := middleware.OapiRequestValidatorWithOptions(spec,
 		&middleware.Options{
 			Options: openapi3filter.Options{
-				AuthenticationFunc: NewAuthenticator(auth),
+				AuthenticationFunc: func(ctx context.Context, input *openapi3filter.AuthenticationInput) error {
+					switch input.SecuritySchemeName {
+					case "AdminAuth":
+						return AuthenticateAdmin(opts.AdminToken, input)
+					case "ClientAuth":
+						return AuthenticateClient(opts.IntrospectionClients, ctx, input)
+					}
+					return Authenticate(auth, opts.CheckUser, ctx, input)
+				},
+			},
+			ErrorHandler: oauthErrors,
+			Skipper: func(c echo.Context) bool {
+				path := c.Request().URL.Path
+				for _, prefix := range opts.SkipPrefixes {
+					if strings.HasPrefix(path, prefix) {
+						return true
+					}
+				}
+				for _, skip := range opts.SkipPaths {
+					if path == skip {
+						return true
+					}
+				}
+				return false
 			},
 		})
-	return validator, nil
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return validator(tagUserID(next))
+	}, nil
+}
+
+// tagUserID adds the user authenticated by the validator to the logger of
+// the request. It runs after the validator, which restores the body it read
+// on the request it was given, so replacing the request during
+// authentication would lose the body.
+func tagUserID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if userID, ok := c.Get("user_id").(string); ok {
+			req := c.Request()
+			c.SetRequest(req.WithContext(logging.WithUserID(req.Context(), userID)))
+		}
+		return next(c)
+	}
+}
+
+// NewJWSValidator returns the validator of the bearer tokens described by
+// the PublicKey, KeyID, Issuer and Audience of opts.
+func NewJWSValidator(opts Options) (*Authenticator, error) {
+	auth := &Authenticator{
+		Issuer:   opts.Issuer,
+		Audience: opts.Audience,
+	}
+	if err := auth.Init(opts.PublicKey, opts.KeyID); err != nil {
+		return nil, err
+	}
+	return auth, nil
 }
 
-func (a *Authenticator) Init() error {
+func (a *Authenticator) Init(publicKey *ecdsa.PublicKey, keyID string) error {
 	set := jwk.NewSet()
 	pubKey := jwk.NewECDSAPublicKey()
-	loadPubKey, err := ecdsafile.LoadEcdsaPublicKey([]byte(PublicKey))
-	if err != nil {
-		return fmt.Errorf("loading PEM private key: %w", err)
-	}
-	err = pubKey.FromRaw(loadPubKey)
+	err := pubKey.FromRaw(publicKey)
 	if err != nil {
 		return fmt.Errorf("parsing jwk key: %w", err)
 	}
@@ -79,7 +176,7 @@ func (a *Authenticator) Init() error {
 		return fmt.Errorf("setting key algorithm: %w", err)
 	}
 
-	err = pubKey.Set(jwk.KeyIDKey, KeyID)
+	err = pubKey.Set(jwk.KeyIDKey, keyID)
 	if err != nil {
 		return fmt.Errorf("setting key ID: %w", err)
 	}
@@ -89,44 +186,154 @@ func (a *Authenticator) Init() error {
 	return nil
 }
 
-func NewAuthenticator(v JWSValidator) openapi3filter.AuthenticationFunc {
+func NewAuthenticator(v JWSValidator, check UserCheck) openapi3filter.AuthenticationFunc {
 	return func(ctx context.Context, input *openapi3filter.AuthenticationInput) error {
-		return Authenticate(v, ctx, input)
+		return Authenticate(v, check, ctx, input)
 	}
 }
 
-func Authenticate(v JWSValidator, ctx context.Context, input *openapi3filter.AuthenticationInput) error {
+func Authenticate(v JWSValidator, check UserCheck, ctx context.Context, input *openapi3filter.AuthenticationInput) error {
 	// Our security scheme is named BearerAuth, ensure this is the case
 	if input.SecuritySchemeName != "BearerAuth" {
 		return fmt.Errorf("security scheme %s != 'BearerAuth'", input.SecuritySchemeName)
 	}
 
-	// Now, we need to get the JWS from the request, to match the request expectations
+	userID, err := AuthenticateBearer(ctx, v, check, input.RequestValidationInput.Request.Header.Get("Authorization"))
+	if errors.Is(err, ErrUserCheckFailed) {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Unable to check token user")
+		return &echo.HTTPError{
+			Code:     http.StatusInternalServerError,
+			Message:  "internal server error",
+			Internal: err,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	eCtx := middleware.GetEchoContext(ctx)
+	eCtx.Set("user_id", userID)
+
+	return nil
+}
+
+// AuthenticateAdmin checks the AdminTokenHeader of the request against
+// token in constant time.
+func AuthenticateAdmin(token string, input *openapi3filter.AuthenticationInput) error {
+	if token == "" {
+		return ErrAdminDisabled
+	}
+	got := input.RequestValidationInput.Request.Header.Get(AdminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return ErrInvalidAdminToken
+	}
+	return nil
+}
+
+// AuthenticateClient checks the HTTP Basic client credentials of the
+// request against clients, answering 401 with an RFC 6749 invalid_client
+// error when they are missing or wrong. The ID of the client is stored as
+// "client_id" in the echo context.
+func AuthenticateClient(clients map[string]string, ctx context.Context, input *openapi3filter.AuthenticationInput) error {
+	eCtx := middleware.GetEchoContext(ctx)
+	id, secret, ok := input.RequestValidationInput.Request.BasicAuth()
+	// RFC 6749 form-encodes the credentials before they are joined.
+	id, secret = formUnescape(id), formUnescape(secret)
+	want, known := clients[id]
+	if !ok || !known || subtle.ConstantTimeCompare([]byte(secret), []byte(want)) != 1 {
+		eCtx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		description := ErrInvalidClient.Error()
+		return &echo.HTTPError{
+			Code: http.StatusUnauthorized,
+			Message: generated.OAuthError{
+				Error:            generated.InvalidClient,
+				ErrorDescription: &description,
+			},
+			Internal: ErrInvalidClient,
+		}
+	}
+	eCtx.Set("client_id", id)
+	return nil
+}
+
+func formUnescape(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+// oauthErrors answers the invalid requests of the OAuth endpoints with an
+// RFC 6749 invalid_request error.
+func oauthErrors(c echo.Context, err *echo.HTTPError) error {
+	if err.Code != http.StatusBadRequest || !strings.HasPrefix(c.Request().URL.Path, OAuthPathPrefix) {
+		return err
+	}
+	description := fmt.Sprint(err.Message)
+	return c.JSON(http.StatusBadRequest, generated.OAuthError{
+		Error:            generated.InvalidRequest,
+		ErrorDescription: &description,
+	})
+}
+
+// AuthenticateBearer validates the bearer token of an Authorization header
+// with v and, when check is not nil, its user with check, returning the ID
+// of the user it was issued to and counting failures in the token validation
+// metrics. The REST and gRPC APIs authenticate users with it.
+func AuthenticateBearer(ctx context.Context, v JWSValidator, check UserCheck, authorization string) (string, error) {
+	// Now, we need to get the JWS from the header, to match the request expectations
 	// against request contents.
-	jws, err := GetJWSFromRequest(input.RequestValidationInput.Request)
+	jws, err := parseBearer(authorization)
 	if err != nil {
-		return fmt.Errorf("getting jws: %w", err)
+		if errors.Is(err, ErrNoAuthHeader) {
+			metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMissingHeader).Inc()
+		} else {
+			metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMalformedHeader).Inc()
+		}
+		return "", fmt.Errorf("getting jws: %w", err)
 	}
 
 	// if the JWS is valid, we have a JWT, which will contain a bunch of claims.
 	token, err := v.ValidateJws(jws)
 	if err != nil {
-		return fmt.Errorf("validating JWS: %w", err)
+		if jwt.IsValidationError(err) {
+			metrics.TokenValidationFailures.WithLabelValues(metrics.TokenInvalidClaims).Inc()
+		} else {
+			metrics.TokenValidationFailures.WithLabelValues(metrics.TokenInvalid).Inc()
+		}
+		return "", fmt.Errorf("validating JWS: %w", err)
 	}
 
 	userID, err := GetClaimsFromToken(token)
 	if err != nil {
-		return fmt.Errorf("validating JWS: %w", err)
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMissingUser).Inc()
+		return "", fmt.Errorf("validating JWS: %w", err)
+	}
+	if check == nil {
+		return userID, nil
 	}
 
-	eCtx := middleware.GetEchoContext(ctx)
-	eCtx.Set("user_id", userID)
-
-	return nil
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMissingUser).Inc()
+		return "", fmt.Errorf("validating JWS: %w", ErrMissingUser)
+	}
+	active, err := check(ctx, id, token.IssuedAt())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUserCheckFailed, err)
+	}
+	if !active {
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TokenInactiveUser).Inc()
+		return "", ErrInactiveUser
+	}
+	return userID, nil
 }
 
 func GetJWSFromRequest(req *http.Request) (string, error) {
-	authHdr := req.Header.Get("Authorization")
+	return parseBearer(req.Header.Get("Authorization"))
+}
+
+func parseBearer(authHdr string) (string, error) {
 	if authHdr == "" {
 		return "", ErrNoAuthHeader
 	}
@@ -139,12 +346,21 @@ func GetJWSFromRequest(req *http.Request) (string, error) {
 	return strings.TrimPrefix(authHdr, prefix), nil
 }
 
+// GetClaimsFromToken returns the ID of the user claim of t, or
+// ErrMissingUser when the claim is missing or malformed.
 func GetClaimsFromToken(t jwt.Token) (string, error) {
 	user, found := t.Get("user")
 	if !found {
-		return "", nil
+		return "", ErrMissingUser
 	}
 
-	mUser := user.(map[string]interface{})
-	return mUser["id"].(string), nil
+	mUser, ok := user.(map[string]interface{})
+	if !ok {
+		return "", ErrMissingUser
+	}
+	id, ok := mUser["id"].(string)
+	if !ok || id == "" {
+		return "", ErrMissingUser
+	}
+	return id, nil
 }
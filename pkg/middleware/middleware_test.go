@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/generated"
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/metrics"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	jwxjwt "github.com/lestrrat-go/jwx/jwt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticatedRequestKeepsBodyAndTagsLogger(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	mw, err := NewMiddleware(Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	userID := uuid.NewString()
+	token, err := signer.CreateJWSWithClaims(context.Background(), map[string]interface{}{"id": userID})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			c.SetRequest(req.WithContext(zerolog.New(&logs).WithContext(req.Context())))
+			return next(c)
+		}
+	})
+	e.Use(mw)
+	e.PUT("/users", func(c echo.Context) error {
+		var body generated.UpdateProfileJSONRequestBody
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+		zerolog.Ctx(c.Request().Context()).Info().Msg("updated")
+		return c.String(http.StatusOK, *body.FullName)
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "http://localhost:8080/users",
+		strings.NewReader(`{"phoneNumber":"+6281234567890","fullName":"Test User"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+string(token))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "Test User", rec.Body.String())
+	require.Contains(t, logs.String(), `"user_id":"`+userID+`"`)
+}
+
+func TestValidateJwsChecksExpiryAndAudience(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	validator, err := NewJWSValidator(Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	claims := map[string]interface{}{"id": uuid.NewString()}
+
+	token, err := signer.CreateExpiringJWS(context.Background(), claims, time.Minute)
+	require.NoError(t, err)
+	_, err = validator.ValidateJws(string(token))
+	require.NoError(t, err)
+
+	token, err = signer.CreateExpiringJWS(context.Background(), claims, -time.Minute)
+	require.NoError(t, err)
+	_, err = validator.ValidateJws(string(token))
+	require.Error(t, err)
+
+	other, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   "another-audience",
+	})
+	require.NoError(t, err)
+	token, err = other.CreateJWSWithClaims(context.Background(), claims)
+	require.NoError(t, err)
+	_, err = validator.ValidateJws(string(token))
+	require.Error(t, err)
+}
+
+func TestAuthenticateBearerRejectsTokensWithoutUser(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	validator, err := NewJWSValidator(Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	sign := func(user interface{}) string {
+		token := jwxjwt.New()
+		require.NoError(t, token.Set(jwxjwt.IssuerKey, jwt.DefaultIssuer))
+		require.NoError(t, token.Set(jwxjwt.AudienceKey, jwt.DefaultAudience))
+		if user != nil {
+			require.NoError(t, token.Set("user", user))
+		}
+		signed, err := signer.SignToken(context.Background(), token)
+		require.NoError(t, err)
+		return string(signed)
+	}
+
+	for name, token := range map[string]string{
+		"missing":    sign(nil),
+		"not a map":  sign("user"),
+		"missing id": sign(map[string]interface{}{}),
+		"numeric id": sign(map[string]interface{}{"id": 42}),
+	} {
+		failures := testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMissingUser))
+		_, err := AuthenticateBearer(context.Background(), validator, nil, "Bearer "+token)
+		require.ErrorIs(t, err, ErrMissingUser, name)
+		require.Equal(t, failures+1, testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues(metrics.TokenMissingUser)), name)
+	}
+}
+
+func TestAuthenticateBearerChecksUser(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{
+		PrivateKey: jwt.DevelopmentPrivateKey,
+		KeyID:      jwt.DefaultKeyID,
+		Issuer:     jwt.DefaultIssuer,
+		Audience:   jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	validator, err := NewJWSValidator(Options{
+		PublicKey: signer.PublicKey(),
+		KeyID:     jwt.DefaultKeyID,
+		Issuer:    jwt.DefaultIssuer,
+		Audience:  jwt.DefaultAudience,
+	})
+	require.NoError(t, err)
+	id := uuid.New()
+	token, err := signer.CreateExpiringJWS(context.Background(), map[string]interface{}{"id": id.String()}, time.Minute)
+	require.NoError(t, err)
+	authorization := "Bearer " + string(token)
+
+	userID, err := AuthenticateBearer(context.Background(), validator, func(_ context.Context, got uuid.UUID, issuedAt time.Time) (bool, error) {
+		require.Equal(t, id, got)
+		require.WithinDuration(t, time.Now(), issuedAt, time.Minute)
+		return true, nil
+	}, authorization)
+	require.NoError(t, err)
+	require.Equal(t, id.String(), userID)
+
+	failures := testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues(metrics.TokenInactiveUser))
+	_, err = AuthenticateBearer(context.Background(), validator, func(context.Context, uuid.UUID, time.Time) (bool, error) {
+		return false, nil
+	}, authorization)
+	require.ErrorIs(t, err, ErrInactiveUser)
+	require.Equal(t, failures+1, testutil.ToFloat64(metrics.TokenValidationFailures.WithLabelValues(metrics.TokenInactiveUser)))
+
+	dbErr := errors.New("connection refused")
+	_, err = AuthenticateBearer(context.Background(), validator, func(context.Context, uuid.UUID, time.Time) (bool, error) {
+		return false, dbErr
+	}, authorization)
+	require.ErrorIs(t, err, ErrUserCheckFailed)
+	require.ErrorIs(t, err, dbErr)
+}
+
+func TestIntrospectionClientAuth(t *testing.T) {
+	signer, err := jwt.NewSigner(jwt.SignerOptions{PrivateKey: jwt.DevelopmentPrivateKey})
+	require.NoError(t, err)
+	mw, err := NewMiddleware(Options{
+		PublicKey:            signer.PublicKey(),
+		KeyID:                jwt.DefaultKeyID,
+		IntrospectionClients: map[string]string{"orders": "s3cret"},
+	})
+	require.NoError(t, err)
+	e := echo.New()
+	e.Use(mw)
+	e.POST("/oauth/introspect", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("client_id").(string)+" "+c.FormValue("token"))
+	})
+
+	for _, tt := range []struct {
+		name         string
+		id, secret   string
+		body         string
+		wantCode     int
+		wantResponse string
+	}{
+		{name: "valid", id: "orders", secret: "s3cret", body: "token=abc", wantCode: http.StatusOK, wantResponse: "orders abc"},
+		{name: "wrong secret", id: "orders", secret: "wrong", body: "token=abc", wantCode: http.StatusUnauthorized},
+		{name: "unknown client", id: "billing", secret: "s3cret", body: "token=abc", wantCode: http.StatusUnauthorized},
+		{name: "no credentials", body: "token=abc", wantCode: http.StatusUnauthorized},
+		{name: "missing token", id: "orders", secret: "s3cret", body: "token_type_hint=access_token", wantCode: http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/oauth/introspect", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tt.id != "" {
+				req.SetBasicAuth(tt.id, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code, rec.Body.String())
+			switch tt.wantCode {
+			case http.StatusOK:
+				require.Equal(t, tt.wantResponse, rec.Body.String())
+			case http.StatusUnauthorized:
+				require.Equal(t, `Basic realm="oauth"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+				require.Contains(t, rec.Body.String(), `"error":"invalid_client"`)
+			case http.StatusBadRequest:
+				require.Contains(t, rec.Body.String(), `"error":"invalid_request"`)
+			}
+		})
+	}
+}
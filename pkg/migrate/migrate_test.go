@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"InterviewBackendSawitProGolang/migrations"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+	"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email text;")},
+	"README.md":                  {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load(testFS)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	require.Equal(t, int64(1), loaded[0].Version)
+	require.Equal(t, "create_users", loaded[0].Name)
+	require.Equal(t, "DROP TABLE users;", loaded[0].Down)
+	require.Equal(t, "add_email", loaded[1].Name)
+	require.Empty(t, loaded[1].Down)
+
+	_, err = Load(fstest.MapFS{"1-bad.sql": {Data: []byte("SELECT 1")}})
+	require.Error(t, err)
+
+	_, err = Load(fstest.MapFS{"0001_only_down.down.sql": {Data: []byte("SELECT 1")}})
+	require.Error(t, err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+	for _, m := range loaded {
+		require.NotEmpty(t, m.Down, m.Name)
+	}
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(DefaultLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(DefaultLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), m.Latest())
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN email text;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations(version, name) VALUES($1,$2)")).
+		WithArgs(int64(2), "add_email").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, int64(2), applied[0].Version)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheck(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(version) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	require.EqualError(t, m.Check(context.Background()), "database is at version 1, want 2")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(version) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	require.NoError(t, m.Check(context.Background()))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(version) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+	require.NoError(t, m.Check(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownWithoutDownFile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	expectUnlock(mock)
+
+	reverted, err := m.Down(context.Background(), 1)
+	require.ErrorIs(t, err, ErrNoDownMigration)
+	require.Empty(t, reverted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/0007_existing.up.sql", []byte("SELECT 1;"), 0o644))
+
+	up, down, err := Create(dir, "Add Avatar")
+	require.NoError(t, err)
+	require.Equal(t, dir+"/0008_add_avatar.up.sql", up)
+	require.Equal(t, dir+"/0008_add_avatar.down.sql", down)
+
+	_, _, err = Create(dir, "bad-name!")
+	require.Error(t, err)
+}
@@ -0,0 +1,40 @@
+// Package operation resolves the OpenAPI operationId of echo requests so
+// logs and metrics can be labelled by operation.
+package operation
+
+import (
+	"regexp"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// Unmatched labels requests that match no route.
+const Unmatched = "unmatched"
+
+// pathParam matches the OpenAPI path parameters, which echo routes as :name.
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Resolver returns a function naming the operation of a request: the
+// operationId of the spec, the route path for routes outside the spec and
+// Unmatched when no route matched, keeping the set of names bounded.
+func Resolver(spec *openapi3.T) func(c echo.Context) string {
+	operations := map[string]string{}
+	for path, item := range spec.Paths {
+		for method, op := range item.Operations() {
+			if op.OperationID != "" {
+				operations[method+" "+pathParam.ReplaceAllString(path, ":$1")] = op.OperationID
+			}
+		}
+	}
+
+	return func(c echo.Context) string {
+		if name, ok := operations[c.Request().Method+" "+c.Path()]; ok {
+			return name
+		}
+		if c.Path() == "" {
+			return Unmatched
+		}
+		return c.Path()
+	}
+}
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Defaults of the zero DispatcherOptions fields.
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = 5 * time.Minute
+)
+
+type DispatcherOptions struct {
+	Repository repository.RepositoryInterface
+	Sinks      []Sink
+	// PollInterval is how often the outbox is checked when it was drained.
+	PollInterval time.Duration
+	BatchSize    int
+	// MinBackoff and MaxBackoff bound the delay before retrying a failed
+	// event, it doubles with every attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Dispatcher publishes the events of the outbox to every sink, at least
+// once and, per user, in the order they were recorded. When several
+// instances run one, only one of them dispatches at a time.
+type Dispatcher struct {
+	opts DispatcherOptions
+	now  func() time.Time
+}
+
+func NewDispatcher(opts DispatcherOptions) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Dispatcher{opts: opts, now: time.Now}
+}
+
+// Run dispatches events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to dispatch events")
+		}
+		// More events may be waiting until a round finds nothing to do.
+		if n > 0 && err == nil {
+			timer.Reset(0)
+		} else {
+			timer.Reset(d.opts.PollInterval)
+		}
+	}
+}
+
+// DispatchOnce publishes the next batch of events and returns how many it
+// tried to publish. The batch is handled in one transaction, holding the outbox
+// lock until it ended, an event whose publishing fails is retried after a
+// backoff and holds back the later events of its user meanwhile.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (n int, err error) {
+	err = d.opts.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		locked, err := repo.LockOutbox(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		now := d.now()
+		events, err := repo.GetPendingOutboxEvents(ctx, repository.GetPendingOutboxEventsInput{
+			Limit: d.opts.BatchSize,
+			Now:   now,
+		})
+		if err != nil {
+			return err
+		}
+
+		held := map[uuid.UUID]bool{}
+		var dispatched []int64
+		for _, event := range events {
+			if held[event.UserID] {
+				continue
+			}
+			n++
+			if err := d.publish(ctx, repo, event); err != nil {
+				held[event.UserID] = true
+				metrics.OutboxEvents.WithLabelValues(event.Type, metrics.OutboxFailed).Inc()
+				zerolog.Ctx(ctx).Warn().Err(err).Int64("event_id", event.ID).Str("event_type", event.Type).
+					Int("attempts", event.Attempts+1).Msg("Failed to publish event")
+				if err := repo.RecordOutboxEventFailure(ctx, repository.RecordOutboxEventFailureInput{
+					ID:            event.ID,
+					Error:         err.Error(),
+					NextAttemptAt: now.Add(d.backoff(event.Attempts)),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			metrics.OutboxEvents.WithLabelValues(event.Type, metrics.OutboxPublished).Inc()
+			dispatched = append(dispatched, event.ID)
+		}
+		return repo.MarkOutboxEventsDispatched(ctx, repository.MarkOutboxEventsDispatchedInput{
+			IDs:          dispatched,
+			DispatchedAt: now,
+		})
+	})
+	return
+}
+
+func (d *Dispatcher) publish(ctx context.Context, repo repository.RepositoryInterface, event repository.OutboxEvent) error {
+	e := Event{
+		ID:         event.ID,
+		Type:       event.Type,
+		UserID:     event.UserID,
+		OccurredAt: event.CreatedAt,
+		Data:       event.Payload,
+	}
+	var errs []error
+	for _, sink := range d.opts.Sinks {
+		if sink, ok := sink.(TxSink); ok {
+			// A nested transaction undoes only the writes of the failed
+			// sink, the batch can still record the failure.
+			errs = append(errs, repo.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+				return sink.PublishTx(ctx, repo, e)
+			}))
+			continue
+		}
+		errs = append(errs, sink.Publish(ctx, e))
+	}
+	return errors.Join(errs...)
+}
+
+// backoff returns the delay before the attempt following attempts failed
+// ones.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.MinBackoff
+	for i := 0; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink keeps the events published to it, failing those of the
+// users in failFor.
+type recordingSink struct {
+	events  []Event
+	failFor map[uuid.UUID]bool
+}
+
+func (s *recordingSink) Publish(ctx context.Context, event Event) error {
+	if s.failFor[event.UserID] {
+		return errors.New("broker unavailable")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestDispatcherPublishesInOrderAndRetries(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	alice, bob := uuid.New(), uuid.New()
+	require.NoError(t, Record(ctx, repo, alice, UserRegistered, UserRegisteredData{PhoneNumber: "+628123456789", FullName: "Alice"}))
+	require.NoError(t, Record(ctx, repo, bob, UserRegistered, UserRegisteredData{PhoneNumber: "+628129876543", FullName: "Bob"}))
+	require.NoError(t, Record(ctx, repo, alice, PhoneNumberChanged, PhoneNumberChangedData{PreviousPhoneNumber: "+628123456789", PhoneNumber: "+628111111111"}))
+
+	sink := &recordingSink{failFor: map[uuid.UUID]bool{alice: true}}
+	now := time.Now()
+	d := NewDispatcher(DispatcherOptions{Repository: repo, Sinks: []Sink{sink}, MinBackoff: time.Minute})
+	d.now = func() time.Time { return now }
+
+	// Alice's first event fails and holds back her second one.
+	n, err := d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, sink.events, 1)
+	require.Equal(t, bob, sink.events[0].UserID)
+	require.Equal(t, UserRegistered, sink.events[0].Type)
+	require.JSONEq(t, `{"phoneNumber":"+628129876543","fullName":"Bob"}`, string(sink.events[0].Data))
+
+	// Nothing is retried before the backoff passed.
+	delete(sink.failFor, alice)
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	now = now.Add(time.Minute)
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, sink.events, 3)
+	require.Equal(t, []string{UserRegistered, PhoneNumberChanged}, []string{sink.events[1].Type, sink.events[2].Type})
+	require.Less(t, sink.events[1].ID, sink.events[2].ID)
+
+	var data PhoneNumberChangedData
+	require.NoError(t, json.Unmarshal(sink.events[2].Data, &data))
+	require.Equal(t, "+628111111111", data.PhoneNumber)
+
+	pending, err := repo.GetPendingOutboxEvents(ctx, repository.GetPendingOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+// failingTxSink writes an event of its own and then fails.
+type failingTxSink struct{}
+
+func (failingTxSink) Publish(ctx context.Context, event Event) error {
+	return errors.New("not called")
+}
+
+func (failingTxSink) PublishTx(ctx context.Context, repo repository.RepositoryInterface, event Event) error {
+	if err := repo.InsertOutboxEvent(ctx, repository.InsertOutboxEventInput{UserID: event.UserID, Type: "Partial", Payload: []byte(`{}`)}); err != nil {
+		return err
+	}
+	return errors.New("queue unavailable")
+}
+
+func TestDispatcherUndoesFailedTxSinkWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	alice := uuid.New()
+	require.NoError(t, Record(ctx, repo, alice, UserRegistered, UserRegisteredData{PhoneNumber: "+628123456789", FullName: "Alice"}))
+
+	now := time.Now()
+	d := NewDispatcher(DispatcherOptions{Repository: repo, Sinks: []Sink{failingTxSink{}}, MinBackoff: time.Minute})
+	d.now = func() time.Time { return now }
+	n, err := d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	pending, err := repo.GetPendingOutboxEvents(ctx, repository.GetPendingOutboxEventsInput{Limit: 10, Now: now.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, UserRegistered, pending[0].Type)
+	require.Equal(t, 1, pending[0].Attempts)
+	require.Equal(t, "queue unavailable", *pending[0].LastError)
+}
+
+func TestDispatcherSkipsWhenAnotherOneHoldsTheLock(t *testing.T) {
+	repo := repository.NewMockRepositoryInterface(gomock.NewController(t))
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+			return fn(repo)
+		})
+	repo.EXPECT().LockOutbox(gomock.Any()).Return(false, nil)
+
+	n, err := NewDispatcher(DispatcherOptions{Repository: repo}).DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(DispatcherOptions{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		require.Equal(t, want, d.backoff(attempts), attempts)
+	}
+}
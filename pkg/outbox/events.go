@@ -0,0 +1,73 @@
+// Package outbox records user domain events in the transaction of the
+// change they describe and delivers them to sinks once it committed.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	UserRegistered     = "UserRegistered"
+	ProfileUpdated     = "ProfileUpdated"
+	PhoneNumberChanged = "PhoneNumberChanged"
+	UserLoggedIn       = "UserLoggedIn"
+)
+
+// Event is what sinks receive. ID increases with every event, consumers
+// can use it to drop the duplicates of at-least-once delivery.
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	UserID     uuid.UUID       `json:"userId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type UserRegisteredData struct {
+	PhoneNumber string `json:"phoneNumber"`
+	FullName    string `json:"fullName"`
+}
+
+// ProfileUpdatedData holds the fields set by the update, those left out
+// kept their value.
+type ProfileUpdatedData struct {
+	PhoneNumber string              `json:"phoneNumber"`
+	FullName    string              `json:"fullName"`
+	Email       *string             `json:"email,omitempty"`
+	DateOfBirth *string             `json:"dateOfBirth,omitempty"`
+	Gender      *string             `json:"gender,omitempty"`
+	Address     *repository.Address `json:"address,omitempty"`
+}
+
+type PhoneNumberChangedData struct {
+	PreviousPhoneNumber string `json:"previousPhoneNumber"`
+	PhoneNumber         string `json:"phoneNumber"`
+}
+
+type UserLoggedInData struct {
+	// IdentifierType is phone_number or email.
+	IdentifierType string `json:"identifierType"`
+}
+
+// Record writes an event of eventType with data to the outbox. Call it with
+// the repository of the transaction making the change, so the event is
+// only delivered if the change commits.
+func Record(ctx context.Context, repo repository.RepositoryInterface, userID uuid.UUID, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", eventType, err)
+	}
+	return repo.InsertOutboxEvent(ctx, repository.InsertOutboxEventInput{
+		UserID:  userID,
+		Type:    eventType,
+		Payload: payload,
+	})
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// Sink delivers events to their consumers, e.g. a message broker. An event
+// is retried until Publish succeeds, so it may be published more than once.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// TxSink is a Sink that stores events in the repository. The dispatcher
+// calls PublishTx with the transaction marking the event dispatched, so the
+// event is stored exactly once.
+type TxSink interface {
+	Sink
+	PublishTx(ctx context.Context, repo repository.RepositoryInterface, event Event) error
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LogSink logs every event, for development and as a record of the events
+// published.
+type LogSink struct {
+	Logger zerolog.Logger
+}
+
+func (s LogSink) Publish(ctx context.Context, event Event) error {
+	s.Logger.Info().
+		Int64("event_id", event.ID).
+		Str("event_type", event.Type).
+		Str("user_id", event.UserID.String()).
+		RawJSON("data", event.Data).
+		Msg("Published event")
+	return nil
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedChecker reports whether a password is known to have leaked.
+type BreachedChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// hashPrefixLength is the number of hex characters of the SHA-1 hash used as
+// the file name, the same k-anonymity split as the Pwned Passwords range API.
+const hashPrefixLength = 5
+
+// HashPrefixDir is a directory of Pwned Passwords range files. Each file is
+// named after the first five upper case hex characters of the SHA-1 hash
+// (e.g. "21BD1.txt") and contains one "SUFFIX:COUNT" line per leaked
+// password, where SUFFIX is the remaining 35 characters of the hash. Only
+// the file for the password's prefix is ever read.
+type HashPrefixDir string
+
+func (d HashPrefixDir) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	f, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, _, _ := strings.Cut(line, ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,208 @@
+// Package password contains the configurable password policy used when users
+// choose a password.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"InterviewBackendSawitProGolang/pkg/phone"
+)
+
+// Rule tags reported in Violation. They double as validator tags so every
+// rule gets its own translated message.
+const (
+	RuleMinLength    = "password_min_length"
+	RuleMaxLength    = "password_max_length"
+	RuleUppercase    = "password_uppercase"
+	RuleLowercase    = "password_lowercase"
+	RuleDigit        = "password_digit"
+	RuleSpecial      = "password_special"
+	RuleRepeated     = "password_repeated"
+	RulePersonalInfo = "password_personal_info"
+	RuleBreached     = "password_breached"
+	// RuleUnchecked is reported by callers of Check when the breached
+	// password list could not be read, so such passwords are rejected
+	// rather than let through unchecked.
+	RuleUnchecked = "password_unchecked"
+)
+
+// Policy describes the requirements a password must meet.
+type Policy struct {
+	MinLength        int  `yaml:"min_length"`
+	MaxLength        int  `yaml:"max_length"`
+	RequireUppercase bool `yaml:"require_uppercase"`
+	RequireLowercase bool `yaml:"require_lowercase"`
+	RequireDigit     bool `yaml:"require_digit"`
+	RequireSpecial   bool `yaml:"require_special"`
+	// MaxRepeatedCharacters limits how many times the same character may
+	// appear in a row, zero disables the rule.
+	MaxRepeatedCharacters int `yaml:"max_repeated_characters"`
+	// DisallowPersonalInfo rejects passwords containing the user's name or
+	// phone number.
+	DisallowPersonalInfo bool `yaml:"disallow_personal_info"`
+	// BreachedPasswordsDir is a directory of SHA-1 hash prefix files, see
+	// HashPrefixDir. Empty disables the breached password check.
+	BreachedPasswordsDir string `yaml:"breached_passwords_dir"`
+
+	breached BreachedChecker
+}
+
+// Subject is the user a password is checked for.
+type Subject struct {
+	FullName    string
+	PhoneNumber string
+}
+
+// Violation is a single failed rule. Param holds the rule parameter, e.g.
+// the minimum length, for use in messages.
+type Violation struct {
+	Rule  string
+	Param string
+}
+
+// DefaultPolicy returns the policy used when none is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:            6,
+		MaxLength:            64,
+		RequireUppercase:     true,
+		RequireDigit:         true,
+		RequireSpecial:       true,
+		DisallowPersonalInfo: true,
+	}
+}
+
+// Init validates the policy and sets up the breached password checker.
+func (p *Policy) Init() error {
+	if p.MinLength < 1 {
+		return errors.New("password policy: min_length must be at least 1")
+	}
+	if p.MaxLength < p.MinLength {
+		return errors.New("password policy: max_length must not be less than min_length")
+	}
+	if p.MaxRepeatedCharacters < 0 {
+		return errors.New("password policy: max_repeated_characters must not be negative")
+	}
+	if p.BreachedPasswordsDir != "" {
+		info, err := os.Stat(p.BreachedPasswordsDir)
+		if err != nil {
+			return fmt.Errorf("password policy: breached_passwords_dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("password policy: breached_passwords_dir %s is not a directory", p.BreachedPasswordsDir)
+		}
+		p.breached = HashPrefixDir(p.BreachedPasswordsDir)
+	}
+	return nil
+}
+
+// WithBreachedChecker returns a copy of the policy using c for the breached
+// password check.
+func (p Policy) WithBreachedChecker(c BreachedChecker) Policy {
+	p.breached = c
+	return p
+}
+
+// Check returns every rule the password violates. The returned error is only
+// set when the breached password list could not be read; the other
+// violations are still returned in that case.
+func (p Policy) Check(password string, subject Subject) ([]Violation, error) {
+	var violations []Violation
+	length := len([]rune(password))
+	if length < p.MinLength {
+		violations = append(violations, Violation{Rule: RuleMinLength, Param: fmt.Sprint(p.MinLength)})
+	}
+	if length > p.MaxLength {
+		violations = append(violations, Violation{Rule: RuleMaxLength, Param: fmt.Sprint(p.MaxLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSpecial = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, Violation{Rule: RuleUppercase})
+	}
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, Violation{Rule: RuleLowercase})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{Rule: RuleDigit})
+	}
+	if p.RequireSpecial && !hasSpecial {
+		violations = append(violations, Violation{Rule: RuleSpecial})
+	}
+
+	if p.MaxRepeatedCharacters > 0 && longestRun(password) > p.MaxRepeatedCharacters {
+		violations = append(violations, Violation{Rule: RuleRepeated, Param: fmt.Sprint(p.MaxRepeatedCharacters)})
+	}
+
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, subject) {
+		violations = append(violations, Violation{Rule: RulePersonalInfo})
+	}
+
+	if p.breached != nil && password != "" {
+		breached, err := p.breached.IsBreached(password)
+		if err != nil {
+			return violations, fmt.Errorf("checking breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, Violation{Rule: RuleBreached})
+		}
+	}
+	return violations, nil
+}
+
+func longestRun(s string) int {
+	var longest, run int
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = r
+	}
+	return longest
+}
+
+// minNamePartLength ignores short name parts such as initials, which would
+// otherwise match too many passwords.
+const minNamePartLength = 3
+
+func containsPersonalInfo(password string, subject Subject) bool {
+	lower := strings.ToLower(password)
+	for _, part := range strings.Fields(strings.ToLower(subject.FullName)) {
+		if len([]rune(part)) >= minNamePartLength && strings.Contains(lower, part) {
+			return true
+		}
+	}
+
+	if subject.PhoneNumber == "" {
+		return false
+	}
+	e164, err := phone.Normalize(subject.PhoneNumber)
+	if err != nil {
+		return strings.Contains(password, subject.PhoneNumber)
+	}
+	// Every notation of the number contains the national significant number.
+	subscriber := strings.TrimPrefix(e164, "+"+phone.CountryCode)
+	return strings.Contains(password, subscriber)
+}
@@ -0,0 +1,72 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func rules(violations []Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.Rule)
+	}
+	return out
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	subject := Subject{FullName: "Budi Santoso", PhoneNumber: "+6281234567890"}
+
+	violations, err := policy.Check("Secr3t!", subject)
+	require.NoError(t, err)
+	require.Empty(t, violations)
+
+	violations, err = policy.Check("abc", subject)
+	require.NoError(t, err)
+	require.Equal(t, []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSpecial}, rules(violations))
+	require.Equal(t, "6", violations[0].Param)
+
+	violations, err = policy.Check("Budi!2023", subject)
+	require.NoError(t, err)
+	require.Equal(t, []string{RulePersonalInfo}, rules(violations))
+
+	violations, err = policy.Check("X!081234567890", subject)
+	require.NoError(t, err)
+	require.Equal(t, []string{RulePersonalInfo}, rules(violations))
+}
+
+func TestRepeatedCharacters(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.MaxRepeatedCharacters = 2
+
+	violations, err := policy.Check("Paaass1!", Subject{})
+	require.NoError(t, err)
+	require.Equal(t, []string{RuleRepeated}, rules(violations))
+	require.Equal(t, "2", violations[0].Param)
+}
+
+func TestHashPrefixDir(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("P@ssw0rd"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	content := "0000000000000000000000000000000000A:1\n" + hash[5:] + ":42\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(content), 0o600))
+
+	policy := DefaultPolicy()
+	policy.BreachedPasswordsDir = dir
+	require.NoError(t, policy.Init())
+
+	violations, err := policy.Check("P@ssw0rd", Subject{})
+	require.NoError(t, err)
+	require.Equal(t, []string{RuleBreached}, rules(violations))
+
+	violations, err = policy.Check("Unl1kely!Passw0rd", Subject{})
+	require.NoError(t, err)
+	require.Empty(t, violations)
+}
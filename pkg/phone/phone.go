@@ -0,0 +1,125 @@
+// Package phone parses Indonesian phone numbers written in the usual local
+// and international notations and normalizes them to E.164.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// CountryCode is the Indonesian country calling code without the leading "+".
+const CountryCode = "62"
+
+const (
+	// MinSubscriberLength and MaxSubscriberLength bound the national
+	// significant number (the digits after "+62") of a mobile number.
+	MinSubscriberLength = 9
+	MaxSubscriberLength = 12
+)
+
+var (
+	ErrEmpty             = errors.New("phone number is empty")
+	ErrInvalidCharacters = errors.New("phone number contains invalid characters")
+	ErrUnsupportedPrefix = errors.New("phone number must start with 0, 62 or +62")
+	ErrInvalidLength     = errors.New("phone number has invalid length")
+	ErrUnknownOperator   = errors.New("phone number has unknown mobile operator prefix")
+)
+
+// mobilePrefixes lists the first three digits of the national significant
+// number assigned to Indonesian mobile operators.
+var mobilePrefixes = map[string]string{
+	"811": "Telkomsel", "812": "Telkomsel", "813": "Telkomsel",
+	"821": "Telkomsel", "822": "Telkomsel", "823": "Telkomsel",
+	"851": "Telkomsel", "852": "Telkomsel", "853": "Telkomsel",
+	"814": "Indosat", "815": "Indosat", "816": "Indosat",
+	"855": "Indosat", "856": "Indosat", "857": "Indosat", "858": "Indosat",
+	"817": "XL", "818": "XL", "819": "XL",
+	"859": "XL", "877": "XL", "878": "XL",
+	"831": "Axis", "832": "Axis", "833": "Axis", "838": "Axis",
+	"895": "Three", "896": "Three", "897": "Three", "898": "Three", "899": "Three",
+	"881": "Smartfren", "882": "Smartfren", "883": "Smartfren", "884": "Smartfren",
+	"885": "Smartfren", "886": "Smartfren", "887": "Smartfren", "888": "Smartfren",
+	"889": "Smartfren",
+	"828": "Ceria",
+}
+
+// Normalize converts a phone number written as "0812...", "62812...",
+// "+62812..." or "+62 812-..." into E.164 ("+62812..."). Spaces, dashes,
+// dots and parentheses are ignored. Normalize only checks the notation; use
+// Validate to check that the result is an Indonesian mobile number.
+func Normalize(raw string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidCharacters
+		}
+	}
+
+	digits := b.String()
+	switch {
+	case digits == "" || digits == "+":
+		return "", ErrEmpty
+	case strings.HasPrefix(digits, "+"+CountryCode):
+		digits = strings.TrimPrefix(digits, "+"+CountryCode)
+	case strings.HasPrefix(digits, "+"):
+		return "", ErrUnsupportedPrefix
+	case strings.HasPrefix(digits, CountryCode):
+		digits = strings.TrimPrefix(digits, CountryCode)
+	case strings.HasPrefix(digits, "0"):
+		digits = strings.TrimPrefix(digits, "0")
+	default:
+		return "", ErrUnsupportedPrefix
+	}
+
+	// "+62 0812..." is a common mistake, drop the trunk prefix.
+	digits = strings.TrimPrefix(digits, "0")
+	if digits == "" {
+		return "", ErrInvalidLength
+	}
+	return "+" + CountryCode + digits, nil
+}
+
+// Validate reports whether an E.164 number is an Indonesian mobile number
+// with a known operator prefix and a plausible length.
+func Validate(e164 string) error {
+	if !strings.HasPrefix(e164, "+"+CountryCode) {
+		return ErrUnsupportedPrefix
+	}
+	subscriber := strings.TrimPrefix(e164, "+"+CountryCode)
+	if len(subscriber) < MinSubscriberLength || len(subscriber) > MaxSubscriberLength {
+		return ErrInvalidLength
+	}
+	if _, ok := mobilePrefixes[subscriber[:3]]; !ok {
+		return ErrUnknownOperator
+	}
+	return nil
+}
+
+// Parse normalizes raw and validates the result.
+func Parse(raw string) (string, error) {
+	e164, err := Normalize(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := Validate(e164); err != nil {
+		return "", err
+	}
+	return e164, nil
+}
+
+// Canonical returns the E.164 form of raw, or raw unchanged when it cannot be
+// normalized so callers can still report a validation error on the original
+// input.
+func Canonical(raw string) string {
+	e164, err := Normalize(raw)
+	if err != nil {
+		return raw
+	}
+	return e164
+}
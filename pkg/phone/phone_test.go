@@ -0,0 +1,56 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{raw: "081234567890", want: "+6281234567890"},
+		{raw: "6281234567890", want: "+6281234567890"},
+		{raw: "+6281234567890", want: "+6281234567890"},
+		{raw: "+62 812-3456-7890", want: "+6281234567890"},
+		{raw: " (0812) 3456.7890 ", want: "+6281234567890"},
+		{raw: "+62 0812 3456 7890", want: "+6281234567890"},
+		{raw: "", err: ErrEmpty},
+		{raw: "+", err: ErrEmpty},
+		{raw: "0812abc", err: ErrInvalidCharacters},
+		{raw: "62+812", err: ErrInvalidCharacters},
+		{raw: "+6581234567", err: ErrUnsupportedPrefix},
+		{raw: "81234567890", err: ErrUnsupportedPrefix},
+		{raw: "0", err: ErrInvalidLength},
+	}
+	for _, c := range cases {
+		got, err := Normalize(c.raw)
+		require.ErrorIs(t, err, c.err, c.raw)
+		require.Equal(t, c.want, got, c.raw)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		e164 string
+		err  error
+	}{
+		{e164: "+6281234567890"},
+		{e164: "+62895123456"},
+		{e164: "+62123456789", err: ErrUnknownOperator},
+		{e164: "+6281234567", err: ErrInvalidLength},
+		{e164: "+628123456789012", err: ErrInvalidLength},
+		{e164: "081234567890", err: ErrUnsupportedPrefix},
+	}
+	for _, c := range cases {
+		require.ErrorIs(t, Validate(c.e164), c.err, c.e164)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	require.Equal(t, "+6281234567890", Canonical("0812 3456 7890"))
+	require.Equal(t, "abc", Canonical("abc"))
+}
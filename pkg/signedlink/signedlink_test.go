@@ -0,0 +1,29 @@
+package signedlink
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	now := time.Now()
+	token := signer.Sign(url.Values{"email": {"budi@example.com"}}, now.Add(time.Hour))
+
+	values, err := signer.Verify(token, now)
+	require.NoError(t, err)
+	require.Equal(t, "budi@example.com", values.Get("email"))
+	require.False(t, values.Has(expiresKey))
+
+	_, err = signer.Verify(token, now.Add(2*time.Hour))
+	require.ErrorIs(t, err, ErrExpired)
+
+	_, err = NewSigner([]byte("other")).Verify(token, now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = signer.Verify("garbage", now)
+	require.ErrorIs(t, err, ErrMalformed)
+}
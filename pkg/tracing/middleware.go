@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+// Middleware starts a server span per request, continuing the trace of the
+// traceparent header, and returns the trace id in the TraceIDHeader.
+// Requests to skipPaths, e.g. probes, are not traced.
+func Middleware(serviceName string, skipPaths ...string) echo.MiddlewareFunc {
+	skip := map[string]bool{}
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+	traced := otelecho.Middleware(serviceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		return skip[c.Path()]
+	}))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return traced(func(c echo.Context) error {
+			if traceID := TraceID(c.Request().Context()); traceID != "" {
+				c.Response().Header().Set(TraceIDHeader, traceID)
+			}
+			return next(c)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0eaf5c58"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+func TestMiddlewarePropagation(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	e := echo.New()
+	e.Use(Middleware("test", "/healthz"))
+	e.GET("/users/:id", func(c echo.Context) error {
+		_, span := Start(c.Request().Context(), "child")
+		End(span, nil)
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/healthz", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, traceID, rec.Header().Get(TraceIDHeader))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name())
+	require.Equal(t, "/users/:id", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, traceID, spans[1].SpanContext().TraceID().String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Empty(t, rec.Header().Get(TraceIDHeader))
+	require.Len(t, recorder.Ended(), 2)
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "jaeger"})
+	require.Error(t, err)
+}
@@ -1,46 +1,78 @@
 package validator
 
 import (
-	"fmt"
+	"InterviewBackendSawitProGolang/pkg/password"
+	"InterviewBackendSawitProGolang/pkg/phone"
+	"InterviewBackendSawitProGolang/repository"
 	"github.com/go-playground/validator/v10"
-	"regexp"
+	"github.com/rs/zerolog/log"
 	"strings"
+	"time"
 )
 
+// maxAge bounds how far in the past a date of birth may be.
+const maxAge = 130
+
+var passwordPolicy = password.DefaultPolicy()
+
+// SetPasswordPolicy replaces the policy new passwords are checked against.
+// It is meant to be called once during startup.
+func SetPasswordPolicy(p password.Policy) {
+	passwordPolicy = p
+}
+
 func customValidation(v *validator.Validate) {
-	v.RegisterValidation("valid_password", validPassword)
 	v.RegisterValidation("indonesian_phone_number", indonesianPhoneNumber)
+	v.RegisterValidation("date_of_birth", dateOfBirth)
+	v.RegisterStructValidation(userPasswordPolicy, repository.User{})
+	v.RegisterStructValidation(addressPostalCode, repository.Address{})
 }
 
-func validPassword(fl validator.FieldLevel) bool {
-	var containString, containNumber, containSpecialCharacter bool
-	password := fl.Field().Interface()
-
-	var regex, err = regexp.Compile(`[A-Z]`)
-	if err != nil {
-		fmt.Println(err.Error())
-		return false
+func userPasswordPolicy(sl validator.StructLevel) {
+	user := sl.Current().Interface().(repository.User)
+	if user.Password == "" {
+		// Reported by the required tag.
+		return
 	}
-	containString = regex.MatchString(password.(string))
 
-	regex, err = regexp.Compile(`\d`)
+	violations, err := passwordPolicy.Check(user.Password, password.Subject{
+		FullName:    user.FullName,
+		PhoneNumber: user.PhoneNumber,
+	})
 	if err != nil {
-		fmt.Println(err.Error())
-		return false
+		// Fail closed, a breached password must not get through while the
+		// list is unreadable.
+		log.Error().Err(err).Msg("Unable to check password policy")
+		sl.ReportError(user.Password, "Password", "Password", password.RuleUnchecked, "")
 	}
-	containNumber = regex.MatchString(password.(string))
-
-	regex, err = regexp.Compile(`[^a-zA-Z\d]`)
-	if err != nil {
-		fmt.Println(err.Error())
-		return false
+	for _, violation := range violations {
+		sl.ReportError(user.Password, "Password", "Password", violation.Rule, violation.Param)
 	}
+}
 
-	containSpecialCharacter = regex.MatchString(password.(string))
-	return (containString && containNumber && containSpecialCharacter)
+// addressPostalCode checks the format of the postal code for the countries
+// whose format is known, currently only Indonesia with its 5 digit codes.
+func addressPostalCode(sl validator.StructLevel) {
+	address := sl.Current().Interface().(repository.Address)
+	if address.Country != "ID" || address.PostalCode == "" {
+		return
+	}
+	if len(address.PostalCode) != 5 || strings.Trim(address.PostalCode, "0123456789") != "" {
+		sl.ReportError(address.PostalCode, "PostalCode", "PostalCode", "indonesian_postal_code", "")
+	}
 }
 
 func indonesianPhoneNumber(fl validator.FieldLevel) bool {
 	phoneNumber := fl.Field().Interface()
-	return strings.HasPrefix(phoneNumber.(string), "+62")
+	_, err := phone.Parse(phoneNumber.(string))
+	return err == nil
+}
+
+func dateOfBirth(fl validator.FieldLevel) bool {
+	dob, ok := fl.Field().Interface().(time.Time)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	return !dob.After(now) && dob.After(now.AddDate(-maxAge, 0, 0))
 }
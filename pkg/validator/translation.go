@@ -1,30 +1,81 @@
 package validator
 
 import (
+	"InterviewBackendSawitProGolang/pkg/password"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 )
 
+var passwordPolicyMessages = map[string]string{
+	password.RuleMinLength:    "{0} must be at least {1} characters long",
+	password.RuleMaxLength:    "{0} must be at most {1} characters long",
+	password.RuleUppercase:    "{0} must contain at least 1 uppercase character",
+	password.RuleLowercase:    "{0} must contain at least 1 lowercase character",
+	password.RuleDigit:        "{0} must contain at least 1 number",
+	password.RuleSpecial:      "{0} must contain at least 1 special character",
+	password.RuleRepeated:     "{0} must not repeat the same character more than {1} times in a row",
+	password.RulePersonalInfo: "{0} must not contain your name or phone number",
+	password.RuleBreached:     "{0} has appeared in a data breach, please choose a different one",
+	password.RuleUnchecked:    "{0} could not be checked against data breaches, please try again later",
+}
+
 func customTranslation(v *validator.Validate, trans ut.Translator) {
-	translateValidPassword(v, trans)
+	translatePasswordPolicy(v, trans)
+	translateIndonesianPhoneNumber(v, trans)
+	translateDateOfBirth(v, trans)
+	translateCountryCode(v, trans)
+	translateIndonesianPostalCode(v, trans)
 }
 
-func translateValidPassword(v *validator.Validate, trans ut.Translator) {
-	v.RegisterTranslation("valid_password", trans, func(ut ut.Translator) error {
-		return ut.Add("valid_password", "Password must be contain at least 1 uppercase character, 1 special character, and 1 number", true) // see universal-translator for details
-	}, func(ut ut.Translator, fe validator.FieldError) string {
-		t, _ := ut.T("valid_password", fe.Field())
+func translatePasswordPolicy(v *validator.Validate, trans ut.Translator) {
+	for rule, message := range passwordPolicyMessages {
+		rule, message := rule, message
+		v.RegisterTranslation(rule, trans, func(ut ut.Translator) error {
+			return ut.Add(rule, message, true) // see universal-translator for details
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(rule, fe.Field(), fe.Param())
 
-		return t
-	})
+			return t
+		})
+	}
 }
 
 func translateIndonesianPhoneNumber(v *validator.Validate, trans ut.Translator) {
 	v.RegisterTranslation("indonesian_phone_number", trans, func(ut ut.Translator) error {
-		return ut.Add("indonesian_phone_number", `Phone numbers must start with the Indonesia country code  "+62"`, true) // see universal-translator for details
+		return ut.Add("indonesian_phone_number", "Phone number must be a valid Indonesian mobile number, e.g. 0812xxxxxxxx or +62812xxxxxxxx", true) // see universal-translator for details
 	}, func(ut ut.Translator, fe validator.FieldError) string {
 		t, _ := ut.T("indonesian_phone_number", fe.Field())
 
 		return t
 	})
 }
+
+func translateDateOfBirth(v *validator.Validate, trans ut.Translator) {
+	v.RegisterTranslation("date_of_birth", trans, func(ut ut.Translator) error {
+		return ut.Add("date_of_birth", "{0} must be a past date within the last 130 years", true) // see universal-translator for details
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("date_of_birth", fe.Field())
+
+		return t
+	})
+}
+
+func translateCountryCode(v *validator.Validate, trans ut.Translator) {
+	v.RegisterTranslation("iso3166_1_alpha2", trans, func(ut ut.Translator) error {
+		return ut.Add("iso3166_1_alpha2", "{0} must be an ISO 3166-1 alpha-2 country code, e.g. ID", true) // see universal-translator for details
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("iso3166_1_alpha2", fe.Field())
+
+		return t
+	})
+}
+
+func translateIndonesianPostalCode(v *validator.Validate, trans ut.Translator) {
+	v.RegisterTranslation("indonesian_postal_code", trans, func(ut ut.Translator) error {
+		return ut.Add("indonesian_postal_code", "{0} must be 5 digits for Indonesian addresses", true) // see universal-translator for details
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("indonesian_postal_code", fe.Field())
+
+		return t
+	})
+}
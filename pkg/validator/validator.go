@@ -7,6 +7,7 @@ import (
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+	"reflect"
 	"strings"
 )
 
@@ -16,7 +17,7 @@ func Validate(i interface{}) error {
 	err := v.Struct(i)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
-			fieldName := strings.ToLower(string(err.Field()[0])) + err.Field()[1:]
+			fieldName := fieldPath(reflect.TypeOf(i), err.StructNamespace())
 			errors[fieldName] = append(errors[fieldName], err.Translate(trans))
 		}
 		errorsMarshal, err := json.Marshal(errors)
@@ -46,3 +47,25 @@ func newTranslation(v *validator.Validate) ut.Translator {
 	en_translations.RegisterDefaultTranslations(v, trans)
 	return trans
 }
+
+// fieldPath turns a struct namespace such as "User.UserInfo.Address.City"
+// into the key used in the error response ("address.city"). The root type
+// and embedded structs are left out so their fields appear at the top level.
+func fieldPath(t reflect.Type, namespace string) string {
+	var path []string
+	for _, name := range strings.Split(namespace, ".")[1:] {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct {
+			if field, ok := t.FieldByName(name); ok {
+				t = field.Type
+				if field.Anonymous {
+					continue
+				}
+			}
+		}
+		path = append(path, strings.ToLower(name[:1])+name[1:])
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,146 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/password"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validationErrors(t *testing.T, i interface{}) map[string][]string {
+	errors := map[string][]string{}
+	err := Validate(i)
+	if err == nil {
+		return errors
+	}
+	require.NoError(t, json.Unmarshal([]byte(err.Error()), &errors))
+	return errors
+}
+
+func TestValidateUser(t *testing.T) {
+	errors := validationErrors(t, repository.User{
+		UserInfo: repository.UserInfo{
+			PhoneNumber: "+62123",
+			FullName:    "Budi Santoso",
+		},
+		UserSecret: repository.UserSecret{
+			Password: "budi",
+		},
+	})
+	require.Contains(t, errors, "phoneNumber")
+	require.Contains(t, errors["password"], "Password must not contain your name or phone number")
+	require.Contains(t, errors["password"], "Password must be at least 6 characters long")
+
+	errors = validationErrors(t, repository.User{
+		UserInfo: repository.UserInfo{
+			PhoneNumber: "+6281234567890",
+			FullName:    "Budi Santoso",
+		},
+		UserSecret: repository.UserSecret{
+			Password: "Secr3t!",
+		},
+	})
+	require.Empty(t, errors)
+}
+
+// unavailableList fails every breached password lookup.
+type unavailableList struct{}
+
+func (unavailableList) IsBreached(string) (bool, error) {
+	return false, errors.New("list is unavailable")
+}
+
+func TestValidateUserRejectsUncheckedPassword(t *testing.T) {
+	defer SetPasswordPolicy(passwordPolicy)
+	SetPasswordPolicy(password.DefaultPolicy().WithBreachedChecker(unavailableList{}))
+
+	errors := validationErrors(t, repository.User{
+		UserInfo: repository.UserInfo{
+			PhoneNumber: "+6281234567890",
+			FullName:    "Budi Santoso",
+		},
+		UserSecret: repository.UserSecret{
+			Password: "Secr3t!",
+		},
+	})
+	require.Equal(t, []string{"Password could not be checked against data breaches, please try again later"}, errors["password"])
+}
+
+func TestValidateProfile(t *testing.T) {
+	email := "not-an-email"
+	gender := "unknown"
+	dob := time.Now().AddDate(1, 0, 0)
+	errors := validationErrors(t, repository.UpdateUserInput{
+		PhoneNumber: "+6281234567890",
+		FullName:    "Budi Santoso",
+		Profile: repository.Profile{
+			Email:       &email,
+			Gender:      &gender,
+			DateOfBirth: &dob,
+			Address: &repository.Address{
+				City:       "Jakarta",
+				Country:    "XX",
+				PostalCode: "123",
+			},
+		},
+	})
+	require.ElementsMatch(t, []string{"email", "gender", "dateOfBirth", "address.street", "address.country"}, keys(errors))
+
+	email = "budi@example.com"
+	gender = "male"
+	dob = time.Date(1990, 12, 31, 0, 0, 0, 0, time.UTC)
+	errors = validationErrors(t, repository.UpdateUserInput{
+		PhoneNumber: "+6281234567890",
+		FullName:    "Budi Santoso",
+		Profile: repository.Profile{
+			Email:       &email,
+			Gender:      &gender,
+			DateOfBirth: &dob,
+			Address: &repository.Address{
+				Street:     "Jl. Sudirman 1",
+				City:       "Jakarta",
+				PostalCode: "10210",
+				Country:    "ID",
+			},
+		},
+	})
+	require.Empty(t, errors)
+}
+
+func TestValidatePostalCodeByCountry(t *testing.T) {
+	for _, tc := range []struct {
+		country, postalCode string
+		valid               bool
+	}{
+		{"ID", "10210", true},
+		{"ID", "1021", false},
+		{"ID", "1021A", false},
+		{"GB", "SW1A 1AA", true},
+		{"US", "94103-1234", true},
+	} {
+		errors := validationErrors(t, repository.Address{
+			Street:     "Jl. Sudirman 1",
+			City:       "Jakarta",
+			PostalCode: tc.postalCode,
+			Country:    tc.country,
+		})
+		if tc.valid {
+			require.Empty(t, errors, tc.postalCode)
+		} else {
+			require.Equal(t, []string{"PostalCode must be 5 digits for Indonesian addresses"}, errors["postalCode"], tc.postalCode)
+		}
+	}
+}
+
+func keys(m map[string][]string) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// Defaults of the zero DelivererOptions fields.
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 50
+	DefaultMaxAttempts  = 10
+	DefaultMinBackoff   = 10 * time.Second
+	DefaultMaxBackoff   = time.Hour
+)
+
+type DelivererOptions struct {
+	Repository repository.RepositoryInterface
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+	// Timeout bounds a delivery request.
+	Timeout time.Duration
+	// PollInterval is how often due deliveries are looked for when there
+	// were none.
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is the number of failed attempts after which a delivery
+	// is dead.
+	MaxAttempts int
+	// MinBackoff and MaxBackoff bound the delay before retrying a failed
+	// delivery, it doubles with every attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Deliverer sends the queued deliveries, retrying failed ones with an
+// exponential backoff until they succeed or run out of attempts. Several
+// instances can run one, a delivery is claimed by one of them at a time.
+type Deliverer struct {
+	opts DelivererOptions
+	now  func() time.Time
+}
+
+func NewDeliverer(opts DelivererOptions) *Deliverer {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Deliverer{opts: opts, now: time.Now}
+}
+
+// Run delivers until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := d.DeliverOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to deliver webhooks")
+		}
+		if n > 0 && err == nil {
+			timer.Reset(0)
+		} else {
+			timer.Reset(d.opts.PollInterval)
+		}
+	}
+}
+
+// DeliverOnce sends the next batch of due deliveries concurrently and
+// returns how many it claimed. The claim is a lease that outlives the
+// request timeout, a delivery whose attempt could not be recorded is
+// retried once the lease expired.
+func (d *Deliverer) DeliverOnce(ctx context.Context) (n int, err error) {
+	now := d.now()
+	deliveries, err := d.opts.Repository.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{
+		Now:        now,
+		LeaseUntil: now.Add(2 * d.opts.Timeout),
+		Limit:      d.opts.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, len(deliveries))
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func(i int, delivery repository.WebhookDelivery) {
+			defer wg.Done()
+			errs[i] = d.deliver(ctx, delivery)
+		}(i, delivery)
+	}
+	wg.Wait()
+	return len(deliveries), errors.Join(errs...)
+}
+
+// deliver makes one attempt of delivery and records its outcome.
+func (d *Deliverer) deliver(ctx context.Context, delivery repository.WebhookDelivery) error {
+	webhook, err := d.opts.Repository.GetWebhookByID(ctx, repository.GetWebhookByIDInput{ID: delivery.WebhookID})
+	if errors.Is(err, repository.ErrNotFound) {
+		message := "webhook was deleted"
+		metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookDead).Inc()
+		return d.opts.Repository.RecordWebhookDeliveryAttempt(ctx, repository.RecordWebhookDeliveryAttemptInput{
+			DeliveryID: delivery.ID,
+			Attempt:    repository.WebhookDeliveryAttempt{AttemptedAt: d.now(), Error: &message},
+			Status:     repository.WebhookDeliveryDead,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	attempt := d.send(ctx, webhook, delivery)
+	input := repository.RecordWebhookDeliveryAttemptInput{DeliveryID: delivery.ID, Attempt: attempt}
+	switch {
+	case attempt.Error == nil:
+		input.Status = repository.WebhookDeliverySucceeded
+		metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookSucceeded).Inc()
+	case delivery.Attempts+1 >= d.opts.MaxAttempts:
+		input.Status = repository.WebhookDeliveryDead
+		metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookDead).Inc()
+		zerolog.Ctx(ctx).Warn().Str("error", *attempt.Error).Int64("delivery_id", delivery.ID).
+			Str("webhook_id", webhook.ID.String()).Msg("Webhook delivery ran out of attempts")
+	default:
+		next := attempt.AttemptedAt.Add(d.backoff(delivery.Attempts))
+		input.Status = repository.WebhookDeliveryPending
+		input.NextAttemptAt = &next
+		metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookFailed).Inc()
+	}
+	return d.opts.Repository.RecordWebhookDeliveryAttempt(ctx, input)
+}
+
+// send posts the payload of delivery to webhook, any response but a 2xx
+// one is a failure.
+func (d *Deliverer) send(ctx context.Context, webhook repository.Webhook, delivery repository.WebhookDelivery) (attempt repository.WebhookDeliveryAttempt) {
+	attempt.AttemptedAt = d.now()
+	fail := func(err error) repository.WebhookDeliveryAttempt {
+		message := err.Error()
+		attempt.Error = &message
+		return attempt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, attempt.AttemptedAt, delivery.Payload))
+
+	start := time.Now()
+	resp, err := d.opts.Client.Do(req)
+	attempt.Duration = time.Since(start)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail(fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+	return attempt
+}
+
+// backoff returns the delay before the attempt following attempts failed
+// ones.
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.opts.MinBackoff
+	for i := 0; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliverSignedEventsWithRetries(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	statuses := []int{http.StatusServiceUnavailable, http.StatusNoContent}
+	var requests []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests, bodies = append(requests, r), append(bodies, body)
+		w.WriteHeader(statuses[0])
+		statuses = statuses[1:]
+	}))
+	defer server.Close()
+
+	subscribed, err := repo.InsertWebhook(ctx, repository.InsertWebhookInput{URL: server.URL, Events: []string{outbox.UserRegistered}, Secret: "secret"})
+	require.NoError(t, err)
+	_, err = repo.InsertWebhook(ctx, repository.InsertWebhookInput{URL: server.URL, Events: []string{outbox.UserLoggedIn}, Secret: "secret"})
+	require.NoError(t, err)
+
+	// The dispatcher queues the deliveries in its transaction.
+	user := uuid.New()
+	require.NoError(t, outbox.Record(ctx, repo, user, outbox.UserRegistered, outbox.UserRegisteredData{PhoneNumber: "+628123456789", FullName: "Budi"}))
+	_, err = outbox.NewDispatcher(outbox.DispatcherOptions{Repository: repo, Sinks: []outbox.Sink{Sink{Repository: repo}}}).DispatchOnce(ctx)
+	require.NoError(t, err)
+
+	now := time.Now()
+	d := NewDeliverer(DelivererOptions{Repository: repo, MinBackoff: time.Minute})
+	d.now = func() time.Time { return now }
+
+	n, err := d.DeliverOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, requests, 1)
+	require.Equal(t, outbox.UserRegistered, requests[0].Header.Get(EventHeader))
+	require.NoError(t, Verify("secret", requests[0].Header.Get(SignatureHeader), bodies[0], time.Minute, now))
+	var event outbox.Event
+	require.NoError(t, json.Unmarshal(bodies[0], &event))
+	require.Equal(t, user, event.UserID)
+	require.Equal(t, strconv.FormatInt(event.ID, 10), requests[0].Header.Get(IDHeader))
+
+	// The failed delivery waits for its backoff.
+	n, err = d.DeliverOnce(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	now = now.Add(time.Minute)
+	n, err = d.DeliverOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, requests, 2)
+
+	deliveries, err := repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{WebhookID: subscribed.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery, err := repo.GetWebhookDelivery(ctx, repository.GetWebhookDeliveryInput{WebhookID: subscribed.ID, ID: deliveries[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, repository.WebhookDeliverySucceeded, delivery.Status)
+	require.Len(t, delivery.Log, 2)
+	require.Equal(t, http.StatusServiceUnavailable, *delivery.Log[0].StatusCode)
+	require.Equal(t, "unexpected status 503", *delivery.Log[0].Error)
+	require.Equal(t, http.StatusNoContent, *delivery.Log[1].StatusCode)
+}
+
+func TestDeliveryDiesAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook, err := repo.InsertWebhook(ctx, repository.InsertWebhookInput{URL: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, Sink{Repository: repo}.Publish(ctx, outbox.Event{ID: 1, Type: outbox.UserLoggedIn, Data: []byte(`{}`)}))
+
+	now := time.Now()
+	d := NewDeliverer(DelivererOptions{Repository: repo, MaxAttempts: 2, MinBackoff: time.Second})
+	d.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		_, err := d.DeliverOnce(ctx)
+		require.NoError(t, err)
+		now = now.Add(time.Hour)
+	}
+
+	dead := repository.WebhookDeliveryDead
+	deliveries, err := repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{WebhookID: webhook.ID, Status: &dead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.Nil(t, deliveries[0].NextAttemptAt)
+
+	// Redelivering starts over.
+	require.NoError(t, repo.RedeliverWebhookDelivery(ctx, repository.RedeliverWebhookDeliveryInput{WebhookID: webhook.ID, ID: deliveries[0].ID, At: now}))
+	n, err := d.DeliverOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestDeliveryToDeletedWebhookIsDead(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	webhook, err := repo.InsertWebhook(ctx, repository.InsertWebhookInput{URL: "http://127.0.0.1:1", Secret: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, Sink{Repository: repo}.Publish(ctx, outbox.Event{ID: 1, Type: outbox.UserLoggedIn, Data: []byte(`{}`)}))
+	require.NoError(t, repo.DeleteWebhook(ctx, repository.DeleteWebhookInput{ID: webhook.ID}))
+
+	_, err = NewDeliverer(DelivererOptions{Repository: repo}).DeliverOnce(ctx)
+	require.NoError(t, err)
+	deliveries, err := repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{WebhookID: webhook.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, repository.WebhookDeliveryDead, deliveries[0].Status)
+	require.Equal(t, "webhook was deleted", *deliveries[0].LastError)
+}
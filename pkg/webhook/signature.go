@@ -0,0 +1,92 @@
+// Package webhook delivers user events to the URLs registered by admins,
+// signing every request so receivers can check where it came from.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a delivery request.
+const (
+	// SignatureHeader holds "t=<unix timestamp>,v1=<signature>", see Sign.
+	SignatureHeader = "Webhook-Signature"
+	// IDHeader holds the event ID, receivers can use it to drop the
+	// duplicates of at-least-once delivery.
+	IDHeader    = "Webhook-Id"
+	EventHeader = "Webhook-Event"
+)
+
+var (
+	ErrMalformedSignature = errors.New("webhook signature is malformed")
+	ErrInvalidSignature   = errors.New("webhook signature is invalid")
+	ErrSignatureExpired   = errors.New("webhook signature timestamp is outside the tolerance")
+)
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the SignatureHeader value of body sent at timestamp. The
+// signature is the hex HMAC-SHA256 with secret of "<timestamp>.<body>", so
+// a request cannot be replayed later with another timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+// Verify checks a SignatureHeader value of body against secret, rejecting
+// timestamps further than tolerance from now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return ErrMalformedSignature
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+	timestamp, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrMalformedSignature
+	}
+	if d := now.Sub(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+	expected := mac(secret, t, body)
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
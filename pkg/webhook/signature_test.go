@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, "whsec_"))
+	now := time.Now()
+	body := []byte(`{"id":1}`)
+	header := Sign(secret, now, body)
+	require.Regexp(t, `^t=\d+,v1=[0-9a-f]{64}$`, header)
+
+	require.NoError(t, Verify(secret, header, body, 5*time.Minute, now.Add(time.Minute)))
+	require.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, now.Add(10*time.Minute)), ErrSignatureExpired)
+	require.ErrorIs(t, Verify(secret, header, []byte(`{"id":2}`), 5*time.Minute, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify("other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(secret, "garbage", body, 5*time.Minute, now), ErrMalformedSignature)
+
+	// A receiver rotating secrets accepts any of the listed signatures.
+	rotated := header + "," + strings.Split(Sign("other", now, body), ",")[1]
+	require.NoError(t, Verify("other", rotated, body, 5*time.Minute, now))
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+)
+
+// Sink queues a delivery of every outbox event to each webhook subscribed
+// to its type, the Deliverer sends them.
+type Sink struct {
+	Repository repository.RepositoryInterface
+}
+
+func (s Sink) Publish(ctx context.Context, event outbox.Event) error {
+	return s.PublishTx(ctx, s.Repository, event)
+}
+
+// PublishTx queues the deliveries with repo, see outbox.TxSink.
+func (s Sink) PublishTx(ctx context.Context, repo repository.RepositoryInterface, event outbox.Event) error {
+	webhooks, err := repo.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []uuid.UUID
+	for _, webhook := range webhooks {
+		if subscribed(webhook, event.Type) {
+			ids = append(ids, webhook.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return repo.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{
+		WebhookIDs:    ids,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       payload,
+		NextAttemptAt: time.Now(),
+	})
+}
+
+// subscribed reports whether webhook receives events of eventType.
+func subscribed(webhook repository.Webhook, eventType string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, t := range webhook.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
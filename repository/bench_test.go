@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The benchmarks compare preparing a statement per call, as the repository
+// used to, with the cached statements. By default they run against
+// latencyDriver, which charges a simulated network round trip per prepare
+// and query and counts the statements left open on the "server". Set
+// BENCH_DATABASE_URL to run them against Postgres instead.
+//
+//	go test ./repository -run '^$' -bench GetUserByFullName -cpu 1,8
+
+const benchQuery = "SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1"
+
+func BenchmarkGetUserByFullName(b *testing.B) {
+	input := GetUserByFullNameInput{FullName: "Test test"}
+	cases := []struct {
+		name string
+		call func(ctx context.Context, r *Repository) error
+	}{
+		{"PreparePerCall", func(ctx context.Context, r *Repository) error {
+			stmt, err := r.Db.PrepareContext(ctx, benchQuery)
+			if err != nil {
+				return err
+			}
+			var output UserInfo
+			return stmt.QueryRowContext(ctx, input.FullName).Scan(&output.PhoneNumber, &output.FullName)
+		}},
+		{"CachedStatement", func(ctx context.Context, r *Repository) error {
+			_, err := r.GetUserByFullName(ctx, input)
+			return err
+		}},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			r, counters := benchRepository(b)
+			defer r.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := c.call(ctx, r); err != nil && !errors.Is(err, ErrNotFound) {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+			if counters != nil {
+				b.ReportMetric(float64(counters.prepared.Load())/float64(b.N), "prepares/op")
+				b.ReportMetric(float64(counters.prepared.Load()-counters.closed.Load()), "open-stmts")
+			}
+		})
+	}
+}
+
+func benchRepository(b *testing.B) (*Repository, *latencyCounters) {
+	if dsn := os.Getenv("BENCH_DATABASE_URL"); dsn != "" {
+		r, err := NewRepository(NewRepositoryOptions{Dsn: dsn, Pool: PoolOptions{MaxOpenConns: 16, MaxIdleConns: 16}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r, nil
+	}
+
+	counters := &latencyCounters{}
+	db := sql.OpenDB(latencyConnector{counters: counters})
+	db.SetMaxOpenConns(16)
+	db.SetMaxIdleConns(16)
+	return &Repository{Db: db}, counters
+}
+
+// roundTrip is the simulated latency of every call to the database.
+const roundTrip = 100 * time.Microsecond
+
+type latencyCounters struct {
+	prepared atomic.Int64
+	closed   atomic.Int64
+}
+
+type latencyDriver struct{}
+
+func (latencyDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("use latencyConnector")
+}
+
+type latencyConnector struct {
+	counters *latencyCounters
+}
+
+func (c latencyConnector) Connect(context.Context) (driver.Conn, error) {
+	return latencyConn{counters: c.counters}, nil
+}
+
+func (latencyConnector) Driver() driver.Driver { return latencyDriver{} }
+
+type latencyConn struct {
+	counters *latencyCounters
+}
+
+func (c latencyConn) Prepare(query string) (driver.Stmt, error) {
+	time.Sleep(roundTrip)
+	c.counters.prepared.Add(1)
+	return latencyStmt{counters: c.counters}, nil
+}
+
+func (latencyConn) Close() error { return nil }
+
+func (latencyConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type latencyStmt struct {
+	counters *latencyCounters
+}
+
+func (s latencyStmt) Close() error {
+	s.counters.closed.Add(1)
+	return nil
+}
+
+func (latencyStmt) NumInput() int { return -1 }
+
+func (latencyStmt) Exec([]driver.Value) (driver.Result, error) {
+	time.Sleep(roundTrip)
+	return driver.RowsAffected(1), nil
+}
+
+func (latencyStmt) Query([]driver.Value) (driver.Rows, error) {
+	time.Sleep(roundTrip)
+	return &latencyRows{}, nil
+}
+
+type latencyRows struct {
+	done bool
+}
+
+func (*latencyRows) Columns() []string { return []string{"phone_number", "full_name"} }
+
+func (*latencyRows) Close() error { return nil }
+
+func (r *latencyRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = "+62123456789", "Test test"
+	return nil
+}
@@ -0,0 +1,168 @@
+// This file contains the caching decorator of RepositoryInterface.
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/cache"
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/pkg/phone"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// CachedRepository caches the users returned by GetUserByID and
+// GetUserByPhoneNumber of the repository it wraps. Writes made through it
+// drop the entries of the user they change, writes made by other means show
+// once the entries expire, or at once to the reads of a WithPrimary context.
+// Password hashes and salts are never cached, the
+// users returned by GetUserByPhoneNumber have no UserSecret, logins read it
+// with GetUserByLoginIdentifier.
+type CachedRepository struct {
+	RepositoryInterface
+	cache cache.Cache
+	ttl   time.Duration
+	// pending collects the keys to drop once the transaction of a WithTx
+	// callback ended, it is nil outside of transactions.
+	pending *[]string
+}
+
+var _ RepositoryInterface = (*CachedRepository)(nil)
+
+// NewCachedRepository caches the lookups of repo in c for ttl.
+func NewCachedRepository(repo RepositoryInterface, c cache.Cache, ttl time.Duration) *CachedRepository {
+	return &CachedRepository{RepositoryInterface: repo, cache: c, ttl: ttl}
+}
+
+func userIDKey(id uuid.UUID) string {
+	return "user:id:" + id.String()
+}
+
+func userPhoneNumberKey(phoneNumber string) string {
+	return "user:phone_number:" + phone.Canonical(phoneNumber)
+}
+
+func (r *CachedRepository) GetUserByID(ctx context.Context, input GetUserByIDInput) (UserInfo, error) {
+	return cached(ctx, r, "GetUserByID", userIDKey(input.ID), func(ctx context.Context) (UserInfo, error) {
+		return r.RepositoryInterface.GetUserByID(ctx, input)
+	})
+}
+
+func (r *CachedRepository) GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (User, error) {
+	user, err := cached(ctx, r, "GetUserByPhoneNumber", userPhoneNumberKey(input.PhoneNumber), func(ctx context.Context) (User, error) {
+		user, err := r.RepositoryInterface.GetUserByPhoneNumber(ctx, input)
+		user.UserSecret = UserSecret{}
+		return user, err
+	})
+	// Entries written before secrets were left out may still hold them.
+	user.UserSecret = UserSecret{}
+	return user, err
+}
+
+func (r *CachedRepository) UpdateUser(ctx context.Context, input UpdateUserInput) error {
+	keys := r.userKeys(ctx, input.ID)
+	defer func() { r.invalidate(ctx, append(keys, userPhoneNumberKey(input.PhoneNumber))...) }()
+	return r.RepositoryInterface.UpdateUser(ctx, input)
+}
+
+func (r *CachedRepository) VerifyEmail(ctx context.Context, input VerifyEmailInput) (VerifyEmailOutput, error) {
+	defer r.invalidate(ctx, r.userKeys(ctx, input.ID)...)
+	return r.RepositoryInterface.VerifyEmail(ctx, input)
+}
+
+func (r *CachedRepository) UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (UpdateUserAvatarOutput, error) {
+	// GetUserByPhoneNumber does not return the avatar.
+	defer r.invalidate(ctx, userIDKey(input.ID))
+	return r.RepositoryInterface.UpdateUserAvatar(ctx, input)
+}
+
+func (r *CachedRepository) UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) error {
+	defer r.invalidate(ctx, r.userKeys(ctx, input.ID)...)
+	return r.RepositoryInterface.UpdateUserPassword(ctx, input)
+}
+
+func (r *CachedRepository) UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) error {
+	defer r.invalidate(ctx, r.userKeys(ctx, input.ID)...)
+	return r.RepositoryInterface.UpdateUserSuspension(ctx, input)
+}
+
+// WithTx runs fn in a transaction of the wrapped repository. Lookups in the
+// transaction bypass the cache and the entries its writes change are
+// dropped once it ended.
+func (r *CachedRepository) WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) error {
+	if r.pending != nil {
+		return r.RepositoryInterface.WithTx(ctx, func(repo RepositoryInterface) error {
+			return fn(&CachedRepository{RepositoryInterface: repo, cache: r.cache, ttl: r.ttl, pending: r.pending})
+		})
+	}
+	var pending []string
+	defer func() { r.invalidate(ctx, pending...) }()
+	return r.RepositoryInterface.WithTx(ctx, func(repo RepositoryInterface) error {
+		return fn(&CachedRepository{RepositoryInterface: repo, cache: r.cache, ttl: r.ttl, pending: &pending})
+	})
+}
+
+// userKeys returns the keys of the current entries of a user, to be dropped
+// after changing it.
+func (r *CachedRepository) userKeys(ctx context.Context, id uuid.UUID) []string {
+	keys := []string{userIDKey(id)}
+	// Without the current phone number its entry expires on its own.
+	if user, err := r.RepositoryInterface.GetUserByID(ctx, GetUserByIDInput{ID: id}); err == nil {
+		keys = append(keys, userPhoneNumberKey(user.PhoneNumber))
+	}
+	return keys
+}
+
+func (r *CachedRepository) invalidate(ctx context.Context, keys ...string) {
+	if r.pending != nil {
+		*r.pending = append(*r.pending, keys...)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to invalidate cached users, they are stale until they expire")
+	}
+}
+
+// cached returns the value of key from the cache of r, calling load and
+// caching its result on a miss. Cache errors count as misses. Misses are
+// loaded from the primary, a lagging replica could otherwise put a value
+// back that an invalidation just removed.
+func cached[T any](ctx context.Context, r *CachedRepository, method, key string, load func(context.Context) (T, error)) (T, error) {
+	if r.pending != nil || readsPrimary(ctx) {
+		return load(ctx)
+	}
+
+	value, ok, err := r.cache.Get(ctx, key)
+	switch {
+	case err != nil:
+		metrics.CacheLookups.WithLabelValues(method, metrics.CacheError).Inc()
+		zerolog.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("Failed to read cache")
+	case ok:
+		var output T
+		if err := json.Unmarshal(value, &output); err == nil {
+			metrics.CacheLookups.WithLabelValues(method, metrics.CacheHit).Inc()
+			return output, nil
+		}
+		// Entries of an older format are replaced.
+		metrics.CacheLookups.WithLabelValues(method, metrics.CacheMiss).Inc()
+	default:
+		metrics.CacheLookups.WithLabelValues(method, metrics.CacheMiss).Inc()
+	}
+
+	output, err := load(WithPrimary(ctx))
+	if err != nil {
+		return output, err
+	}
+	if value, err := json.Marshal(output); err == nil {
+		if err := r.cache.Set(ctx, key, value, r.ttl); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("Failed to write cache")
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/cache"
+	"InterviewBackendSawitProGolang/pkg/metrics"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// primaryContext matches the contexts returned by WithPrimary.
+type primaryContext struct{}
+
+func (primaryContext) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && readsPrimary(ctx)
+}
+
+func (primaryContext) String() string {
+	return "reads the primary"
+}
+
+func TestCachedRepositoryCachesLookups(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	r := NewCachedRepository(inner, cache.NewLRU(10), time.Minute)
+	id := uuid.New()
+	hits := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheHit))
+	misses := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheMiss))
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"}, nil)
+	for i := 0; i < 2; i++ {
+		info, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+		require.NoError(t, err)
+		require.Equal(t, "Test test", info.FullName)
+	}
+	require.Equal(t, hits+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheHit)))
+	require.Equal(t, misses+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheMiss)))
+
+	// Both notations of a number share an entry.
+	inner.EXPECT().GetUserByPhoneNumber(primaryContext{}, gomock.Any()).Return(User{ID: id}, nil)
+	for _, number := range []string{"08123456789", "+628123456789"} {
+		user, err := r.GetUserByPhoneNumber(ctx, GetUserByPhoneNumberInput{PhoneNumber: number})
+		require.NoError(t, err)
+		require.Equal(t, id, user.ID)
+	}
+
+	// Not found is not cached.
+	inner.EXPECT().GetUserByID(primaryContext{}, gomock.Any()).Return(UserInfo{}, ErrNotFound).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := r.GetUserByID(ctx, GetUserByIDInput{ID: uuid.New()})
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+}
+
+func TestCachedRepositoryBypassedByWithPrimary(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	r := NewCachedRepository(inner, cache.NewLRU(10), time.Minute)
+	id := uuid.New()
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(UserInfo{FullName: "Test test"}, nil)
+	_, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+
+	// The user was suspended by another process.
+	suspendedAt := time.Now()
+	primary := WithPrimary(ctx)
+	inner.EXPECT().GetUserByID(primary, GetUserByIDInput{ID: id}).Return(UserInfo{FullName: "Test test", SuspendedAt: &suspendedAt}, nil)
+	info, err := r.GetUserByID(primary, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+	require.NotNil(t, info.SuspendedAt)
+}
+
+// recordingCache keeps every value written to the cache it wraps.
+type recordingCache struct {
+	cache.Cache
+	values [][]byte
+}
+
+func (c *recordingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.values = append(c.values, value)
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestCachedRepositoryDoesNotCacheSecrets(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	c := &recordingCache{Cache: cache.NewLRU(10)}
+	r := NewCachedRepository(inner, c, time.Minute)
+	id := uuid.New()
+	hash := "$2a$10$mfxo/UQ74TLajLVG/Rj3zet/8HFLEpOauWqAzkOSg1QIYnsr7Aoy6"
+
+	inner.EXPECT().GetUserByPhoneNumber(primaryContext{}, gomock.Any()).Return(User{
+		ID:         id,
+		UserInfo:   UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"},
+		UserSecret: UserSecret{Password: hash, PasswordSalt: "s4lt"},
+	}, nil)
+	for i := 0; i < 2; i++ {
+		user, err := r.GetUserByPhoneNumber(ctx, GetUserByPhoneNumberInput{PhoneNumber: "+628123456789"})
+		require.NoError(t, err)
+		require.Equal(t, id, user.ID)
+		require.Equal(t, "Test test", user.FullName)
+		require.Empty(t, user.Password)
+	}
+	require.Len(t, c.values, 1)
+	for _, value := range c.values {
+		require.NotContains(t, string(value), hash)
+		require.NotContains(t, string(value), "s4lt")
+	}
+}
+
+func TestCachedRepositoryInvalidatesOnUpdateUser(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	r := NewCachedRepository(inner, cache.NewLRU(10), time.Minute)
+	id := uuid.New()
+	old := UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"}
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(old, nil)
+	inner.EXPECT().GetUserByPhoneNumber(primaryContext{}, gomock.Any()).Return(User{ID: id, UserInfo: old}, nil)
+	_, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+	_, err = r.GetUserByPhoneNumber(ctx, GetUserByPhoneNumberInput{PhoneNumber: old.PhoneNumber})
+	require.NoError(t, err)
+
+	update := UpdateUserInput{ID: id, PhoneNumber: "+628129876543", FullName: "New name"}
+	gomock.InOrder(
+		inner.EXPECT().GetUserByID(ctx, GetUserByIDInput{ID: id}).Return(old, nil),
+		inner.EXPECT().UpdateUser(ctx, update).Return(nil),
+	)
+	require.NoError(t, r.UpdateUser(ctx, update))
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(UserInfo{PhoneNumber: update.PhoneNumber, FullName: update.FullName}, nil)
+	info, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+	require.Equal(t, "New name", info.FullName)
+
+	inner.EXPECT().GetUserByPhoneNumber(primaryContext{}, GetUserByPhoneNumberInput{PhoneNumber: old.PhoneNumber}).Return(User{}, ErrNotFound)
+	_, err = r.GetUserByPhoneNumber(ctx, GetUserByPhoneNumberInput{PhoneNumber: old.PhoneNumber})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCachedRepositoryInvalidatesAfterTransaction(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	lru := cache.NewLRU(10)
+	r := NewCachedRepository(inner, lru, time.Minute)
+	id := uuid.New()
+	info := UserInfo{PhoneNumber: "+628123456789", FullName: "Test test"}
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(info, nil)
+	_, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+
+	inner.EXPECT().WithTx(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(RepositoryInterface) error) error {
+			return fn(inner)
+		})
+	// Lookups in the transaction read the transaction.
+	inner.EXPECT().GetUserByID(ctx, GetUserByIDInput{ID: id}).Return(info, nil).Times(2)
+	inner.EXPECT().VerifyEmail(ctx, gomock.Any()).Return(VerifyEmailOutput{Verified: true}, nil)
+	err = r.WithTx(ctx, func(repo RepositoryInterface) error {
+		if _, err := repo.GetUserByID(ctx, GetUserByIDInput{ID: id}); err != nil {
+			return err
+		}
+		if _, err := repo.VerifyEmail(ctx, VerifyEmailInput{ID: id}); err != nil {
+			return err
+		}
+		require.Equal(t, 1, lru.Len())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Zero(t, lru.Len())
+}
+
+// failingCache fails every call.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingCache) Delete(context.Context, ...string) error {
+	return errors.New("connection refused")
+}
+
+func TestCachedRepositoryFallsBackWhenCacheFails(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMockRepositoryInterface(gomock.NewController(t))
+	r := NewCachedRepository(inner, failingCache{}, time.Minute)
+	id := uuid.New()
+	errs := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheError))
+
+	inner.EXPECT().GetUserByID(primaryContext{}, GetUserByIDInput{ID: id}).Return(UserInfo{FullName: "Test test"}, nil)
+	info, err := r.GetUserByID(ctx, GetUserByIDInput{ID: id})
+	require.NoError(t, err)
+	require.Equal(t, "Test test", info.FullName)
+	require.Equal(t, errs+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("GetUserByID", metrics.CacheError)))
+
+	// A failed invalidation does not fail the write.
+	inner.EXPECT().UpdateUserAvatar(ctx, gomock.Any()).Return(UpdateUserAvatarOutput{}, nil)
+	_, err = r.UpdateUserAvatar(ctx, UpdateUserAvatarInput{ID: id})
+	require.NoError(t, err)
+}
@@ -0,0 +1,616 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"InterviewBackendSawitProGolang/migrations"
+	"InterviewBackendSawitProGolang/pkg/migrate"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// conformanceSuite checks that every RepositoryInterface implementation
+// behaves like the Postgres Repository. Set TEST_DATABASE_URL to run it
+// against Postgres too, the users table of that database is truncated.
+type conformanceSuite struct {
+	suite.Suite
+	// open returns an empty repository and a function soft deleting a user
+	// the way an operator would, by setting deleted_at.
+	open       func(t *testing.T) (RepositoryInterface, func(id uuid.UUID))
+	repo       RepositoryInterface
+	softDelete func(id uuid.UUID)
+	ctx        context.Context
+}
+
+func (s *conformanceSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.repo, s.softDelete = s.open(s.T())
+}
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	suite.Run(t, &conformanceSuite{open: func(t *testing.T) (RepositoryInterface, func(uuid.UUID)) {
+		r := NewMemoryRepository()
+		return r, r.softDelete
+	}})
+}
+
+func TestSQLiteRepositoryConformance(t *testing.T) {
+	suite.Run(t, &conformanceSuite{open: func(t *testing.T) (RepositoryInterface, func(uuid.UUID)) {
+		r, err := NewSQLiteRepository(context.Background(), filepath.Join(t.TempDir(), "users.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { r.Close() })
+		return r, func(id uuid.UUID) {
+			require.NoError(t, r.softDelete(context.Background(), id))
+		}
+	}})
+}
+
+func TestPostgresRepositoryConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	suite.Run(t, &conformanceSuite{open: func(t *testing.T) (RepositoryInterface, func(uuid.UUID)) {
+		r, err := NewRepository(NewRepositoryOptions{Dsn: dsn})
+		require.NoError(t, err)
+		t.Cleanup(func() { r.Close() })
+		m, err := migrate.New(r.Db, migrations.FS)
+		require.NoError(t, err)
+		_, err = m.Up(context.Background())
+		require.NoError(t, err)
+		_, err = r.Db.Exec("TRUNCATE users, outbox_events, webhook_delivery_attempts, webhook_deliveries, webhooks")
+		require.NoError(t, err)
+		return r, func(id uuid.UUID) {
+			_, err := r.Db.Exec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1", id)
+			require.NoError(t, err)
+		}
+	}})
+}
+
+func (s *conformanceSuite) insert(phoneNumber, fullName string) uuid.UUID {
+	output, err := s.repo.InsertUser(s.ctx, User{
+		UserInfo:   UserInfo{PhoneNumber: phoneNumber, FullName: fullName},
+		UserSecret: UserSecret{Password: "hash", PasswordSalt: "salt"},
+	})
+	s.Require().NoError(err)
+	return output.ID
+}
+
+func (s *conformanceSuite) TestInsertAndGet() {
+	id := s.insert("0812 3456 7890", "Test test")
+
+	user, err := s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+62 812-3456-7890"})
+	s.Require().NoError(err)
+	s.Equal(id, user.ID)
+	s.Equal("+6281234567890", user.PhoneNumber)
+	s.Equal("Test test", user.FullName)
+	s.Equal(UserSecret{Password: "hash", PasswordSalt: "salt"}, user.UserSecret)
+
+	info, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Equal(UserInfo{PhoneNumber: "+6281234567890", FullName: "Test test"}, info)
+
+	info, err = s.repo.GetUserByFullName(s.ctx, GetUserByFullNameInput{FullName: "TEST TEST"})
+	s.Require().NoError(err)
+	s.Equal(UserInfo{PhoneNumber: "+6281234567890", FullName: "Test test"}, info)
+}
+
+func (s *conformanceSuite) TestNotFound() {
+	_, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: uuid.New()})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.GetUserByLoginIdentifier(s.ctx, GetUserByLoginIdentifierInput{Type: LoginIdentifierEmail, Value: "test@example.com"})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.GetUserByFullName(s.ctx, GetUserByFullNameInput{FullName: "Test test"})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.UpdateUserAvatar(s.ctx, UpdateUserAvatarInput{ID: uuid.New(), AvatarKey: "avatars/key"})
+	s.ErrorIs(err, ErrNotFound)
+}
+
+func (s *conformanceSuite) TestPhoneNumberIsUnique() {
+	id := s.insert("+6281234567890", "Test test")
+	other := s.insert("+6281234567891", "Other test")
+
+	_, err := s.repo.InsertUser(s.ctx, User{UserInfo: UserInfo{PhoneNumber: "0812 3456 7890", FullName: "Test again"}})
+	s.ErrorIs(err, ErrPhoneNumberTaken)
+	s.ErrorIs(err, ErrConflict)
+
+	err = s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: other, PhoneNumber: "+6281234567890", FullName: "Other test"})
+	s.ErrorIs(err, ErrPhoneNumberTaken)
+
+	// Keeping the own number is no conflict.
+	s.NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "+6281234567890", FullName: "Test renamed"}))
+}
+
+func (s *conformanceSuite) TestEmailIsUniqueIgnoringCase() {
+	id := s.insert("+6281234567890", "Test test")
+	other := s.insert("+6281234567891", "Other test")
+	email := "Test@Example.com"
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "+6281234567890", FullName: "Test test", Profile: Profile{Email: &email}}))
+
+	taken := "test@example.COM"
+	err := s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: other, PhoneNumber: "+6281234567891", FullName: "Other test", Profile: Profile{Email: &taken}})
+	s.ErrorIs(err, ErrEmailTaken)
+	s.ErrorIs(err, ErrConflict)
+
+	user, err := s.repo.GetUserByLoginIdentifier(s.ctx, GetUserByLoginIdentifierInput{Type: LoginIdentifierEmail, Value: "TEST@example.com"})
+	s.Require().NoError(err)
+	s.Equal(id, user.ID)
+	s.Equal(&email, user.Email)
+}
+
+func (s *conformanceSuite) TestUpdateUserKeepsUnsetProfileFields() {
+	id := s.insert("+6281234567890", "Test test")
+	email := "test@example.com"
+	dateOfBirth := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+	gender := "female"
+	address := Address{Street: "Jl. Sudirman 1", City: "Jakarta", PostalCode: "10220", Country: "ID"}
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{
+		ID:          id,
+		PhoneNumber: "+6281234567890",
+		FullName:    "Test test",
+		Profile:     Profile{Email: &email, DateOfBirth: &dateOfBirth, Gender: &gender, Address: &address},
+	}))
+
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "0812 3456 7891", FullName: "Test renamed"}))
+
+	info, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Equal("+6281234567891", info.PhoneNumber)
+	s.Equal("Test renamed", info.FullName)
+	s.Equal(&email, info.Email)
+	s.Require().NotNil(info.DateOfBirth)
+	s.Equal(dateOfBirth.Format("2006-01-02"), info.DateOfBirth.Format("2006-01-02"))
+	s.Equal(&gender, info.Gender)
+	s.Equal(&address, info.Address)
+
+	// The stored values cannot be changed through the returned pointers.
+	*info.Email = "changed@example.com"
+	info, err = s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Equal(&email, info.Email)
+}
+
+func (s *conformanceSuite) TestEmailVerification() {
+	id := s.insert("+6281234567890", "Test test")
+	email := "test@example.com"
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "+6281234567890", FullName: "Test test", Profile: Profile{Email: &email}}))
+
+	output, err := s.repo.VerifyEmail(s.ctx, VerifyEmailInput{ID: id, Email: "other@example.com", VerifiedAt: time.Now()})
+	s.Require().NoError(err)
+	s.False(output.Verified)
+
+	verifiedAt := time.Now().Truncate(time.Microsecond)
+	output, err = s.repo.VerifyEmail(s.ctx, VerifyEmailInput{ID: id, Email: "TEST@example.com", VerifiedAt: verifiedAt})
+	s.Require().NoError(err)
+	s.True(output.Verified)
+	info, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Require().NotNil(info.EmailVerifiedAt)
+	s.True(verifiedAt.Equal(*info.EmailVerifiedAt), "verified at %s, want %s", info.EmailVerifiedAt, verifiedAt)
+
+	// Changing only the case keeps the verification, another email drops it.
+	sameEmail := "Test@Example.com"
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "+6281234567890", FullName: "Test test", Profile: Profile{Email: &sameEmail}}))
+	info, err = s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.NotNil(info.EmailVerifiedAt)
+
+	otherEmail := "other@example.com"
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: id, PhoneNumber: "+6281234567890", FullName: "Test test", Profile: Profile{Email: &otherEmail}}))
+	info, err = s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Nil(info.EmailVerifiedAt)
+}
+
+func (s *conformanceSuite) TestUpdateUserAvatarReturnsPreviousKey() {
+	id := s.insert("+6281234567890", "Test test")
+
+	output, err := s.repo.UpdateUserAvatar(s.ctx, UpdateUserAvatarInput{ID: id, AvatarKey: "avatars/first"})
+	s.Require().NoError(err)
+	s.Nil(output.PreviousAvatarKey)
+
+	output, err = s.repo.UpdateUserAvatar(s.ctx, UpdateUserAvatarInput{ID: id, AvatarKey: "avatars/second"})
+	s.Require().NoError(err)
+	s.Require().NotNil(output.PreviousAvatarKey)
+	s.Equal("avatars/first", *output.PreviousAvatarKey)
+}
+
+func (s *conformanceSuite) TestUpdateUserPassword() {
+	id := s.insert("+6281234567890", "Test test")
+
+	secret := UserSecret{Password: "new-hash", PasswordSalt: "new-salt"}
+	resetAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.repo.UpdateUserPassword(s.ctx, UpdateUserPasswordInput{ID: id, UserSecret: secret, TokensValidAfter: resetAt}))
+	user, err := s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.Require().NoError(err)
+	s.Equal(secret, user.UserSecret)
+	info, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Require().NotNil(info.TokensValidAfter)
+	s.True(resetAt.Equal(*info.TokensValidAfter))
+
+	err = s.repo.UpdateUserPassword(s.ctx, UpdateUserPasswordInput{ID: uuid.New(), UserSecret: secret})
+	s.ErrorIs(err, ErrNotFound)
+}
+
+func (s *conformanceSuite) TestUpdateUserSuspension() {
+	id := s.insert("+6281234567890", "Test test")
+
+	suspendedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.repo.UpdateUserSuspension(s.ctx, UpdateUserSuspensionInput{ID: id, SuspendedAt: &suspendedAt}))
+	user, err := s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.Require().NoError(err)
+	s.Require().NotNil(user.SuspendedAt)
+	s.True(suspendedAt.Equal(*user.SuspendedAt))
+	info, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Require().NotNil(info.SuspendedAt)
+	s.Require().NotNil(info.TokensValidAfter)
+	s.True(suspendedAt.Equal(*info.TokensValidAfter))
+
+	// Lifting the suspension does not bring back the revoked tokens.
+	s.Require().NoError(s.repo.UpdateUserSuspension(s.ctx, UpdateUserSuspensionInput{ID: id}))
+	info, err = s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.Require().NoError(err)
+	s.Nil(info.SuspendedAt)
+	s.Require().NotNil(info.TokensValidAfter)
+	s.True(suspendedAt.Equal(*info.TokensValidAfter))
+
+	s.softDelete(id)
+	err = s.repo.UpdateUserSuspension(s.ctx, UpdateUserSuspensionInput{ID: id, SuspendedAt: &suspendedAt})
+	s.ErrorIs(err, ErrNotFound)
+}
+
+func (s *conformanceSuite) TestGetRecentUsersNewestFirst() {
+	first := s.insert("+6281234567890", "First test")
+	second := s.insert("+6281234567891", "Second test")
+	deleted := s.insert("+6281234567892", "Deleted test")
+	third := s.insert("+6281234567893", "Third test")
+	s.softDelete(deleted)
+
+	users, err := s.repo.GetRecentUsers(s.ctx, GetRecentUsersInput{Limit: 2})
+	s.Require().NoError(err)
+	s.Require().Len(users, 2)
+	s.Equal(third, users[0].ID)
+	s.Equal("+6281234567893", users[0].PhoneNumber)
+	s.Equal("Third test", users[0].FullName)
+	s.False(users[0].CreatedAt.IsZero())
+	s.Equal(second, users[1].ID)
+
+	users, err = s.repo.GetRecentUsers(s.ctx, GetRecentUsersInput{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(users, 3)
+	s.Equal(first, users[2].ID)
+}
+
+func (s *conformanceSuite) TestInsertUsers() {
+	email, verified := "test@example.com", "verified@example.com"
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	suspendedAt := verifiedAt.Add(time.Hour)
+	output, err := s.repo.InsertUsers(s.ctx, InsertUsersInput{Users: []User{
+		{UserInfo: UserInfo{PhoneNumber: "0812 3456 7890", FullName: "First test", Profile: Profile{Email: &email}}, UserSecret: UserSecret{Password: "hash", PasswordSalt: "salt"}},
+		{UserInfo: UserInfo{PhoneNumber: "+6281234567891", FullName: "Second test"}, UserSecret: UserSecret{Password: "other-hash"}},
+		{UserInfo: UserInfo{PhoneNumber: "+6281234567892", FullName: "Third test", EmailVerifiedAt: &verifiedAt, SuspendedAt: &suspendedAt, Profile: Profile{Email: &verified}}, UserSecret: UserSecret{Password: "hash"}},
+	}})
+	s.Require().NoError(err)
+	s.Require().Len(output.IDs, 3)
+
+	user, err := s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.Require().NoError(err)
+	s.Equal(output.IDs[0], user.ID)
+	s.Equal(UserSecret{Password: "hash", PasswordSalt: "salt"}, user.UserSecret)
+	s.Require().NotNil(user.Email)
+	s.Equal(email, *user.Email)
+	s.Nil(user.EmailVerifiedAt)
+	user, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567891"})
+	s.Require().NoError(err)
+	s.Equal(output.IDs[1], user.ID)
+	s.Nil(user.Email)
+	s.Nil(user.SuspendedAt)
+	user, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567892"})
+	s.Require().NoError(err)
+	s.Require().NotNil(user.EmailVerifiedAt)
+	s.True(verifiedAt.Equal(*user.EmailVerifiedAt))
+	s.Require().NotNil(user.SuspendedAt)
+	s.True(suspendedAt.Equal(*user.SuspendedAt))
+}
+
+func (s *conformanceSuite) TestInsertUsersFailsAsAWhole() {
+	s.insert("+6281234567890", "Test test")
+
+	_, err := s.repo.InsertUsers(s.ctx, InsertUsersInput{Users: []User{
+		{UserInfo: UserInfo{PhoneNumber: "+6281234567891", FullName: "New test"}, UserSecret: UserSecret{Password: "hash"}},
+		{UserInfo: UserInfo{PhoneNumber: "+6281234567890", FullName: "Taken test"}, UserSecret: UserSecret{Password: "hash"}},
+	}})
+	s.ErrorIs(err, ErrPhoneNumberTaken)
+	_, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567891"})
+	s.ErrorIs(err, ErrNotFound)
+
+	email := "TEST@example.com"
+	s.Require().NoError(s.repo.UpdateUser(s.ctx, UpdateUserInput{ID: s.insert("+6281234567892", "Email test"), PhoneNumber: "+6281234567892", FullName: "Email test", Profile: Profile{Email: &email}}))
+	other := "test@example.com"
+	_, err = s.repo.InsertUsers(s.ctx, InsertUsersInput{Users: []User{
+		{UserInfo: UserInfo{PhoneNumber: "+6281234567893", FullName: "Other test", Profile: Profile{Email: &other}}, UserSecret: UserSecret{Password: "hash"}},
+	}})
+	s.ErrorIs(err, ErrEmailTaken)
+}
+
+func (s *conformanceSuite) TestGetUsersPagesByID() {
+	ids := map[uuid.UUID]bool{}
+	for i := 0; i < 5; i++ {
+		ids[s.insert(fmt.Sprintf("+628123456789%d", i), "Test test")] = true
+	}
+	deleted := s.insert("+6281234567899", "Deleted test")
+	s.softDelete(deleted)
+
+	var seen []uuid.UUID
+	after := uuid.Nil
+	for {
+		users, err := s.repo.GetUsers(s.ctx, GetUsersInput{AfterID: after, Limit: 2})
+		s.Require().NoError(err)
+		if len(users) == 0 {
+			break
+		}
+		for _, user := range users {
+			s.True(ids[user.ID], "unexpected user %s", user.ID)
+			s.Equal("Test test", user.FullName)
+			s.Equal(UserSecret{Password: "hash", PasswordSalt: "salt"}, user.UserSecret)
+			s.False(user.CreatedAt.IsZero())
+			if len(seen) > 0 {
+				s.Less(seen[len(seen)-1].String(), user.ID.String())
+			}
+			seen = append(seen, user.ID)
+		}
+		after = users[len(users)-1].ID
+	}
+	s.Len(seen, 5)
+}
+
+func (s *conformanceSuite) TestUpdateLastLogin() {
+	id := s.insert("+6281234567890", "Test test")
+	now := time.Now()
+	s.NoError(s.repo.UpdateLastLoginAndSuccessfullyLogin(s.ctx, UpdateLastLoginAndSuccessfullyLoginInput{ID: id, LastLogin: &now}))
+	// Unknown users are ignored like by an UPDATE matching no row.
+	s.NoError(s.repo.UpdateLastLoginAndSuccessfullyLogin(s.ctx, UpdateLastLoginAndSuccessfullyLoginInput{ID: uuid.New(), LastLogin: &now}))
+}
+
+func (s *conformanceSuite) TestSoftDeletedUsersAreHiddenButKeepTheirNumber() {
+	id := s.insert("+6281234567890", "Test test")
+	s.softDelete(id)
+
+	_, err := s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.GetUserByFullName(s.ctx, GetUserByFullNameInput{FullName: "Test test"})
+	s.ErrorIs(err, ErrNotFound)
+	_, err = s.repo.UpdateUserAvatar(s.ctx, UpdateUserAvatarInput{ID: id, AvatarKey: "avatars/key"})
+	s.ErrorIs(err, ErrNotFound)
+
+	_, err = s.repo.InsertUser(s.ctx, User{UserInfo: UserInfo{PhoneNumber: "+6281234567890", FullName: "Test again"}})
+	s.ErrorIs(err, ErrPhoneNumberTaken)
+}
+
+func (s *conformanceSuite) TestWithTx() {
+	fnErr := errors.New("failed")
+	err := s.repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+		_, err := repo.InsertUser(s.ctx, User{UserInfo: UserInfo{PhoneNumber: "+6281234567890", FullName: "Test test"}})
+		s.Require().NoError(err)
+		// The transaction sees its own writes.
+		_, err = repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+		s.Require().NoError(err)
+		return fnErr
+	})
+	s.ErrorIs(err, fnErr)
+	_, err = s.repo.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "+6281234567890"})
+	s.ErrorIs(err, ErrNotFound, "rolled back insert is visible")
+
+	var id uuid.UUID
+	err = s.repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+		output, err := repo.InsertUser(s.ctx, User{UserInfo: UserInfo{PhoneNumber: "+6281234567890", FullName: "Test test"}})
+		id = output.ID
+		return err
+	})
+	s.Require().NoError(err)
+	_, err = s.repo.GetUserByID(s.ctx, GetUserByIDInput{ID: id})
+	s.NoError(err)
+}
+
+func (s *conformanceSuite) TestOutbox() {
+	first, second := uuid.New(), uuid.New()
+	fnErr := errors.New("failed")
+	err := s.repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+		s.Require().NoError(repo.InsertOutboxEvent(s.ctx, InsertOutboxEventInput{UserID: first, Type: "Rolledback", Payload: []byte(`{}`)}))
+		return fnErr
+	})
+	s.ErrorIs(err, fnErr)
+	for i, id := range []uuid.UUID{first, second, first} {
+		s.Require().NoError(s.repo.InsertOutboxEvent(s.ctx, InsertOutboxEventInput{UserID: id, Type: "Test", Payload: []byte(fmt.Sprintf(`{"n":%d}`, i))}))
+	}
+
+	var events []OutboxEvent
+	err = s.repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+		locked, err := repo.LockOutbox(s.ctx)
+		s.Require().NoError(err)
+		s.True(locked)
+		events, err = repo.GetPendingOutboxEvents(s.ctx, GetPendingOutboxEventsInput{Limit: 10})
+		return err
+	})
+	s.Require().NoError(err)
+	s.Require().Len(events, 3)
+	for i, event := range events {
+		s.Equal("Test", event.Type)
+		s.JSONEq(fmt.Sprintf(`{"n":%d}`, i), string(event.Payload))
+		s.WithinDuration(time.Now(), event.CreatedAt, time.Minute)
+		s.Zero(event.Attempts)
+		s.Nil(event.NextAttemptAt)
+	}
+	s.Equal([]uuid.UUID{first, second, first}, []uuid.UUID{events[0].UserID, events[1].UserID, events[2].UserID})
+	s.Less(events[0].ID, events[1].ID)
+	s.Less(events[1].ID, events[2].ID)
+
+	next := time.Now().Add(time.Minute).Truncate(time.Second)
+	s.Require().NoError(s.repo.RecordOutboxEventFailure(s.ctx, RecordOutboxEventFailureInput{ID: events[0].ID, Error: "unreachable", NextAttemptAt: next}))
+	s.Require().NoError(s.repo.MarkOutboxEventsDispatched(s.ctx, MarkOutboxEventsDispatchedInput{IDs: []int64{events[1].ID}, DispatchedAt: time.Now()}))
+
+	pending, err := s.repo.GetPendingOutboxEvents(s.ctx, GetPendingOutboxEventsInput{Limit: 1, Now: next})
+	s.Require().NoError(err)
+	s.Require().Len(pending, 1)
+	s.Equal(events[0].ID, pending[0].ID)
+	s.Equal(1, pending[0].Attempts)
+	s.Equal("unreachable", *pending[0].LastError)
+	s.True(next.Equal(*pending[0].NextAttemptAt))
+
+	pending, err = s.repo.GetPendingOutboxEvents(s.ctx, GetPendingOutboxEventsInput{Limit: 10, Now: next})
+	s.Require().NoError(err)
+	s.Len(pending, 2)
+
+	// Before its next attempt the failed event holds back the later event
+	// of its user.
+	pending, err = s.repo.GetPendingOutboxEvents(s.ctx, GetPendingOutboxEventsInput{Limit: 10, Now: time.Now()})
+	s.Require().NoError(err)
+	s.Empty(pending)
+}
+
+func (s *conformanceSuite) TestNestedWithTxUndoesOnlyItsWrites() {
+	id := uuid.New()
+	fnErr := errors.New("failed")
+	err := s.repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+		s.Require().NoError(repo.InsertOutboxEvent(s.ctx, InsertOutboxEventInput{UserID: id, Type: "Kept", Payload: []byte(`{}`)}))
+		err := repo.WithTx(s.ctx, func(repo RepositoryInterface) error {
+			s.Require().NoError(repo.InsertOutboxEvent(s.ctx, InsertOutboxEventInput{UserID: id, Type: "Undone", Payload: []byte(`{}`)}))
+			return fnErr
+		})
+		s.ErrorIs(err, fnErr)
+		return nil
+	})
+	s.Require().NoError(err)
+
+	pending, err := s.repo.GetPendingOutboxEvents(s.ctx, GetPendingOutboxEventsInput{Limit: 10, Now: time.Now()})
+	s.Require().NoError(err)
+	s.Require().Len(pending, 1)
+	s.Equal("Kept", pending[0].Type)
+}
+
+func (s *conformanceSuite) TestWebhooks() {
+	first, err := s.repo.InsertWebhook(s.ctx, InsertWebhookInput{URL: "https://example.com/first", Events: []string{"UserRegistered"}, Secret: "secret"})
+	s.Require().NoError(err)
+	second, err := s.repo.InsertWebhook(s.ctx, InsertWebhookInput{URL: "https://example.com/second", Secret: "secret"})
+	s.Require().NoError(err)
+
+	webhooks, err := s.repo.GetWebhooks(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Len(webhooks, 2)
+	s.Equal(first.ID, webhooks[0].ID)
+	s.Equal([]string{"UserRegistered"}, webhooks[0].Events)
+	s.Equal(second.ID, webhooks[1].ID)
+	s.Empty(webhooks[1].Events)
+
+	webhook, err := s.repo.GetWebhookByID(s.ctx, GetWebhookByIDInput{ID: second.ID})
+	s.Require().NoError(err)
+	s.Equal("https://example.com/second", webhook.URL)
+	s.Equal("secret", webhook.Secret)
+	s.WithinDuration(time.Now(), webhook.CreatedAt, time.Minute)
+
+	s.Require().NoError(s.repo.DeleteWebhook(s.ctx, DeleteWebhookInput{ID: second.ID}))
+	_, err = s.repo.GetWebhookByID(s.ctx, GetWebhookByIDInput{ID: second.ID})
+	s.ErrorIs(err, ErrNotFound)
+	s.ErrorIs(s.repo.DeleteWebhook(s.ctx, DeleteWebhookInput{ID: second.ID}), ErrNotFound)
+	webhooks, err = s.repo.GetWebhooks(s.ctx)
+	s.Require().NoError(err)
+	s.Len(webhooks, 1)
+}
+
+func (s *conformanceSuite) TestWebhookDeliveries() {
+	webhook, err := s.repo.InsertWebhook(s.ctx, InsertWebhookInput{URL: "https://example.com", Secret: "secret"})
+	s.Require().NoError(err)
+	now := time.Now().Truncate(time.Second)
+	for i := int64(1); i <= 2; i++ {
+		input := InsertWebhookDeliveriesInput{
+			WebhookIDs:    []uuid.UUID{webhook.ID},
+			EventID:       i,
+			EventType:     "Test",
+			Payload:       []byte(fmt.Sprintf(`{"n":%d}`, i)),
+			NextAttemptAt: now,
+		}
+		s.Require().NoError(s.repo.InsertWebhookDeliveries(s.ctx, input))
+		// Delivering an event again does not duplicate it.
+		s.Require().NoError(s.repo.InsertWebhookDeliveries(s.ctx, input))
+	}
+
+	claimed, err := s.repo.ClaimWebhookDeliveries(s.ctx, ClaimWebhookDeliveriesInput{Now: now, LeaseUntil: now.Add(time.Minute), Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 2)
+	s.Equal(int64(1), claimed[0].EventID)
+	s.Equal(webhook.ID, claimed[0].WebhookID)
+	s.Equal(WebhookDeliveryPending, claimed[0].Status)
+	s.JSONEq(`{"n":1}`, string(claimed[0].Payload))
+	s.Equal(int64(2), claimed[1].EventID)
+	claimedAgain, err := s.repo.ClaimWebhookDeliveries(s.ctx, ClaimWebhookDeliveriesInput{Now: now, LeaseUntil: now.Add(time.Minute), Limit: 10})
+	s.Require().NoError(err)
+	s.Empty(claimedAgain)
+
+	statusCode, errMessage := 500, "unexpected status 500"
+	retry := now.Add(time.Second)
+	s.Require().NoError(s.repo.RecordWebhookDeliveryAttempt(s.ctx, RecordWebhookDeliveryAttemptInput{
+		DeliveryID:    claimed[0].ID,
+		Attempt:       WebhookDeliveryAttempt{AttemptedAt: now, StatusCode: &statusCode, Error: &errMessage, Duration: 20 * time.Millisecond},
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: &retry,
+	}))
+	statusCode = 204
+	s.Require().NoError(s.repo.RecordWebhookDeliveryAttempt(s.ctx, RecordWebhookDeliveryAttemptInput{
+		DeliveryID: claimed[0].ID,
+		Attempt:    WebhookDeliveryAttempt{AttemptedAt: retry, StatusCode: &statusCode, Duration: 10 * time.Millisecond},
+		Status:     WebhookDeliverySucceeded,
+	}))
+	s.Require().NoError(s.repo.RecordWebhookDeliveryAttempt(s.ctx, RecordWebhookDeliveryAttemptInput{
+		DeliveryID: claimed[1].ID,
+		Attempt:    WebhookDeliveryAttempt{AttemptedAt: now, Error: &errMessage},
+		Status:     WebhookDeliveryDead,
+	}))
+
+	delivery, err := s.repo.GetWebhookDelivery(s.ctx, GetWebhookDeliveryInput{WebhookID: webhook.ID, ID: claimed[0].ID})
+	s.Require().NoError(err)
+	s.Equal(WebhookDeliverySucceeded, delivery.Status)
+	s.Equal(2, delivery.Attempts)
+	s.Nil(delivery.NextAttemptAt)
+	s.True(retry.Equal(*delivery.DeliveredAt))
+	s.Require().Len(delivery.Log, 2)
+	s.Equal(500, *delivery.Log[0].StatusCode)
+	s.Equal(errMessage, *delivery.Log[0].Error)
+	s.Equal(20*time.Millisecond, delivery.Log[0].Duration)
+	s.Equal(204, *delivery.Log[1].StatusCode)
+	s.Nil(delivery.Log[1].Error)
+	_, err = s.repo.GetWebhookDelivery(s.ctx, GetWebhookDeliveryInput{WebhookID: uuid.New(), ID: claimed[0].ID})
+	s.ErrorIs(err, ErrNotFound)
+
+	deliveries, err := s.repo.GetWebhookDeliveries(s.ctx, GetWebhookDeliveriesInput{WebhookID: webhook.ID, Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(deliveries, 2)
+	s.Equal(claimed[1].ID, deliveries[0].ID)
+	dead := WebhookDeliveryDead
+	deliveries, err = s.repo.GetWebhookDeliveries(s.ctx, GetWebhookDeliveriesInput{WebhookID: webhook.ID, Status: &dead, Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(deliveries, 1)
+	s.Nil(deliveries[0].LastStatusCode)
+	s.Equal(errMessage, *deliveries[0].LastError)
+
+	s.Require().NoError(s.repo.RedeliverWebhookDelivery(s.ctx, RedeliverWebhookDeliveryInput{WebhookID: webhook.ID, ID: claimed[1].ID, At: now}))
+	s.ErrorIs(s.repo.RedeliverWebhookDelivery(s.ctx, RedeliverWebhookDeliveryInput{WebhookID: webhook.ID, ID: claimed[1].ID + 100, At: now}), ErrNotFound)
+	claimed, err = s.repo.ClaimWebhookDeliveries(s.ctx, ClaimWebhookDeliveriesInput{Now: now, LeaseUntil: now.Add(time.Minute), Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1)
+	s.Equal(int64(2), claimed[0].EventID)
+	s.Zero(claimed[0].Attempts)
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The repository returns these errors, possibly wrapped, instead of the
+// errors of database/sql and the driver, test for them with errors.Is.
+var (
+	// ErrNotFound is returned when the requested user does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a unique constraint.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is returned when the database cannot be reached, the
+	// call may succeed when retried later.
+	ErrUnavailable = errors.New("database unavailable")
+
+	// ErrPhoneNumberTaken is the ErrConflict of the unique phone number
+	// constraint, e.g. when two registrations for the same number race.
+	ErrPhoneNumberTaken = fmt.Errorf("phone number already exists: %w", ErrConflict)
+	// ErrEmailTaken is the ErrConflict of the unique email index.
+	ErrEmailTaken = fmt.Errorf("email already exists: %w", ErrConflict)
+)
+
+// uniqueViolation is the SQLSTATE of unique_violation.
+const uniqueViolation = "23505"
+
+// unavailableClasses are the SQLSTATE classes meaning the server can not
+// serve us right now: connection exception, insufficient resources and
+// operator intervention, e.g. a shutdown.
+var unavailableClasses = map[pq.ErrorClass]bool{
+	"08": true,
+	"53": true,
+	"57": true,
+}
+
+// mapError translates err into the errors of the repository. Errors it does
+// not know are returned unchanged.
+func mapError(err error) error {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrConflict),
+		errors.Is(err, ErrUnavailable):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == uniqueViolation && pqErr.Constraint == "users_phone_number_key":
+			return ErrPhoneNumberTaken
+		case pqErr.Code == uniqueViolation && pqErr.Constraint == "users_email_key":
+			return ErrEmailTaken
+		case pqErr.Code == uniqueViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case unavailableClasses[pqErr.Code.Class()]:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// SQLite names the column of a column constraint and the index of an
+		// index, see sqliteSchema.
+		msg := sqliteErr.Error()
+		switch {
+		case sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(msg, "users.phone_number"):
+			return ErrPhoneNumberTaken
+		case sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(msg, "users_email_key"):
+			return ErrEmailTaken
+		case sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY, sqliteErr.Code()&0xff == sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapError(t *testing.T) {
+	other := errors.New("syntax error")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"phone number taken", &pq.Error{Code: uniqueViolation, Constraint: "users_phone_number_key"}, ErrPhoneNumberTaken},
+		{"email taken", &pq.Error{Code: uniqueViolation, Constraint: "users_email_key"}, ErrEmailTaken},
+		{"other unique violation", &pq.Error{Code: uniqueViolation, Constraint: "users_pkey"}, ErrConflict},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, ErrUnavailable},
+		{"too many connections", &pq.Error{Code: "53300"}, ErrUnavailable},
+		{"bad connection", driver.ErrBadConn, ErrUnavailable},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrUnavailable},
+		{"other", other, other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mapError(tt.err)
+			if tt.want == nil {
+				require.NoError(t, got)
+				return
+			}
+			require.ErrorIs(t, got, tt.want)
+			// Mapping twice must not wrap again.
+			require.Equal(t, got, mapError(got))
+		})
+	}
+	require.ErrorIs(t, ErrPhoneNumberTaken, ErrConflict)
+	require.ErrorIs(t, ErrEmailTaken, ErrConflict)
+}
+
+func TestInsertUserPhoneNumberTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO users(phone_number, full_name, password, password_salt) VALUES($1,$2,$3,$4) RETURNING id")).
+		ExpectQuery().
+		WillReturnError(&pq.Error{Code: uniqueViolation, Constraint: "users_phone_number_key"})
+
+	_, err = r.InsertUser(context.Background(), User{})
+	require.ErrorIs(t, err, ErrPhoneNumberTaken)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByIDNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL")).
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number"}))
+
+	_, err = r.GetUserByID(context.Background(), GetUserByIDInput{})
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
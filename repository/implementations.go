@@ -1,18 +1,29 @@
 package repository
 
 import (
+	"InterviewBackendSawitProGolang/pkg/phone"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 func (r *Repository) InsertUser(ctx context.Context, input User) (output InsertUserOutput, err error) {
-	stmt, err := r.Db.PrepareContext(ctx, "INSERT INTO users(phone_number, full_name, password, password_salt) VALUES($1,$2,$3,$4) RETURNING id")
+	ctx, span := startSpan(ctx, "Repository.InsertUser")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "INSERT INTO users(phone_number, full_name, password, password_salt) VALUES($1,$2,$3,$4) RETURNING id")
 	if err != nil {
 		return
 	}
 
-	err = stmt.QueryRowContext(ctx, input.PhoneNumber, input.FullName, input.Password, input.PasswordSalt).Scan(&output.ID)
+	err = stmt.QueryRowContext(ctx, phone.Canonical(input.PhoneNumber), input.FullName, input.Password, input.PasswordSalt).Scan(&output.ID)
 	if err != nil {
 		return
 	}
@@ -21,88 +32,670 @@ func (r *Repository) InsertUser(ctx context.Context, input User) (output InsertU
 }
 
 func (r *Repository) GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (output User, err error) {
-	stmt, err := r.Db.PrepareContext(ctx, "SELECT id, phone_number, full_name, password, password_salt FROM users WHERE phone_number = $1")
+	return r.GetUserByLoginIdentifier(ctx, GetUserByLoginIdentifierInput{
+		Type:  LoginIdentifierPhoneNumber,
+		Value: input.PhoneNumber,
+	})
+}
+
+func (r *Repository) GetUserByLoginIdentifier(ctx context.Context, input GetUserByLoginIdentifierInput) (output User, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetUserByLoginIdentifier")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	var query, value string
+	switch input.Type {
+	case LoginIdentifierPhoneNumber:
+		query = "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = $1 AND deleted_at IS NULL"
+		value = phone.Canonical(input.Value)
+	case LoginIdentifierEmail:
+		query = "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL"
+		value = input.Value
+	default:
+		err = fmt.Errorf("unknown login identifier type %q", input.Type)
+		return
+	}
+
+	err = r.read(ctx, query, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, value).Scan(
+			&output.ID,
+			&output.PhoneNumber,
+			&output.FullName,
+			&output.Email,
+			&output.EmailVerifiedAt,
+			&output.SuspendedAt,
+			&output.Password,
+			&output.PasswordSalt,
+		)
+	})
+	return
+}
+
+func (r *Repository) GetUserByID(ctx context.Context, input GetUserByIDInput) (output UserInfo, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetUserByID")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	err = r.read(ctx, "SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, input.ID.String()).Scan(
+			&output.PhoneNumber,
+			&output.FullName,
+			&output.Email,
+			&output.EmailVerifiedAt,
+			&output.AvatarKey,
+			&output.SuspendedAt,
+			&output.TokensValidAfter,
+			&output.DateOfBirth,
+			&output.Gender,
+			&output.Address,
+		)
+	})
+	return
+}
+
+func (r *Repository) GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (output UserInfo, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetUserByFullName")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	err = r.read(ctx, "SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, input.FullName).Scan(
+			&output.PhoneNumber,
+			&output.FullName,
+		)
+	})
+	return
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateUser")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE users SET phone_number = $1, full_name = $2, email = COALESCE($3, email), email_verified_at = CASE WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE($4, date_of_birth), gender = COALESCE($5, gender), address = COALESCE($6, address) WHERE id = $7 AND deleted_at IS NULL")
 	if err != nil {
 		return
 	}
 
-	err = stmt.QueryRowContext(ctx, input.PhoneNumber).Scan(
-		&output.ID,
-		&output.PhoneNumber,
-		&output.FullName,
-		&output.Password,
-		&output.PasswordSalt,
-	)
+	_, err = stmt.ExecContext(ctx, phone.Canonical(input.PhoneNumber), input.FullName, input.Email, input.DateOfBirth, input.Gender, input.Address, input.ID)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (r *Repository) UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateLastLoginAndSuccessfullyLogin")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
 
+	stmt, err := r.prepare(ctx, "UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2 AND deleted_at IS NULL")
 	if err != nil {
-		fmt.Println(sql.ErrNoRows)
-		if err != sql.ErrNoRows {
-			return
+		return
+	}
+
+	_, err = stmt.ExecContext(ctx, input.LastLogin, input.ID)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetUsersPhoneNumber and UpdateUserPhoneNumber serve cmd/normalizephone,
+// which only runs against Postgres, so they are not part of
+// RepositoryInterface.
+func (r *Repository) GetUsersPhoneNumber(ctx context.Context) (output []UserPhoneNumber, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetUsersPhoneNumber")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	// The listing feeds phone number updates, read it from the primary.
+	stmt, err := r.prepare(ctx, "SELECT id, phone_number FROM users ORDER BY created_at")
+	if err != nil {
+		return
+	}
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user UserPhoneNumber
+		if err = rows.Scan(&user.ID, &user.PhoneNumber); err != nil {
+			return nil, err
 		}
+		output = append(output, user)
 	}
+	err = rows.Err()
+	return
+}
+
+func (r *Repository) UpdateUserPhoneNumber(ctx context.Context, input UpdateUserPhoneNumberInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateUserPhoneNumber")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
 
+	stmt, err := r.prepare(ctx, "UPDATE users SET phone_number = $1 WHERE id = $2")
+	if err != nil {
+		return
+	}
+	_, err = stmt.ExecContext(ctx, input.PhoneNumber, input.ID)
+	if err != nil {
+		return
+	}
 	return
 }
 
-func (r *Repository) GetUserByID(ctx context.Context, input GetUserByIDInput) (output UserInfo, err error) {
-	fmt.Println(input.ID.String())
-	stmt, err := r.Db.PrepareContext(ctx, "SELECT phone_number, full_name FROM users WHERE id = $1")
+func (r *Repository) VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error) {
+	ctx, span := startSpan(ctx, "Repository.VerifyEmail")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE users SET email_verified_at = $1 WHERE id = $2 AND LOWER(email) = LOWER($3) AND deleted_at IS NULL")
 	if err != nil {
 		return
 	}
 
-	err = stmt.QueryRowContext(ctx, input.ID.String()).Scan(
-		&output.PhoneNumber,
-		&output.FullName,
-	)
+	result, err := stmt.ExecContext(ctx, input.VerifiedAt, input.ID, input.Email)
+	if err != nil {
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	output.Verified = affected > 0
+	return
+}
 
+func (r *Repository) UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (output UpdateUserAvatarOutput, err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateUserAvatar")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE users u SET avatar_key = $1 FROM (SELECT avatar_key FROM users WHERE id = $2 AND deleted_at IS NULL FOR UPDATE) previous WHERE u.id = $2 RETURNING previous.avatar_key")
 	if err != nil {
 		return
 	}
 
+	err = stmt.QueryRowContext(ctx, input.AvatarKey, input.ID).Scan(&output.PreviousAvatarKey)
+	if err != nil {
+		return
+	}
 	return
 }
 
-func (r *Repository) GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (output UserInfo, err error) {
-	stmt, err := r.Db.PrepareContext(ctx, "SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1)")
+func (r *Repository) UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateUserPassword")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE users SET password = $1, password_salt = $2, tokens_valid_after = $3 WHERE id = $4 AND deleted_at IS NULL")
+	if err != nil {
+		return
+	}
+	result, err := stmt.ExecContext(ctx, input.Password, input.PasswordSalt, input.TokensValidAfter, input.ID)
+	if err != nil {
+		return
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.UpdateUserSuspension")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE users SET suspended_at = $1, tokens_valid_after = COALESCE($1, tokens_valid_after) WHERE id = $2 AND deleted_at IS NULL")
 	if err != nil {
 		return
 	}
+	result, err := stmt.ExecContext(ctx, input.SuspendedAt, input.ID)
+	if err != nil {
+		return
+	}
+	return requireAffected(result)
+}
+
+func (r *Repository) GetRecentUsers(ctx context.Context, input GetRecentUsersInput) (output []RecentUser, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetRecentUsers")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	err = r.read(ctx, "SELECT id, phone_number, full_name, email, created_at, suspended_at FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id LIMIT $1", func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, input.Limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		output, err = scanRecentUsers(rows)
+		return err
+	})
+	return
+}
+
+func (r *Repository) InsertUsers(ctx context.Context, input InsertUsersInput) (output InsertUsersOutput, err error) {
+	ctx, span := startSpan(ctx, "Repository.InsertUsers")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	n := len(input.Users)
+	ids := make([]string, n)
+	phoneNumbers := make([]string, n)
+	fullNames := make([]string, n)
+	emails := make([]sql.NullString, n)
+	emailVerifiedAts := make([]sql.NullString, n)
+	suspendedAts := make([]sql.NullString, n)
+	passwords := make([]string, n)
+	salts := make([]string, n)
+	output.IDs = make([]uuid.UUID, n)
+	for i, user := range input.Users {
+		output.IDs[i] = uuid.New()
+		ids[i] = output.IDs[i].String()
+		phoneNumbers[i] = phone.Canonical(user.PhoneNumber)
+		fullNames[i] = user.FullName
+		if user.Email != nil {
+			emails[i] = sql.NullString{String: *user.Email, Valid: true}
+		}
+		emailVerifiedAts[i] = nullTimestamp(user.EmailVerifiedAt)
+		suspendedAts[i] = nullTimestamp(user.SuspendedAt)
+		passwords[i] = user.Password
+		salts[i] = user.PasswordSalt
+	}
+
+	// One statement whatever the number of users, unlike a VALUES list.
+	stmt, err := r.prepare(ctx, "INSERT INTO users(id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt) SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::timestamptz[], $6::timestamptz[], $7::text[], $8::text[])")
+	if err != nil {
+		return InsertUsersOutput{}, err
+	}
+	_, err = stmt.ExecContext(ctx, pq.Array(ids), pq.Array(phoneNumbers), pq.Array(fullNames), pq.Array(emails), pq.Array(emailVerifiedAts), pq.Array(suspendedAts), pq.Array(passwords), pq.Array(salts))
+	if err != nil {
+		return InsertUsersOutput{}, err
+	}
+	return
+}
+
+// nullTimestamp formats t for a timestamptz array, which pq.Array only
+// takes as text.
+func nullTimestamp(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+func (r *Repository) GetUsers(ctx context.Context, input GetUsersInput) (output []StoredUser, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetUsers")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	err = r.read(ctx, "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt, created_at FROM users WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT $2", func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, input.AfterID, input.Limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		output, err = scanStoredUsers(rows)
+		return err
+	})
+	return
+}
+
+func scanStoredUsers(rows *sql.Rows) (output []StoredUser, err error) {
+	for rows.Next() {
+		var user StoredUser
+		if err = rows.Scan(&user.ID, &user.PhoneNumber, &user.FullName, &user.Email, &user.EmailVerifiedAt, &user.SuspendedAt, &user.Password, &user.PasswordSalt, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		output = append(output, user)
+	}
+	return output, rows.Err()
+}
+
+func scanRecentUsers(rows *sql.Rows) (output []RecentUser, err error) {
+	for rows.Next() {
+		var user RecentUser
+		if err = rows.Scan(&user.ID, &user.PhoneNumber, &user.FullName, &user.Email, &user.CreatedAt, &user.SuspendedAt); err != nil {
+			return nil, err
+		}
+		output = append(output, user)
+	}
+	return output, rows.Err()
+}
 
-	err = stmt.QueryRowContext(ctx, input.FullName).Scan(
-		&output.PhoneNumber,
-		&output.FullName,
+// requireAffected returns ErrNotFound when an update changed no row.
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// outboxLockKey is the advisory lock held by the running outbox dispatcher.
+const outboxLockKey = 0x6f7574626f78
+
+func (r *Repository) InsertOutboxEvent(ctx context.Context, input InsertOutboxEventInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.InsertOutboxEvent")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "INSERT INTO outbox_events(user_id, type, payload) VALUES($1,$2,$3)")
+	if err != nil {
+		return
+	}
+	// lib/pq sends []byte as bytea, which jsonb does not accept.
+	_, err = stmt.ExecContext(ctx, input.UserID, input.Type, string(input.Payload))
+	return
+}
+
+func (r *Repository) LockOutbox(ctx context.Context) (locked bool, err error) {
+	ctx, span := startSpan(ctx, "Repository.LockOutbox")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	// The lock is released when the transaction ends.
+	if r.tx == nil {
+		err = errors.New("LockOutbox must be called in a transaction")
+		return
+	}
+	stmt, err := r.prepare(ctx, "SELECT pg_try_advisory_xact_lock($1)")
+	if err != nil {
+		return
+	}
+	err = stmt.QueryRowContext(ctx, outboxLockKey).Scan(&locked)
+	return
+}
+
+func (r *Repository) GetPendingOutboxEvents(ctx context.Context, input GetPendingOutboxEventsInput) (output []OutboxEvent, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetPendingOutboxEvents")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "SELECT e.id, e.user_id, e.type, e.payload, e.created_at, e.attempts, e.next_attempt_at, e.last_error FROM outbox_events e WHERE e.dispatched_at IS NULL AND NOT EXISTS (SELECT 1 FROM outbox_events held WHERE held.user_id = e.user_id AND held.id <= e.id AND held.dispatched_at IS NULL AND held.next_attempt_at > $2) ORDER BY e.id LIMIT $1")
+	if err != nil {
+		return
+	}
+	rows, err := stmt.QueryContext(ctx, input.Limit, input.Now)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event OutboxEvent
+		if err = rows.Scan(&event.ID, &event.UserID, &event.Type, (*[]byte)(&event.Payload), &event.CreatedAt, &event.Attempts, &event.NextAttemptAt, &event.LastError); err != nil {
+			return nil, err
+		}
+		output = append(output, event)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *Repository) MarkOutboxEventsDispatched(ctx context.Context, input MarkOutboxEventsDispatchedInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.MarkOutboxEventsDispatched")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	if len(input.IDs) == 0 {
+		return
+	}
+	stmt, err := r.prepare(ctx, "UPDATE outbox_events SET dispatched_at = $1 WHERE id = ANY($2)")
+	if err != nil {
+		return
+	}
+	_, err = stmt.ExecContext(ctx, input.DispatchedAt, pq.Array(input.IDs))
+	return
+}
+
+func (r *Repository) RecordOutboxEventFailure(ctx context.Context, input RecordOutboxEventFailureInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.RecordOutboxEventFailure")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "UPDATE outbox_events SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3")
+	if err != nil {
+		return
+	}
+	_, err = stmt.ExecContext(ctx, input.Error, input.NextAttemptAt, input.ID)
+	return
+}
+
+func (r *Repository) InsertWebhook(ctx context.Context, input InsertWebhookInput) (output InsertWebhookOutput, err error) {
+	ctx, span := startSpan(ctx, "Repository.InsertWebhook")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "INSERT INTO webhooks(url, events, secret) VALUES($1,$2,$3) RETURNING id, created_at")
+	if err != nil {
+		return
+	}
+	err = stmt.QueryRowContext(ctx, input.URL, pq.Array(input.Events), input.Secret).Scan(&output.ID, &output.CreatedAt)
+	return
+}
+
+func (r *Repository) GetWebhooks(ctx context.Context) (output []Webhook, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetWebhooks")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE deleted_at IS NULL ORDER BY created_at")
+	if err != nil {
+		return
+	}
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook Webhook
+		if err = rows.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		output = append(output, webhook)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *Repository) GetWebhookByID(ctx context.Context, input GetWebhookByIDInput) (output Webhook, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetWebhookByID")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE id = $1 AND deleted_at IS NULL")
+	if err != nil {
+		return
+	}
+	err = stmt.QueryRowContext(ctx, input.ID).Scan(&output.ID, &output.URL, pq.Array(&output.Events), &output.Secret, &output.CreatedAt)
+	return
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, input DeleteWebhookInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.DeleteWebhook")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE webhooks SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL")
+	if err != nil {
+		return
+	}
+	result, err := stmt.ExecContext(ctx, input.ID)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (r *Repository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.InsertWebhookDeliveries")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	if len(input.WebhookIDs) == 0 {
+		return
+	}
+	stmt, err := r.prepare(ctx, "INSERT INTO webhook_deliveries(webhook_id, event_id, event_type, payload, status, next_attempt_at) SELECT webhook_id, $2, $3, $4, 'pending', $5 FROM unnest($1::uuid[]) AS webhook_id ON CONFLICT (webhook_id, event_id) DO NOTHING")
+	if err != nil {
+		return
+	}
+	ids := make([]string, len(input.WebhookIDs))
+	for i, id := range input.WebhookIDs {
+		ids[i] = id.String()
+	}
+	_, err = stmt.ExecContext(ctx, pq.Array(ids), input.EventID, input.EventType, string(input.Payload), input.NextAttemptAt)
+	return
+}
+
+const webhookDeliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+// scanner is a *sql.Row or *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhookDelivery(row scanner) (delivery WebhookDelivery, err error) {
+	err = row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		(*[]byte)(&delivery.Payload),
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
 	)
+	return
+}
 
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "Repository.ClaimWebhookDeliveries")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $2 ORDER BY next_attempt_at, id LIMIT $3 FOR UPDATE SKIP LOCKED) RETURNING "+webhookDeliveryColumns)
 	if err != nil {
 		return
 	}
+	rows, err := stmt.QueryContext(ctx, input.LeaseUntil, input.Now, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if delivery, err = scanWebhookDelivery(rows); err != nil {
+			return nil, err
+		}
+		output = append(output, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	// RETURNING does not follow the order of the subquery.
+	sort.Slice(output, func(i, j int) bool { return output[i].ID < output[j].ID })
 	return
 }
 
-func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (err error) {
-	stmt, err := r.Db.PrepareContext(ctx, "UPDATE users SET phone_number = $1, full_name = $2 WHERE id = $3")
+func (r *Repository) RecordWebhookDeliveryAttempt(ctx context.Context, input RecordWebhookDeliveryAttemptInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.RecordWebhookDeliveryAttempt")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	var deliveredAt *time.Time
+	if input.Status == WebhookDeliverySucceeded {
+		deliveredAt = &input.Attempt.AttemptedAt
+	}
+	stmt, err := r.prepare(ctx, "WITH attempt AS (INSERT INTO webhook_delivery_attempts(delivery_id, attempted_at, status_code, error, duration_ms) VALUES($1,$2,$3,$4,$5)) UPDATE webhook_deliveries SET status = $6, attempts = attempts + 1, next_attempt_at = $7, last_status_code = $3, last_error = $4, delivered_at = $8 WHERE id = $1")
 	if err != nil {
 		return
 	}
+	_, err = stmt.ExecContext(ctx, input.DeliveryID, input.Attempt.AttemptedAt, input.Attempt.StatusCode, input.Attempt.Error,
+		input.Attempt.Duration.Milliseconds(), input.Status, input.NextAttemptAt, deliveredAt)
+	return
+}
 
-	_, err = stmt.ExecContext(ctx, input.PhoneNumber, input.FullName, input.ID)
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetWebhookDeliveries")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = $1 AND status = COALESCE($2, status) ORDER BY id DESC LIMIT $3")
 	if err != nil {
 		return
 	}
+	rows, err := stmt.QueryContext(ctx, input.WebhookID, input.Status, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if delivery, err = scanWebhookDelivery(rows); err != nil {
+			return nil, err
+		}
+		output = append(output, delivery)
+	}
+	err = rows.Err()
 	return
 }
 
-func (r *Repository) UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) (err error) {
-	stmt, err := r.Db.PrepareContext(ctx, "UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2")
+func (r *Repository) GetWebhookDelivery(ctx context.Context, input GetWebhookDeliveryInput) (output GetWebhookDeliveryOutput, err error) {
+	ctx, span := startSpan(ctx, "Repository.GetWebhookDelivery")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	stmt, err := r.prepare(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2")
 	if err != nil {
 		return
 	}
+	if output.WebhookDelivery, err = scanWebhookDelivery(stmt.QueryRowContext(ctx, input.ID, input.WebhookID)); err != nil {
+		return
+	}
 
-	_, err = stmt.ExecContext(ctx, input.LastLogin, input.ID)
+	stmt, err = r.prepare(ctx, "SELECT attempted_at, status_code, error, duration_ms FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY id")
 	if err != nil {
 		return
 	}
+	rows, err := stmt.QueryContext(ctx, input.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt WebhookDeliveryAttempt
+		var durationMs int64
+		if err = rows.Scan(&attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &durationMs); err != nil {
+			return
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		output.Log = append(output.Log, attempt)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *Repository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error) {
+	ctx, span := startSpan(ctx, "Repository.RedeliverWebhookDelivery")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+	markWrite(ctx)
+
+	stmt, err := r.prepare(ctx, "UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1 WHERE id = $2 AND webhook_id = $3")
+	if err != nil {
+		return
+	}
+	result, err := stmt.ExecContext(ctx, input.At, input.ID, input.WebhookID)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
 	return
 }
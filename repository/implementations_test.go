@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/go-test/deep"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"regexp"
 	"testing"
 	"time"
@@ -42,9 +43,6 @@ func (s *TestSuite) SetupSuite() {
 	s.curr = &curr
 	s.db, s.mock, err = sqlmock.New()
 	require.NoError(s.T(), err)
-	s.r = &Repository{
-		s.db,
-	}
 	s.ctx = context.TODO()
 	s.userInfo = UserInfo{
 		FullName:    "Test test",
@@ -81,6 +79,12 @@ func (s *TestSuite) SetupSuite() {
 	}
 }
 
+// SetupTest starts every test with an empty statement cache so each one
+// expects its own prepare.
+func (s *TestSuite) SetupTest() {
+	s.r = &Repository{Db: s.db}
+}
+
 func (s *TestSuite) AfterTest(_, _ string) {
 	require.NoError(s.T(), s.mock.ExpectationsWereMet())
 }
@@ -132,12 +136,15 @@ func (s *TestSuite) TestInsertUserFailed() {
 }
 
 func (s *TestSuite) TestGetUserByPhoneNumberSuccess() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, password_salt FROM users WHERE phone_number = $1	"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = $1 AND deleted_at IS NULL"))
 	prepare.ExpectQuery().
-		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password", "password_salt"}).AddRow(
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "email", "email_verified_at", "suspended_at", "password", "password_salt"}).AddRow(
 			s.user.ID,
 			s.user.PhoneNumber,
 			s.user.FullName,
+			nil,
+			nil,
+			nil,
 			s.user.Password,
 			s.user.PasswordSalt,
 		)).
@@ -150,7 +157,7 @@ func (s *TestSuite) TestGetUserByPhoneNumberSuccess() {
 }
 
 func (s *TestSuite) TestGetUserByPhoneNumberFailedPrepareQuery() {
-	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, password_salt FROM users WHERE phone_number = $1	")).
+	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = $1 AND deleted_at IS NULL")).
 		WillReturnError(fmt.Errorf("internal server error"))
 	output, err := s.r.GetUserByPhoneNumber(s.ctx, s.getUserByPhoneNumberInput)
 	require.Error(s.T(), err)
@@ -158,7 +165,7 @@ func (s *TestSuite) TestGetUserByPhoneNumberFailedPrepareQuery() {
 }
 
 func (s *TestSuite) TestGetUserByPhoneNumberFailed() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, password_salt FROM users WHERE phone_number = $1	"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = $1 AND deleted_at IS NULL"))
 	prepare.ExpectQuery().
 		WillReturnError(fmt.Errorf("internal server error")).
 		WithArgs(
@@ -170,11 +177,19 @@ func (s *TestSuite) TestGetUserByPhoneNumberFailed() {
 }
 
 func (s *TestSuite) TestGetUserByIDSuccess() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE id = $1"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL"))
 	prepare.ExpectQuery().
-		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow(
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name", "email", "email_verified_at", "avatar_key", "suspended_at", "tokens_valid_after", "date_of_birth", "gender", "address"}).AddRow(
 			s.user.PhoneNumber,
 			s.user.FullName,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 		)).
 		WithArgs(
 			s.user.ID,
@@ -185,7 +200,7 @@ func (s *TestSuite) TestGetUserByIDSuccess() {
 }
 
 func (s *TestSuite) TestGetUserByIDFailedPrepareQuery() {
-	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE id = $1")).
+	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL")).
 		WillReturnError(fmt.Errorf("sql: internal server error"))
 	output, err := s.r.GetUserByID(s.ctx, s.getUserByIDInput)
 	require.Error(s.T(), err)
@@ -193,7 +208,7 @@ func (s *TestSuite) TestGetUserByIDFailedPrepareQuery() {
 }
 
 func (s *TestSuite) TestGetUserByIDFailed() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE id = $1"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL"))
 	prepare.ExpectQuery().
 		WillReturnError(fmt.Errorf("sql: internal server error")).
 		WithArgs(
@@ -205,7 +220,7 @@ func (s *TestSuite) TestGetUserByIDFailed() {
 }
 
 func (s *TestSuite) TestGetUserByFullNameSuccess() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1)"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1"))
 	prepare.ExpectQuery().
 		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow(
 			s.user.PhoneNumber,
@@ -220,7 +235,7 @@ func (s *TestSuite) TestGetUserByFullNameSuccess() {
 }
 
 func (s *TestSuite) TestGetUserByFullNameFailedPrepareQuery() {
-	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1)")).
+	s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1")).
 		WillReturnError(fmt.Errorf("sql: internal server error"))
 	output, err := s.r.GetUserByFullName(s.ctx, s.getUserByFullName)
 	require.Error(s.T(), err)
@@ -228,7 +243,7 @@ func (s *TestSuite) TestGetUserByFullNameFailedPrepareQuery() {
 }
 
 func (s *TestSuite) TestGetUserByFullNameFailed() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1)"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1"))
 	prepare.ExpectQuery().
 		WillReturnError(fmt.Errorf("sql: internal server error")).
 		WithArgs(
@@ -240,11 +255,15 @@ func (s *TestSuite) TestGetUserByFullNameFailed() {
 }
 
 func (s *TestSuite) TestUpdateUserByIDSuccess() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2 WHERE id = $3"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, email = COALESCE($3, email), email_verified_at = CASE WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE($4, date_of_birth), gender = COALESCE($5, gender), address = COALESCE($6, address) WHERE id = $7 AND deleted_at IS NULL"))
 	prepare.ExpectExec().
 		WithArgs(
 			s.user.PhoneNumber,
 			s.user.FullName,
+			nil,
+			nil,
+			nil,
+			nil,
 			s.user.ID,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -253,19 +272,23 @@ func (s *TestSuite) TestUpdateUserByIDSuccess() {
 }
 
 func (s *TestSuite) TestUpdateUserByIDFailedPrepareQuery() {
-	s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2 WHERE id = $3")).
+	s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, email = COALESCE($3, email), email_verified_at = CASE WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE($4, date_of_birth), gender = COALESCE($5, gender), address = COALESCE($6, address) WHERE id = $7 AND deleted_at IS NULL")).
 		WillReturnError(fmt.Errorf("sql: internal server error"))
 	err := s.r.UpdateUser(s.ctx, s.updateUserInput)
 	require.Error(s.T(), err)
 }
 
 func (s *TestSuite) TestUpdateUserByIDFailed() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2 WHERE id = $3"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, email = COALESCE($3, email), email_verified_at = CASE WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE($4, date_of_birth), gender = COALESCE($5, gender), address = COALESCE($6, address) WHERE id = $7 AND deleted_at IS NULL"))
 	prepare.ExpectExec().
 		WillReturnError(fmt.Errorf("sql: internal server error")).
 		WithArgs(
 			s.user.PhoneNumber,
 			s.user.FullName,
+			nil,
+			nil,
+			nil,
+			nil,
 			s.user.ID,
 		)
 	err := s.r.UpdateUser(s.ctx, s.updateUserInput)
@@ -273,7 +296,7 @@ func (s *TestSuite) TestUpdateUserByIDFailed() {
 }
 
 func (s *TestSuite) TestUpdateLastLoginAndSuccessfullyLoginSuccess() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2 AND deleted_at IS NULL"))
 	prepare.ExpectExec().
 		WithArgs(
 			s.curr,
@@ -284,14 +307,14 @@ func (s *TestSuite) TestUpdateLastLoginAndSuccessfullyLoginSuccess() {
 }
 
 func (s *TestSuite) TestUpdateLastLoginAndSuccessfullyLoginFailedPrepareQuery() {
-	s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2")).
+	s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2 AND deleted_at IS NULL")).
 		WillReturnError(fmt.Errorf("sql: internal server error"))
 	err := s.r.UpdateLastLoginAndSuccessfullyLogin(s.ctx, s.updateLastLoginAndSuccessfullyLoginInput)
 	require.Error(s.T(), err)
 }
 
 func (s *TestSuite) TestUpdateLastLoginAndSuccessfullyLoginByIDFailed() {
-	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2"))
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET successfully_login = (successfully_login + 1), last_login = $1 WHERE id = $2 AND deleted_at IS NULL"))
 	prepare.ExpectExec().
 		WithArgs(
 			s.curr,
@@ -301,3 +324,290 @@ func (s *TestSuite) TestUpdateLastLoginAndSuccessfullyLoginByIDFailed() {
 	err := s.r.UpdateLastLoginAndSuccessfullyLogin(s.ctx, s.updateLastLoginAndSuccessfullyLoginInput)
 	require.Error(s.T(), err)
 }
+
+func (s *TestSuite) TestGetUserByPhoneNumberNormalizesInput() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = $1 AND deleted_at IS NULL"))
+	prepare.ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "email", "email_verified_at", "suspended_at", "password", "password_salt"}).AddRow(
+			s.user.ID,
+			s.user.PhoneNumber,
+			s.user.FullName,
+			nil,
+			nil,
+			nil,
+			s.user.Password,
+			s.user.PasswordSalt,
+		)).
+		WithArgs(
+			s.user.PhoneNumber,
+		)
+	output, err := s.r.GetUserByPhoneNumber(s.ctx, GetUserByPhoneNumberInput{PhoneNumber: "0123-456-789"})
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output, s.user))
+}
+
+func (s *TestSuite) TestGetUsersPhoneNumberSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number FROM users ORDER BY created_at"))
+	prepare.ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(
+			s.user.ID,
+			s.user.PhoneNumber,
+		))
+	output, err := s.r.GetUsersPhoneNumber(s.ctx)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output, []UserPhoneNumber{{ID: s.user.ID, PhoneNumber: s.user.PhoneNumber}}))
+}
+
+func (s *TestSuite) TestGetUsersPhoneNumberFailed() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number FROM users ORDER BY created_at"))
+	prepare.ExpectQuery().
+		WillReturnError(fmt.Errorf("sql: internal server error"))
+	output, err := s.r.GetUsersPhoneNumber(s.ctx)
+	require.Error(s.T(), err)
+	require.Nil(s.T(), output)
+}
+
+func (s *TestSuite) TestUpdateUserPhoneNumberSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1 WHERE id = $2"))
+	prepare.ExpectExec().
+		WithArgs(
+			s.user.PhoneNumber,
+			s.user.ID,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	err := s.r.UpdateUserPhoneNumber(s.ctx, UpdateUserPhoneNumberInput{ID: s.user.ID, PhoneNumber: s.user.PhoneNumber})
+	require.NoError(s.T(), err)
+}
+
+func (s *TestSuite) TestGetUserByIDWithProfileSuccess() {
+	email := "test@example.com"
+	gender := "female"
+	dob := time.Date(1990, 12, 31, 0, 0, 0, 0, time.UTC)
+	address := &Address{Street: "Jl. Sudirman 1", City: "Jakarta", Country: "ID"}
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = $1 AND deleted_at IS NULL"))
+	prepare.ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name", "email", "email_verified_at", "avatar_key", "suspended_at", "tokens_valid_after", "date_of_birth", "gender", "address"}).AddRow(
+			s.user.PhoneNumber,
+			s.user.FullName,
+			email,
+			nil,
+			nil,
+			nil,
+			nil,
+			dob,
+			gender,
+			[]byte(`{"street":"Jl. Sudirman 1","city":"Jakarta","country":"ID"}`),
+		)).
+		WithArgs(
+			s.user.ID,
+		)
+	output, err := s.r.GetUserByID(s.ctx, s.getUserByIDInput)
+	require.NoError(s.T(), err)
+	expected := s.userInfo
+	expected.Profile = Profile{
+		Email:       &email,
+		DateOfBirth: &dob,
+		Gender:      &gender,
+		Address:     address,
+	}
+	require.Nil(s.T(), deep.Equal(output, expected))
+}
+
+func (s *TestSuite) TestUpdateUserWithProfileSuccess() {
+	email := "test@example.com"
+	address := &Address{Street: "Jl. Sudirman 1", City: "Jakarta", Country: "ID"}
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, email = COALESCE($3, email), email_verified_at = CASE WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE($4, date_of_birth), gender = COALESCE($5, gender), address = COALESCE($6, address) WHERE id = $7 AND deleted_at IS NULL"))
+	prepare.ExpectExec().
+		WithArgs(
+			s.user.PhoneNumber,
+			s.user.FullName,
+			email,
+			nil,
+			nil,
+			`{"street":"Jl. Sudirman 1","city":"Jakarta","country":"ID"}`,
+			s.user.ID,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	input := s.updateUserInput
+	input.Email = &email
+	input.Address = address
+	err := s.r.UpdateUser(s.ctx, input)
+	require.NoError(s.T(), err)
+}
+
+func (s *TestSuite) TestGetUserByLoginIdentifierEmailSuccess() {
+	email := "Test@Example.com"
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL"))
+	prepare.ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "email", "email_verified_at", "suspended_at", "password", "password_salt"}).AddRow(
+			s.user.ID,
+			s.user.PhoneNumber,
+			s.user.FullName,
+			email,
+			s.curr,
+			nil,
+			s.user.Password,
+			s.user.PasswordSalt,
+		)).
+		WithArgs(
+			"test@example.com",
+		)
+	output, err := s.r.GetUserByLoginIdentifier(s.ctx, GetUserByLoginIdentifierInput{
+		Type:  LoginIdentifierEmail,
+		Value: "test@example.com",
+	})
+	require.NoError(s.T(), err)
+	expected := s.user
+	expected.Email = &email
+	expected.EmailVerifiedAt = s.curr
+	require.Nil(s.T(), deep.Equal(output, expected))
+}
+
+func (s *TestSuite) TestGetUserByLoginIdentifierUnknownType() {
+	output, err := s.r.GetUserByLoginIdentifier(s.ctx, GetUserByLoginIdentifierInput{
+		Type:  "username",
+		Value: "test",
+	})
+	require.Error(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output, User{}))
+}
+
+func (s *TestSuite) TestVerifyEmailSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET email_verified_at = $1 WHERE id = $2 AND LOWER(email) = LOWER($3) AND deleted_at IS NULL"))
+	prepare.ExpectExec().
+		WithArgs(
+			*s.curr,
+			s.user.ID,
+			"test@example.com",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err := s.r.VerifyEmail(s.ctx, VerifyEmailInput{
+		ID:         s.user.ID,
+		Email:      "test@example.com",
+		VerifiedAt: *s.curr,
+	})
+	require.NoError(s.T(), err)
+	require.True(s.T(), output.Verified)
+}
+
+func (s *TestSuite) TestVerifyEmailChanged() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET email_verified_at = $1 WHERE id = $2 AND LOWER(email) = LOWER($3) AND deleted_at IS NULL"))
+	prepare.ExpectExec().
+		WithArgs(
+			*s.curr,
+			s.user.ID,
+			"old@example.com",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	output, err := s.r.VerifyEmail(s.ctx, VerifyEmailInput{
+		ID:         s.user.ID,
+		Email:      "old@example.com",
+		VerifiedAt: *s.curr,
+	})
+	require.NoError(s.T(), err)
+	require.False(s.T(), output.Verified)
+}
+
+func (s *TestSuite) TestUpdateUserAvatarSuccess() {
+	previous := "avatars/" + s.user.ID.String() + "/old"
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users u SET avatar_key = $1 FROM (SELECT avatar_key FROM users WHERE id = $2 AND deleted_at IS NULL FOR UPDATE) previous WHERE u.id = $2 RETURNING previous.avatar_key"))
+	prepare.ExpectQuery().
+		WithArgs(
+			"avatars/"+s.user.ID.String()+"/new",
+			s.user.ID,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"avatar_key"}).AddRow(previous))
+	output, err := s.r.UpdateUserAvatar(s.ctx, UpdateUserAvatarInput{
+		ID:        s.user.ID,
+		AvatarKey: "avatars/" + s.user.ID.String() + "/new",
+	})
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output.PreviousAvatarKey, &previous))
+}
+
+func (s *TestSuite) TestUpdateUserPasswordSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET password = $1, password_salt = $2, tokens_valid_after = $3 WHERE id = $4 AND deleted_at IS NULL"))
+	prepare.ExpectExec().
+		WithArgs(
+			s.user.Password,
+			s.user.PasswordSalt,
+			*s.curr,
+			s.user.ID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := s.r.UpdateUserPassword(s.ctx, UpdateUserPasswordInput{ID: s.user.ID, UserSecret: s.user.UserSecret, TokensValidAfter: *s.curr})
+	require.NoError(s.T(), err)
+}
+
+func (s *TestSuite) TestUpdateUserSuspensionNotFound() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET suspended_at = $1, tokens_valid_after = COALESCE($1, tokens_valid_after) WHERE id = $2 AND deleted_at IS NULL"))
+	prepare.ExpectExec().
+		WithArgs(
+			*s.curr,
+			s.user.ID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err := s.r.UpdateUserSuspension(s.ctx, UpdateUserSuspensionInput{ID: s.user.ID, SuspendedAt: s.curr})
+	require.ErrorIs(s.T(), err, ErrNotFound)
+}
+
+func (s *TestSuite) TestGetRecentUsersSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, created_at, suspended_at FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id LIMIT $1"))
+	prepare.ExpectQuery().
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "email", "created_at", "suspended_at"}).AddRow(
+			s.user.ID,
+			s.user.PhoneNumber,
+			s.user.FullName,
+			nil,
+			*s.curr,
+			nil,
+		))
+	output, err := s.r.GetRecentUsers(s.ctx, GetRecentUsersInput{Limit: 10})
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output, []RecentUser{{
+		ID:          s.user.ID,
+		PhoneNumber: s.user.PhoneNumber,
+		FullName:    s.user.FullName,
+		CreatedAt:   *s.curr,
+	}}))
+}
+
+func (s *TestSuite) TestInsertUsersSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO users(id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt) SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::timestamptz[], $6::timestamptz[], $7::text[], $8::text[])"))
+	prepare.ExpectExec().
+		WithArgs(
+			sqlmock.AnyArg(),
+			pq.Array([]string{s.user.PhoneNumber}),
+			pq.Array([]string{s.user.FullName}),
+			pq.Array([]sql.NullString{{}}),
+			pq.Array([]sql.NullString{{}}),
+			pq.Array([]sql.NullString{{}}),
+			pq.Array([]string{s.user.Password}),
+			pq.Array([]string{s.user.PasswordSalt}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err := s.r.InsertUsers(s.ctx, InsertUsersInput{Users: []User{s.user}})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), output.IDs, 1)
+}
+
+func (s *TestSuite) TestGetUsersSuccess() {
+	prepare := s.mock.ExpectPrepare(regexp.QuoteMeta("SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt, created_at FROM users WHERE id > $1 AND deleted_at IS NULL ORDER BY id LIMIT $2"))
+	prepare.ExpectQuery().
+		WithArgs(uuid.Nil, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "email", "email_verified_at", "suspended_at", "password", "password_salt", "created_at"}).AddRow(
+			s.user.ID,
+			s.user.PhoneNumber,
+			s.user.FullName,
+			nil,
+			nil,
+			nil,
+			s.user.Password,
+			s.user.PasswordSalt,
+			*s.curr,
+		))
+	output, err := s.r.GetUsers(s.ctx, GetUsersInput{Limit: 100})
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), deep.Equal(output, []StoredUser{{User: s.user, CreatedAt: *s.curr}}))
+}
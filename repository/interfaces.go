@@ -9,8 +9,53 @@ import "context"
 type RepositoryInterface interface {
 	InsertUser(ctx context.Context, input User) (output InsertUserOutput, err error)
 	GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (output User, err error)
+	GetUserByLoginIdentifier(ctx context.Context, input GetUserByLoginIdentifierInput) (output User, err error)
 	GetUserByID(ctx context.Context, input GetUserByIDInput) (output UserInfo, err error)
 	GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (output UserInfo, err error)
 	UpdateUser(ctx context.Context, input UpdateUserInput) (err error)
 	UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) (err error)
+	VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error)
+	UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (output UpdateUserAvatarOutput, err error)
+	// UpdateUserPassword and UpdateUserSuspension return ErrNotFound when
+	// there is no such user.
+	UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) (err error)
+	UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) (err error)
+	// GetRecentUsers returns the latest registered users, newest first.
+	GetRecentUsers(ctx context.Context, input GetRecentUsersInput) (output []RecentUser, err error)
+	// InsertUsers inserts several users at once, failing as a whole when
+	// one of them violates a unique constraint. The IDs are in the order
+	// of the users.
+	InsertUsers(ctx context.Context, input InsertUsersInput) (output InsertUsersOutput, err error)
+	// GetUsers pages through the users that were not deleted in the order
+	// of their ID.
+	GetUsers(ctx context.Context, input GetUsersInput) (output []StoredUser, err error)
+	InsertOutboxEvent(ctx context.Context, input InsertOutboxEventInput) (err error)
+	// LockOutbox makes the calling transaction the only outbox dispatcher
+	// until it ends, locked is false when another one is running.
+	LockOutbox(ctx context.Context) (locked bool, err error)
+	// GetPendingOutboxEvents returns the undelivered events that are due in
+	// the order they were inserted. An event waiting for its next attempt
+	// holds back the later events of its user.
+	GetPendingOutboxEvents(ctx context.Context, input GetPendingOutboxEventsInput) (output []OutboxEvent, err error)
+	MarkOutboxEventsDispatched(ctx context.Context, input MarkOutboxEventsDispatchedInput) (err error)
+	RecordOutboxEventFailure(ctx context.Context, input RecordOutboxEventFailureInput) (err error)
+	InsertWebhook(ctx context.Context, input InsertWebhookInput) (output InsertWebhookOutput, err error)
+	// GetWebhooks returns the webhooks that were not deleted, oldest first.
+	GetWebhooks(ctx context.Context) (output []Webhook, err error)
+	GetWebhookByID(ctx context.Context, input GetWebhookByIDInput) (output Webhook, err error)
+	DeleteWebhook(ctx context.Context, input DeleteWebhookInput) (err error)
+	InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error)
+	ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (output []WebhookDelivery, err error)
+	// RecordWebhookDeliveryAttempt logs an attempt and updates the delivery.
+	RecordWebhookDeliveryAttempt(ctx context.Context, input RecordWebhookDeliveryAttemptInput) (err error)
+	// GetWebhookDeliveries returns the deliveries of a webhook, newest first.
+	GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (output []WebhookDelivery, err error)
+	GetWebhookDelivery(ctx context.Context, input GetWebhookDeliveryInput) (output GetWebhookDeliveryOutput, err error)
+	// RedeliverWebhookDelivery makes a delivery pending again with a fresh
+	// set of attempts.
+	RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error)
+	// WithTx runs fn in a transaction which is committed if fn returns nil
+	// and rolled back otherwise. Called on the repository of a transaction,
+	// it undoes only the writes of fn when fn fails, like a savepoint.
+	WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) (err error)
 }
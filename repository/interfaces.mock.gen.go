@@ -34,6 +34,65 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) ([]WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, input)
+	ret0, _ := ret[0].([]WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimWebhookDeliveries(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimWebhookDeliveries), ctx, input)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockRepositoryInterface) DeleteWebhook(ctx context.Context, input DeleteWebhookInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteWebhook(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteWebhook), ctx, input)
+}
+
+// GetPendingOutboxEvents mocks base method.
+func (m *MockRepositoryInterface) GetPendingOutboxEvents(ctx context.Context, input GetPendingOutboxEventsInput) ([]OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingOutboxEvents", ctx, input)
+	ret0, _ := ret[0].([]OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingOutboxEvents indicates an expected call of GetPendingOutboxEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPendingOutboxEvents(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingOutboxEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPendingOutboxEvents), ctx, input)
+}
+
+// GetRecentUsers mocks base method.
+func (m *MockRepositoryInterface) GetRecentUsers(ctx context.Context, input GetRecentUsersInput) ([]RecentUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecentUsers", ctx, input)
+	ret0, _ := ret[0].([]RecentUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecentUsers indicates an expected call of GetRecentUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) GetRecentUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecentUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).GetRecentUsers), ctx, input)
+}
+
 // GetUserByFullName mocks base method.
 func (m *MockRepositoryInterface) GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (UserInfo, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +123,21 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUserByID(ctx, input interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserByID), ctx, input)
 }
 
+// GetUserByLoginIdentifier mocks base method.
+func (m *MockRepositoryInterface) GetUserByLoginIdentifier(ctx context.Context, input GetUserByLoginIdentifierInput) (User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByLoginIdentifier", ctx, input)
+	ret0, _ := ret[0].(User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByLoginIdentifier indicates an expected call of GetUserByLoginIdentifier.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserByLoginIdentifier(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLoginIdentifier", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserByLoginIdentifier), ctx, input)
+}
+
 // GetUserByPhoneNumber mocks base method.
 func (m *MockRepositoryInterface) GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (User, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +153,95 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUserByPhoneNumber(ctx, input i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserByPhoneNumber), ctx, input)
 }
 
+// GetUsers mocks base method.
+func (m *MockRepositoryInterface) GetUsers(ctx context.Context, input GetUsersInput) ([]StoredUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", ctx, input)
+	ret0, _ := ret[0].([]StoredUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUsers), ctx, input)
+}
+
+// GetWebhookByID mocks base method.
+func (m *MockRepositoryInterface) GetWebhookByID(ctx context.Context, input GetWebhookByIDInput) (Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookByID", ctx, input)
+	ret0, _ := ret[0].(Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookByID indicates an expected call of GetWebhookByID.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhookByID(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookByID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhookByID), ctx, input)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) ([]WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, input)
+	ret0, _ := ret[0].([]WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhookDeliveries(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhookDeliveries), ctx, input)
+}
+
+// GetWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) GetWebhookDelivery(ctx context.Context, input GetWebhookDeliveryInput) (GetWebhookDeliveryOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDelivery", ctx, input)
+	ret0, _ := ret[0].(GetWebhookDeliveryOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDelivery indicates an expected call of GetWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhookDelivery(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhookDelivery), ctx, input)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockRepositoryInterface) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", ctx)
+	ret0, _ := ret[0].([]Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhooks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhooks), ctx)
+}
+
+// InsertOutboxEvent mocks base method.
+func (m *MockRepositoryInterface) InsertOutboxEvent(ctx context.Context, input InsertOutboxEventInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertOutboxEvent", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertOutboxEvent indicates an expected call of InsertOutboxEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertOutboxEvent(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOutboxEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertOutboxEvent), ctx, input)
+}
+
 // InsertUser mocks base method.
 func (m *MockRepositoryInterface) InsertUser(ctx context.Context, input User) (InsertUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +257,121 @@ func (mr *MockRepositoryInterfaceMockRecorder) InsertUser(ctx, input interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertUser", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertUser), ctx, input)
 }
 
+// InsertUsers mocks base method.
+func (m *MockRepositoryInterface) InsertUsers(ctx context.Context, input InsertUsersInput) (InsertUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertUsers", ctx, input)
+	ret0, _ := ret[0].(InsertUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertUsers indicates an expected call of InsertUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertUsers), ctx, input)
+}
+
+// InsertWebhook mocks base method.
+func (m *MockRepositoryInterface) InsertWebhook(ctx context.Context, input InsertWebhookInput) (InsertWebhookOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWebhook", ctx, input)
+	ret0, _ := ret[0].(InsertWebhookOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertWebhook indicates an expected call of InsertWebhook.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertWebhook(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWebhook", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertWebhook), ctx, input)
+}
+
+// InsertWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWebhookDeliveries", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertWebhookDeliveries indicates an expected call of InsertWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertWebhookDeliveries(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertWebhookDeliveries), ctx, input)
+}
+
+// LockOutbox mocks base method.
+func (m *MockRepositoryInterface) LockOutbox(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockOutbox", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockOutbox indicates an expected call of LockOutbox.
+func (mr *MockRepositoryInterfaceMockRecorder) LockOutbox(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockOutbox", reflect.TypeOf((*MockRepositoryInterface)(nil).LockOutbox), ctx)
+}
+
+// MarkOutboxEventsDispatched mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventsDispatched(ctx context.Context, input MarkOutboxEventsDispatchedInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventsDispatched", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventsDispatched indicates an expected call of MarkOutboxEventsDispatched.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventsDispatched(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventsDispatched", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventsDispatched), ctx, input)
+}
+
+// RecordOutboxEventFailure mocks base method.
+func (m *MockRepositoryInterface) RecordOutboxEventFailure(ctx context.Context, input RecordOutboxEventFailureInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordOutboxEventFailure", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordOutboxEventFailure indicates an expected call of RecordOutboxEventFailure.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordOutboxEventFailure(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordOutboxEventFailure", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordOutboxEventFailure), ctx, input)
+}
+
+// RecordWebhookDeliveryAttempt mocks base method.
+func (m *MockRepositoryInterface) RecordWebhookDeliveryAttempt(ctx context.Context, input RecordWebhookDeliveryAttemptInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookDeliveryAttempt", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordWebhookDeliveryAttempt indicates an expected call of RecordWebhookDeliveryAttempt.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordWebhookDeliveryAttempt(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookDeliveryAttempt", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordWebhookDeliveryAttempt), ctx, input)
+}
+
+// RedeliverWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeliverWebhookDelivery", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedeliverWebhookDelivery indicates an expected call of RedeliverWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) RedeliverWebhookDelivery(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeliverWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).RedeliverWebhookDelivery), ctx, input)
+}
+
 // UpdateLastLoginAndSuccessfullyLogin mocks base method.
 func (m *MockRepositoryInterface) UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) error {
 	m.ctrl.T.Helper()
@@ -121,3 +399,75 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateUser(ctx, input interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUser), ctx, input)
 }
+
+// UpdateUserAvatar mocks base method.
+func (m *MockRepositoryInterface) UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (UpdateUserAvatarOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserAvatar", ctx, input)
+	ret0, _ := ret[0].(UpdateUserAvatarOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserAvatar indicates an expected call of UpdateUserAvatar.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserAvatar(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserAvatar", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserAvatar), ctx, input)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockRepositoryInterface) UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserPassword(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserPassword), ctx, input)
+}
+
+// UpdateUserSuspension mocks base method.
+func (m *MockRepositoryInterface) UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserSuspension", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserSuspension indicates an expected call of UpdateUserSuspension.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserSuspension(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserSuspension", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserSuspension), ctx, input)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockRepositoryInterface) VerifyEmail(ctx context.Context, input VerifyEmailInput) (VerifyEmailOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, input)
+	ret0, _ := ret[0].(VerifyEmailOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyEmail(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyEmail), ctx, input)
+}
+
+// WithTx mocks base method.
+func (m *MockRepositoryInterface) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryInterfaceMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepositoryInterface)(nil).WithTx), ctx, fn)
+}
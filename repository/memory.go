@@ -0,0 +1,732 @@
+// This file contains the in-memory implementation of RepositoryInterface.
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/phone"
+
+	"github.com/google/uuid"
+)
+
+// MemoryRepository keeps users in memory with the semantics of the Postgres
+// Repository, for local development and tests. Its data is lost on exit.
+type MemoryRepository struct {
+	mu    *sync.Mutex
+	state *memoryState
+	// inTx is set on the repository passed to a WithTx callback, which runs
+	// with mu held.
+	inTx bool
+}
+
+type memoryState struct {
+	users map[uuid.UUID]memoryUser
+	// seq orders users by creation like the created_at column.
+	seq int64
+	// outbox holds the rows of outbox_events in insertion order.
+	outbox   []memoryOutboxEvent
+	webhooks map[uuid.UUID]memoryWebhook
+	// deliveries holds the rows of webhook_deliveries, the delivery with
+	// ID n at index n-1.
+	deliveries []memoryWebhookDelivery
+}
+
+type memoryWebhook struct {
+	Webhook
+	seq       int64
+	deletedAt *time.Time
+}
+
+type memoryWebhookDelivery struct {
+	WebhookDelivery
+	log []WebhookDeliveryAttempt
+}
+
+type memoryOutboxEvent struct {
+	OutboxEvent
+	dispatchedAt *time.Time
+}
+
+// memoryUser is a row of the users table. Pointer fields are replaced on
+// update, never written through, so copies of a row share them safely.
+type memoryUser struct {
+	User
+	seq       int64
+	createdAt time.Time
+	deletedAt *time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		mu:    &sync.Mutex{},
+		state: &memoryState{users: map[uuid.UUID]memoryUser{}, webhooks: map[uuid.UUID]memoryWebhook{}},
+	}
+}
+
+var _ RepositoryInterface = (*MemoryRepository)(nil)
+
+func (r *MemoryRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// find returns the user with id unless it is soft deleted.
+func (s *memoryState) find(id uuid.UUID) (memoryUser, bool) {
+	user, ok := s.users[id]
+	if !ok || user.deletedAt != nil {
+		return memoryUser{}, false
+	}
+	return user, true
+}
+
+// findBy returns the first user by creation that is not soft deleted and
+// matches.
+func (s *memoryState) findBy(match func(memoryUser) bool) (memoryUser, bool) {
+	var found memoryUser
+	ok := false
+	for _, user := range s.users {
+		if user.deletedAt == nil && match(user) && (!ok || user.seq < found.seq) {
+			found, ok = user, true
+		}
+	}
+	return found, ok
+}
+
+// checkUnique returns the error of the unique constraints the row of id
+// would violate with phoneNumber and email. Soft deleted users keep their
+// phone number and email like in the users table.
+func (s *memoryState) checkUnique(id uuid.UUID, phoneNumber string, email *string) error {
+	for _, user := range s.users {
+		if user.ID == id {
+			continue
+		}
+		if user.PhoneNumber == phoneNumber {
+			return ErrPhoneNumberTaken
+		}
+		if email != nil && user.Email != nil && strings.EqualFold(*user.Email, *email) {
+			return ErrEmailTaken
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) InsertUser(ctx context.Context, input User) (output InsertUserOutput, err error) {
+	defer r.lock()()
+
+	// Only the columns of the INSERT are set.
+	output.ID, err = r.state.insert(User{UserInfo: UserInfo{PhoneNumber: input.PhoneNumber, FullName: input.FullName}, UserSecret: input.UserSecret})
+	return
+}
+
+// insert adds a user with the phone number, full name, email and secret of
+// input and returns their new ID.
+func (s *memoryState) insert(input User) (uuid.UUID, error) {
+	id := uuid.New()
+	phoneNumber := phone.Canonical(input.PhoneNumber)
+	if err := s.checkUnique(id, phoneNumber, input.Email); err != nil {
+		return uuid.Nil, err
+	}
+	s.seq++
+	s.users[id] = memoryUser{
+		User: User{
+			ID: id,
+			UserInfo: UserInfo{
+				PhoneNumber:     phoneNumber,
+				FullName:        input.FullName,
+				EmailVerifiedAt: clone(input.EmailVerifiedAt),
+				SuspendedAt:     clone(input.SuspendedAt),
+				Profile:         Profile{Email: clone(input.Email)},
+			},
+			UserSecret: input.UserSecret,
+		},
+		seq:       s.seq,
+		createdAt: time.Now(),
+	}
+	return id, nil
+}
+
+func (r *MemoryRepository) GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (output User, err error) {
+	return r.GetUserByLoginIdentifier(ctx, GetUserByLoginIdentifierInput{
+		Type:  LoginIdentifierPhoneNumber,
+		Value: input.PhoneNumber,
+	})
+}
+
+func (r *MemoryRepository) GetUserByLoginIdentifier(ctx context.Context, input GetUserByLoginIdentifierInput) (output User, err error) {
+	defer r.lock()()
+
+	var match func(memoryUser) bool
+	switch input.Type {
+	case LoginIdentifierPhoneNumber:
+		phoneNumber := phone.Canonical(input.Value)
+		match = func(user memoryUser) bool { return user.PhoneNumber == phoneNumber }
+	case LoginIdentifierEmail:
+		match = func(user memoryUser) bool { return user.Email != nil && strings.EqualFold(*user.Email, input.Value) }
+	default:
+		err = fmt.Errorf("unknown login identifier type %q", input.Type)
+		return
+	}
+
+	user, ok := r.state.findBy(match)
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	output = User{
+		ID: user.ID,
+		UserInfo: UserInfo{
+			PhoneNumber:     user.PhoneNumber,
+			FullName:        user.FullName,
+			EmailVerifiedAt: clone(user.EmailVerifiedAt),
+			SuspendedAt:     clone(user.SuspendedAt),
+			Profile:         Profile{Email: clone(user.Email)},
+		},
+		UserSecret: user.UserSecret,
+	}
+	return
+}
+
+func (r *MemoryRepository) GetUserByID(ctx context.Context, input GetUserByIDInput) (output UserInfo, err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	output = UserInfo{
+		PhoneNumber:      user.PhoneNumber,
+		FullName:         user.FullName,
+		EmailVerifiedAt:  clone(user.EmailVerifiedAt),
+		AvatarKey:        clone(user.AvatarKey),
+		SuspendedAt:      clone(user.SuspendedAt),
+		TokensValidAfter: clone(user.TokensValidAfter),
+		Profile: Profile{
+			Email:       clone(user.Email),
+			DateOfBirth: clone(user.DateOfBirth),
+			Gender:      clone(user.Gender),
+			Address:     clone(user.Address),
+		},
+	}
+	return
+}
+
+func (r *MemoryRepository) GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (output UserInfo, err error) {
+	defer r.lock()()
+
+	user, ok := r.state.findBy(func(user memoryUser) bool { return strings.EqualFold(user.FullName, input.FullName) })
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	output = UserInfo{
+		PhoneNumber: user.PhoneNumber,
+		FullName:    user.FullName,
+	}
+	return
+}
+
+func (r *MemoryRepository) UpdateUser(ctx context.Context, input UpdateUserInput) (err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		return
+	}
+	email := user.Email
+	if input.Email != nil {
+		email = clone(input.Email)
+	}
+	phoneNumber := phone.Canonical(input.PhoneNumber)
+	if err = r.state.checkUnique(user.ID, phoneNumber, email); err != nil {
+		return
+	}
+
+	if email == nil || user.Email == nil || !strings.EqualFold(*email, *user.Email) {
+		user.EmailVerifiedAt = nil
+	}
+	user.PhoneNumber = phoneNumber
+	user.FullName = input.FullName
+	user.Email = email
+	if input.DateOfBirth != nil {
+		user.DateOfBirth = clone(input.DateOfBirth)
+	}
+	if input.Gender != nil {
+		user.Gender = clone(input.Gender)
+	}
+	if input.Address != nil {
+		user.Address = clone(input.Address)
+	}
+	r.state.users[user.ID] = user
+	return
+}
+
+func (r *MemoryRepository) UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) (err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		return
+	}
+	user.SuccessfullyLogin++
+	user.LastLogin = clone(input.LastLogin)
+	r.state.users[user.ID] = user
+	return
+}
+
+func (r *MemoryRepository) VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok || user.Email == nil || !strings.EqualFold(*user.Email, input.Email) {
+		return
+	}
+	user.EmailVerifiedAt = clone(&input.VerifiedAt)
+	r.state.users[user.ID] = user
+	output.Verified = true
+	return
+}
+
+func (r *MemoryRepository) UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (output UpdateUserAvatarOutput, err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	output.PreviousAvatarKey = user.AvatarKey
+	user.AvatarKey = clone(&input.AvatarKey)
+	r.state.users[user.ID] = user
+	return
+}
+
+func (r *MemoryRepository) UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) (err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	user.UserSecret = input.UserSecret
+	user.TokensValidAfter = clone(&input.TokensValidAfter)
+	r.state.users[user.ID] = user
+	return
+}
+
+func (r *MemoryRepository) UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) (err error) {
+	defer r.lock()()
+
+	user, ok := r.state.find(input.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	user.SuspendedAt = clone(input.SuspendedAt)
+	if input.SuspendedAt != nil {
+		user.TokensValidAfter = clone(input.SuspendedAt)
+	}
+	r.state.users[user.ID] = user
+	return
+}
+
+func (r *MemoryRepository) GetRecentUsers(ctx context.Context, input GetRecentUsersInput) (output []RecentUser, err error) {
+	defer r.lock()()
+
+	users := make([]memoryUser, 0, len(r.state.users))
+	for _, user := range r.state.users {
+		if user.deletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].seq > users[j].seq })
+	if len(users) > input.Limit {
+		users = users[:input.Limit]
+	}
+	for _, user := range users {
+		output = append(output, RecentUser{
+			ID:          user.ID,
+			PhoneNumber: user.PhoneNumber,
+			FullName:    user.FullName,
+			Email:       clone(user.Email),
+			CreatedAt:   user.createdAt,
+			SuspendedAt: clone(user.SuspendedAt),
+		})
+	}
+	return
+}
+
+func (r *MemoryRepository) InsertUsers(ctx context.Context, input InsertUsersInput) (output InsertUsersOutput, err error) {
+	err = r.WithTx(ctx, func(repo RepositoryInterface) error {
+		tx := repo.(*MemoryRepository)
+		for _, user := range input.Users {
+			id, err := tx.state.insert(user)
+			if err != nil {
+				return err
+			}
+			output.IDs = append(output.IDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return InsertUsersOutput{}, err
+	}
+	return
+}
+
+func (r *MemoryRepository) GetUsers(ctx context.Context, input GetUsersInput) (output []StoredUser, err error) {
+	defer r.lock()()
+
+	users := make([]memoryUser, 0, len(r.state.users))
+	for _, user := range r.state.users {
+		if user.deletedAt == nil && bytes.Compare(user.ID[:], input.AfterID[:]) > 0 {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return bytes.Compare(users[i].ID[:], users[j].ID[:]) < 0 })
+	if len(users) > input.Limit {
+		users = users[:input.Limit]
+	}
+	for _, user := range users {
+		output = append(output, StoredUser{
+			User: User{
+				ID: user.ID,
+				UserInfo: UserInfo{
+					PhoneNumber:     user.PhoneNumber,
+					FullName:        user.FullName,
+					EmailVerifiedAt: clone(user.EmailVerifiedAt),
+					SuspendedAt:     clone(user.SuspendedAt),
+					Profile:         Profile{Email: clone(user.Email)},
+				},
+				UserSecret: user.UserSecret,
+			},
+			CreatedAt: user.createdAt,
+		})
+	}
+	return
+}
+
+func (r *MemoryRepository) InsertOutboxEvent(ctx context.Context, input InsertOutboxEventInput) (err error) {
+	defer r.lock()()
+
+	var id int64 = 1
+	if n := len(r.state.outbox); n > 0 {
+		id = r.state.outbox[n-1].ID + 1
+	}
+	r.state.outbox = append(r.state.outbox, memoryOutboxEvent{OutboxEvent: OutboxEvent{
+		ID:        id,
+		UserID:    input.UserID,
+		Type:      input.Type,
+		Payload:   append(json.RawMessage(nil), input.Payload...),
+		CreatedAt: time.Now(),
+	}})
+	return
+}
+
+// LockOutbox always succeeds, WithTx already holds the repository lock.
+func (r *MemoryRepository) LockOutbox(ctx context.Context) (locked bool, err error) {
+	return true, nil
+}
+
+func (r *MemoryRepository) GetPendingOutboxEvents(ctx context.Context, input GetPendingOutboxEventsInput) (output []OutboxEvent, err error) {
+	defer r.lock()()
+
+	held := map[uuid.UUID]bool{}
+	for _, event := range r.state.outbox {
+		if len(output) == input.Limit {
+			break
+		}
+		if event.dispatchedAt != nil || held[event.UserID] {
+			continue
+		}
+		if event.NextAttemptAt != nil && event.NextAttemptAt.After(input.Now) {
+			held[event.UserID] = true
+			continue
+		}
+		output = append(output, event.OutboxEvent)
+	}
+	return
+}
+
+func (r *MemoryRepository) MarkOutboxEventsDispatched(ctx context.Context, input MarkOutboxEventsDispatchedInput) (err error) {
+	defer r.lock()()
+
+	ids := make(map[int64]bool, len(input.IDs))
+	for _, id := range input.IDs {
+		ids[id] = true
+	}
+	for i, event := range r.state.outbox {
+		if ids[event.ID] {
+			r.state.outbox[i].dispatchedAt = clone(&input.DispatchedAt)
+		}
+	}
+	return
+}
+
+func (r *MemoryRepository) RecordOutboxEventFailure(ctx context.Context, input RecordOutboxEventFailureInput) (err error) {
+	defer r.lock()()
+
+	for i, event := range r.state.outbox {
+		if event.ID == input.ID {
+			event.Attempts++
+			event.LastError = clone(&input.Error)
+			event.NextAttemptAt = clone(&input.NextAttemptAt)
+			r.state.outbox[i] = event
+		}
+	}
+	return
+}
+
+func (r *MemoryRepository) InsertWebhook(ctx context.Context, input InsertWebhookInput) (output InsertWebhookOutput, err error) {
+	defer r.lock()()
+
+	output = InsertWebhookOutput{ID: uuid.New(), CreatedAt: time.Now()}
+	r.state.seq++
+	r.state.webhooks[output.ID] = memoryWebhook{
+		Webhook: Webhook{
+			ID:        output.ID,
+			URL:       input.URL,
+			Events:    append([]string{}, input.Events...),
+			Secret:    input.Secret,
+			CreatedAt: output.CreatedAt,
+		},
+		seq: r.state.seq,
+	}
+	return
+}
+
+func (r *MemoryRepository) GetWebhooks(ctx context.Context) (output []Webhook, err error) {
+	defer r.lock()()
+
+	webhooks := make([]memoryWebhook, 0, len(r.state.webhooks))
+	for _, webhook := range r.state.webhooks {
+		if webhook.deletedAt == nil {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].seq < webhooks[j].seq })
+	for _, webhook := range webhooks {
+		output = append(output, webhook.Webhook)
+	}
+	return
+}
+
+func (r *MemoryRepository) GetWebhookByID(ctx context.Context, input GetWebhookByIDInput) (output Webhook, err error) {
+	defer r.lock()()
+
+	webhook, ok := r.state.webhooks[input.ID]
+	if !ok || webhook.deletedAt != nil {
+		err = ErrNotFound
+		return
+	}
+	output = webhook.Webhook
+	return
+}
+
+func (r *MemoryRepository) DeleteWebhook(ctx context.Context, input DeleteWebhookInput) (err error) {
+	defer r.lock()()
+
+	webhook, ok := r.state.webhooks[input.ID]
+	if !ok || webhook.deletedAt != nil {
+		err = ErrNotFound
+		return
+	}
+	now := time.Now()
+	webhook.deletedAt = &now
+	r.state.webhooks[input.ID] = webhook
+	return
+}
+
+func (r *MemoryRepository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error) {
+	defer r.lock()()
+
+	for _, id := range input.WebhookIDs {
+		if _, ok := r.state.webhooks[id]; !ok {
+			return fmt.Errorf("webhook %s does not exist: %w", id, ErrConflict)
+		}
+		exists := false
+		for _, delivery := range r.state.deliveries {
+			if delivery.WebhookID == id && delivery.EventID == input.EventID {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		r.state.deliveries = append(r.state.deliveries, memoryWebhookDelivery{WebhookDelivery: WebhookDelivery{
+			ID:            int64(len(r.state.deliveries) + 1),
+			WebhookID:     id,
+			EventID:       input.EventID,
+			EventType:     input.EventType,
+			Payload:       append(json.RawMessage(nil), input.Payload...),
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: clone(&input.NextAttemptAt),
+			CreatedAt:     time.Now(),
+		}})
+	}
+	return
+}
+
+func (r *MemoryRepository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	defer r.lock()()
+
+	var due []int
+	for i, delivery := range r.state.deliveries {
+		if delivery.Status == WebhookDeliveryPending && !delivery.NextAttemptAt.After(input.Now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return r.state.deliveries[due[i]].NextAttemptAt.Before(*r.state.deliveries[due[j]].NextAttemptAt)
+	})
+	if len(due) > input.Limit {
+		due = due[:input.Limit]
+	}
+	sort.Ints(due)
+	for _, i := range due {
+		r.state.deliveries[i].NextAttemptAt = clone(&input.LeaseUntil)
+		output = append(output, r.state.deliveries[i].WebhookDelivery)
+	}
+	return
+}
+
+func (r *MemoryRepository) RecordWebhookDeliveryAttempt(ctx context.Context, input RecordWebhookDeliveryAttemptInput) (err error) {
+	defer r.lock()()
+
+	i := int(input.DeliveryID) - 1
+	if i < 0 || i >= len(r.state.deliveries) {
+		return fmt.Errorf("webhook delivery %d does not exist: %w", input.DeliveryID, ErrConflict)
+	}
+	delivery := r.state.deliveries[i]
+	delivery.Status = input.Status
+	delivery.Attempts++
+	delivery.NextAttemptAt = clone(input.NextAttemptAt)
+	delivery.LastStatusCode = clone(input.Attempt.StatusCode)
+	delivery.LastError = clone(input.Attempt.Error)
+	if input.Status == WebhookDeliverySucceeded {
+		delivery.DeliveredAt = clone(&input.Attempt.AttemptedAt)
+	}
+	attempt := input.Attempt
+	attempt.StatusCode = clone(attempt.StatusCode)
+	attempt.Error = clone(attempt.Error)
+	// The log is appended to a copy, it may be shared with a snapshot.
+	delivery.log = append(append([]WebhookDeliveryAttempt(nil), delivery.log...), attempt)
+	r.state.deliveries[i] = delivery
+	return
+}
+
+func (r *MemoryRepository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	defer r.lock()()
+
+	for i := len(r.state.deliveries) - 1; i >= 0 && len(output) < input.Limit; i-- {
+		delivery := r.state.deliveries[i]
+		if delivery.WebhookID == input.WebhookID && (input.Status == nil || delivery.Status == *input.Status) {
+			output = append(output, delivery.WebhookDelivery)
+		}
+	}
+	return
+}
+
+func (r *MemoryRepository) GetWebhookDelivery(ctx context.Context, input GetWebhookDeliveryInput) (output GetWebhookDeliveryOutput, err error) {
+	defer r.lock()()
+
+	i := int(input.ID) - 1
+	if i < 0 || i >= len(r.state.deliveries) || r.state.deliveries[i].WebhookID != input.WebhookID {
+		err = ErrNotFound
+		return
+	}
+	output.WebhookDelivery = r.state.deliveries[i].WebhookDelivery
+	output.Log = append(output.Log, r.state.deliveries[i].log...)
+	return
+}
+
+func (r *MemoryRepository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error) {
+	defer r.lock()()
+
+	i := int(input.ID) - 1
+	if i < 0 || i >= len(r.state.deliveries) || r.state.deliveries[i].WebhookID != input.WebhookID {
+		err = ErrNotFound
+		return
+	}
+	delivery := r.state.deliveries[i]
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = clone(&input.At)
+	r.state.deliveries[i] = delivery
+	return
+}
+
+// WithTx runs fn with the repository locked, so transactions are
+// serializable, and restores the state when fn fails. Nested calls restore
+// only the changes of their own fn.
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) (err error) {
+	if r.inTx {
+		snapshot := r.state.clone()
+		if err = fn(r); err != nil {
+			*r.state = snapshot
+		}
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := r.state.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			*r.state = snapshot
+			panic(p)
+		}
+		if err != nil {
+			*r.state = snapshot
+		}
+	}()
+	return fn(&MemoryRepository{mu: r.mu, state: r.state, inTx: true})
+}
+
+// clone returns a copy of s that does not change with s.
+func (s *memoryState) clone() memoryState {
+	c := memoryState{
+		users:      make(map[uuid.UUID]memoryUser, len(s.users)),
+		seq:        s.seq,
+		outbox:     append([]memoryOutboxEvent(nil), s.outbox...),
+		webhooks:   make(map[uuid.UUID]memoryWebhook, len(s.webhooks)),
+		deliveries: append([]memoryWebhookDelivery(nil), s.deliveries...),
+	}
+	for id, user := range s.users {
+		c.users[id] = user
+	}
+	for id, webhook := range s.webhooks {
+		c.webhooks[id] = webhook
+	}
+	return c
+}
+
+// softDelete marks the user deleted like setting users.deleted_at.
+func (r *MemoryRepository) softDelete(id uuid.UUID) {
+	defer r.lock()()
+
+	if user, ok := r.state.users[id]; ok {
+		now := time.Now()
+		user.deletedAt = &now
+		r.state.users[id] = user
+	}
+}
+
+// clone returns a copy of *p so callers cannot change the stored value.
+func clone[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
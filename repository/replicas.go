@@ -0,0 +1,133 @@
+// This file contains the routing of reads to read replicas.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReplicaCheckInterval is how often replicas are pinged when
+// NewRepositoryOptions.ReplicaCheckInterval is not set.
+const DefaultReplicaCheckInterval = 5 * time.Second
+
+// replica is a read replica with its own pool and statement cache.
+type replica struct {
+	*Repository
+	healthy atomic.Bool
+}
+
+// readSession records whether a request wrote, see WithSession.
+type readSession struct {
+	wrote atomic.Bool
+	// primary sends every read to the primary, see WithPrimary.
+	primary bool
+}
+
+type readSessionKey struct{}
+
+// WithSession returns a context whose reads go to the primary once a write
+// was made with it, so a request reads its own writes even when the
+// replicas lag behind. Without a session reads always prefer replicas.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readSessionKey{}, &readSession{})
+}
+
+// WithPrimary returns a context whose reads go to the primary and bypass
+// CachedRepository, for reads that must see every committed write, those of
+// other processes included.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readSessionKey{}, &readSession{primary: true})
+}
+
+// readsPrimary tells whether ctx was returned by WithPrimary.
+func readsPrimary(ctx context.Context) bool {
+	s, ok := ctx.Value(readSessionKey{}).(*readSession)
+	return ok && s.primary
+}
+
+// markWrite makes the following reads of the session of ctx go to the
+// primary.
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(readSessionKey{}).(*readSession); ok {
+		s.wrote.Store(true)
+	}
+}
+
+func wroteInSession(ctx context.Context) bool {
+	s, ok := ctx.Value(readSessionKey{}).(*readSession)
+	return ok && (s.primary || s.wrote.Load())
+}
+
+// pickReplica returns the next healthy replica round robin, or nil when
+// the read has to go to the primary.
+func (r *Repository) pickReplica(ctx context.Context) *replica {
+	if r.tx != nil || len(r.replicas) == 0 || wroteInSession(ctx) {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(int(start)+i)%len(r.replicas)]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// read runs fn with the statement of query on a replica when one is
+// healthy and on the primary otherwise. A replica that turns out to be
+// unavailable is taken out of rotation and the read retried on the primary.
+func (r *Repository) read(ctx context.Context, query string, fn func(stmt *sql.Stmt) error) error {
+	if rep := r.pickReplica(ctx); rep != nil {
+		stmt, err := rep.prepare(ctx, query)
+		if err == nil {
+			err = fn(stmt)
+		}
+		if !errors.Is(mapError(err), ErrUnavailable) {
+			return err
+		}
+		rep.healthy.Store(false)
+	}
+
+	stmt, err := r.prepare(ctx, query)
+	if err != nil {
+		return err
+	}
+	return fn(stmt)
+}
+
+// checkReplicas pings every replica, putting those that answer back into
+// rotation and taking the others out.
+func (r *Repository) checkReplicas(ctx context.Context, timeout time.Duration) {
+	for _, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		rep.healthy.Store(rep.Db.PingContext(pingCtx) == nil)
+		cancel()
+	}
+}
+
+// monitorReplicas runs checkReplicas every interval until stop is closed.
+func (r *Repository) monitorReplicas(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.checkReplicas(context.Background(), interval)
+		}
+	}
+}
+
+// ReplicaDBs returns the connection pools of the read replicas.
+func (r *Repository) ReplicaDBs() []*sql.DB {
+	dbs := make([]*sql.DB, len(r.replicas))
+	for i, rep := range r.replicas {
+		dbs[i] = rep.Db
+	}
+	return dbs
+}
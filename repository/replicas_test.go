@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+const getUserByFullNameQuery = "SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1"
+
+func newReplicatedRepository(t *testing.T) (*Repository, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	primary, primaryMock, err := sqlmock.New()
+	require.NoError(t, err)
+	replicaDb, replicaMock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	rep := &replica{Repository: &Repository{Db: replicaDb}}
+	rep.healthy.Store(true)
+	return &Repository{Db: primary, replicas: []*replica{rep}}, primaryMock, replicaMock
+}
+
+func expectGetUserByFullName(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare(regexp.QuoteMeta(getUserByFullNameQuery)).
+		ExpectQuery().WithArgs("Test test").
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow("+62123456789", "Test test"))
+}
+
+func TestReadsGoToReplicaUntilTheSessionWrites(t *testing.T) {
+	r, primaryMock, replicaMock := newReplicatedRepository(t)
+	ctx := WithSession(context.Background())
+
+	expectGetUserByFullName(replicaMock)
+	_, err := r.GetUserByFullName(ctx, GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+
+	primaryMock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1 WHERE id = $2")).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, r.UpdateUserPhoneNumber(ctx, UpdateUserPhoneNumberInput{ID: uuid.New(), PhoneNumber: "+62123456789"}))
+
+	// The session wrote, it reads from the primary, other requests do not.
+	primaryMock.ExpectPrepare(regexp.QuoteMeta(getUserByFullNameQuery)).
+		ExpectQuery().WithArgs("Test test").
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow("+62123456789", "Test test"))
+	_, err = r.GetUserByFullName(ctx, GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+
+	replicaMock.ExpectQuery(regexp.QuoteMeta(getUserByFullNameQuery)).WithArgs("Test test").
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow("+62123456789", "Test test"))
+	_, err = r.GetUserByFullName(WithSession(context.Background()), GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestWithPrimaryReadsFromPrimary(t *testing.T) {
+	r, primaryMock, replicaMock := newReplicatedRepository(t)
+
+	expectGetUserByFullName(primaryMock)
+	_, err := r.GetUserByFullName(WithPrimary(context.Background()), GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReadFallsBackToPrimaryWhenReplicaIsUnavailable(t *testing.T) {
+	r, primaryMock, replicaMock := newReplicatedRepository(t)
+	ctx := context.Background()
+
+	replicaMock.ExpectPrepare(regexp.QuoteMeta(getUserByFullNameQuery)).WillReturnError(&pq.Error{Code: "57P03"})
+	expectGetUserByFullName(primaryMock)
+	_, err := r.GetUserByFullName(ctx, GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+	require.False(t, r.replicas[0].healthy.Load())
+
+	// Out of rotation until a ping succeeds.
+	primaryMock.ExpectQuery(regexp.QuoteMeta(getUserByFullNameQuery)).WithArgs("Test test").
+		WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow("+62123456789", "Test test"))
+	_, err = r.GetUserByFullName(ctx, GetUserByFullNameInput{FullName: "Test test"})
+	require.NoError(t, err)
+
+	replicaMock.ExpectPing()
+	r.checkReplicas(ctx, time.Second)
+	require.True(t, r.replicas[0].healthy.Load())
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReadDoesNotFallBackOnNotFound(t *testing.T) {
+	r, primaryMock, replicaMock := newReplicatedRepository(t)
+
+	replicaMock.ExpectPrepare(regexp.QuoteMeta(getUserByFullNameQuery)).
+		ExpectQuery().WithArgs("Test test").WillReturnError(sql.ErrNoRows)
+	_, err := r.GetUserByFullName(context.Background(), GetUserByFullNameInput{FullName: "Test test"})
+	require.ErrorIs(t, err, ErrNotFound)
+	require.True(t, r.replicas[0].healthy.Load())
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
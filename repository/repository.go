@@ -2,25 +2,276 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/tracing"
 
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The drivers RepositoryInterface is implemented for.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
 )
 
 type Repository struct {
 	Db *sql.DB
+
+	mu sync.RWMutex
+	// stmts caches the prepared statements by query, see prepare.
+	stmts map[string]*sql.Stmt
+
+	// tx is set on the repository passed to a WithTx callback, its calls run
+	// in the transaction with the statements of parent.
+	tx     *sql.Tx
+	parent *Repository
+
+	// replicas serve the reads, see read.
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
 }
 
 type NewRepositoryOptions struct {
 	Dsn string
+	// ReplicaDsns are read replicas of Dsn. Reads are spread over the
+	// healthy ones, writes and transactions always go to Dsn.
+	ReplicaDsns []string
+	// ReplicaCheckInterval is how often replicas are pinged to put them
+	// back into rotation, DefaultReplicaCheckInterval when zero.
+	ReplicaCheckInterval time.Duration
+	Pool                 PoolOptions
+}
+
+// PoolOptions configures the connection pool, zero values keep the
+// database/sql defaults.
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
-func NewRepository(opts NewRepositoryOptions) *Repository {
-	db, err := sql.Open("postgres", opts.Dsn)
+// NewRepository opens the connection pool. It does not connect, use
+// WaitReady to make sure the database is reachable.
+func NewRepository(opts NewRepositoryOptions) (*Repository, error) {
+	db, err := openPool(opts.Dsn, opts.Pool)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	r := &Repository{Db: db}
+	for i, dsn := range opts.ReplicaDsns {
+		replicaDb, err := openPool(dsn, opts.Pool)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("opening replica %d: %w", i, err)
+		}
+		rep := &replica{Repository: &Repository{Db: replicaDb}}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	if len(r.replicas) > 0 {
+		interval := opts.ReplicaCheckInterval
+		if interval <= 0 {
+			interval = DefaultReplicaCheckInterval
+		}
+		r.stop = make(chan struct{})
+		go r.monitorReplicas(interval, r.stop)
+	}
+	return r, nil
+}
+
+func openPool(dsn string, pool PoolOptions) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
 	}
-	return &Repository{
-		Db: db,
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+// Backoff is the delay between connection attempts, doubling from Initial
+// up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// WaitReady pings the database until it answers or ctx is done, so a
+// service started together with its database does not fail right away.
+func (r *Repository) WaitReady(ctx context.Context, backoff Backoff) error {
+	delay := backoff.Initial
+	for attempt := 1; ; attempt++ {
+		err := r.Db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("database not ready after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		if delay *= 2; delay > backoff.Max {
+			delay = backoff.Max
+		}
+	}
+}
+
+// startSpan starts the span of a repository call.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+}
+
+// endSpan ends span, not recording ErrNotFound which only means that
+// nothing was found.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// prepare returns the statement of query, preparing it on first use. The
+// statement is shared by all callers, database/sql prepares it again on
+// every pool connection it runs on.
+func (r *Repository) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	if r.tx != nil {
+		stmt, err := r.parent.prepare(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return r.tx.StmtContext(ctx, stmt), nil
+	}
+
+	r.mu.RLock()
+	stmt, ok := r.stmts[query]
+	r.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stmt, ok := r.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := r.Db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if r.stmts == nil {
+		r.stmts = map[string]*sql.Stmt{}
+	}
+	r.stmts[query] = stmt
+	return stmt, nil
+}
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and
+// rolling it back otherwise. The repository passed to fn runs its calls in
+// the transaction, calling WithTx on it runs fn in a savepoint of the same
+// transaction.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) (err error) {
+	if r.tx != nil {
+		return runSavepoint(ctx, r.tx, func() error { return fn(r) })
+	}
+
+	ctx, span := startSpan(ctx, "Repository.WithTx")
+	defer func() { err = mapError(err); endSpan(span, err) }()
+
+	markWrite(ctx)
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	return runTx(tx, func() error {
+		return fn(&Repository{Db: r.Db, tx: tx, parent: r})
+	})
+}
+
+// runTx runs fn in tx, rolling tx back when fn fails or panics and
+// committing it otherwise.
+func runTx(tx *sql.Tx, fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = errors.Join(err, fmt.Errorf("rolling back: %w", rbErr))
+			}
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("committing transaction: %w", err)
+		}
+	}()
+	return fn()
+}
+
+// runSavepoint runs fn in a savepoint of tx, rolling back to it when fn
+// fails so the transaction stays usable and only the writes of fn are
+// undone. A panic is left to the transaction to roll back.
+func runSavepoint(ctx context.Context, tx *sql.Tx, fn func() error) (err error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT nested"); err != nil {
+		return fmt.Errorf("creating savepoint: %w", err)
+	}
+	if err = fn(); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested"); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("rolling back to savepoint: %w", rbErr))
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT nested"); err != nil {
+		return fmt.Errorf("releasing savepoint: %w", err)
+	}
+	return nil
+}
+
+// Close closes the prepared statements and the connection pools.
+func (r *Repository) Close() error {
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.Close())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for query, stmt := range r.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(r.stmts, query)
 	}
+	return errors.Join(append(errs, r.Db.Close())...)
 }
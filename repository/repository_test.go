@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitReady(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	require.NoError(t, r.WaitReady(context.Background(), Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWaitReadyTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	for i := 0; i < 100; i++ {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = r.WaitReady(ctx, Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond})
+	require.ErrorContains(t, err, "connection refused")
+}
+
+func TestPreparedStatementsAreReused(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	prepare := mock.ExpectPrepare(regexp.QuoteMeta("SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER($1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1")).WillBeClosed()
+	for i := 0; i < 2; i++ {
+		prepare.ExpectQuery().WithArgs("Test test").
+			WillReturnRows(sqlmock.NewRows([]string{"phone_number", "full_name"}).AddRow("+62123456789", "Test test"))
+	}
+	mock.ExpectClose()
+
+	for i := 0; i < 2; i++ {
+		_, err := r.GetUserByFullName(context.Background(), GetUserByFullNameInput{FullName: "Test test"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTxCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectBegin()
+	// The cached statement is prepared again on the connection of the
+	// transaction.
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1 WHERE id = $2"))
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE users SET phone_number = $1 WHERE id = $2")).
+		ExpectExec().WithArgs("+62123456789", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = r.WithTx(context.Background(), func(repo RepositoryInterface) error {
+		return repo.(*Repository).UpdateUserPhoneNumber(context.Background(), UpdateUserPhoneNumberInput{PhoneNumber: "+62123456789"})
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTxRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT nested").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT nested").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	fnErr := errors.New("failed")
+	err = r.WithTx(context.Background(), func(repo RepositoryInterface) error {
+		// Nested calls run in a savepoint of the outer transaction.
+		return repo.WithTx(context.Background(), func(RepositoryInterface) error {
+			return fnErr
+		})
+	})
+	require.ErrorIs(t, err, fnErr)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	r := &Repository{Db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	require.Panics(t, func() {
+		_ = r.WithTx(context.Background(), func(RepositoryInterface) error {
+			panic("boom")
+		})
+	})
+	require.NoError(t, mock.ExpectationsWereMet())
+}
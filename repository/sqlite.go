@@ -0,0 +1,644 @@
+// This file contains the SQLite implementation of RepositoryInterface.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/phone"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteAddedColumns are the users columns added after the table was
+// first created, they are added to databases that lack them.
+var sqliteAddedColumns = []struct{ name, definition string }{
+	{"suspended_at", "TIMESTAMP"},
+	{"tokens_valid_after", "TIMESTAMP"},
+}
+
+// sqliteSchema mirrors the users table of the Postgres migrations.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		phone_number TEXT NOT NULL CONSTRAINT users_phone_number_key UNIQUE,
+		full_name TEXT NOT NULL,
+		password TEXT,
+		password_salt TEXT,
+		successfully_login INTEGER NOT NULL DEFAULT 0,
+		last_login TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		created_by TEXT,
+		modified_at TIMESTAMP,
+		modified_by TEXT,
+		deleted_at TIMESTAMP,
+		email TEXT,
+		email_verified_at TIMESTAMP,
+		avatar_key TEXT,
+		date_of_birth DATE,
+		gender TEXT CHECK (gender IN ('male', 'female', 'other')),
+		address TEXT,
+		suspended_at TIMESTAMP,
+		tokens_valid_after TIMESTAMP
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (LOWER(email))`,
+	`CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at)`,
+	`CREATE TABLE IF NOT EXISTS outbox_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_error TEXT,
+		dispatched_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS outbox_events_waiting ON outbox_events (user_id, id) WHERE dispatched_at IS NULL AND next_attempt_at IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		deleted_at TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id TEXT NOT NULL REFERENCES webhooks (id),
+		event_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_status_code INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL,
+		delivered_at TIMESTAMP,
+		UNIQUE (webhook_id, event_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		delivery_id INTEGER NOT NULL REFERENCES webhook_deliveries (id),
+		attempted_at TIMESTAMP NOT NULL,
+		status_code INTEGER,
+		error TEXT,
+		duration_ms INTEGER NOT NULL
+	)`,
+}
+
+// SQLiteRepository stores users in a SQLite database with the semantics of
+// the Postgres Repository, for local development without a database server.
+type SQLiteRepository struct {
+	Db *sql.DB
+	// tx is set on the repository passed to a WithTx callback.
+	tx *sql.Tx
+}
+
+var _ RepositoryInterface = (*SQLiteRepository)(nil)
+
+// NewSQLiteRepository opens the database file at path, ":memory:" for a
+// private in-memory database, and creates the users table if needed.
+func NewSQLiteRepository(ctx context.Context, path string) (*SQLiteRepository, error) {
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	// The sqlite time format keeps created_at sortable as text.
+	dsn += "_time_format=sqlite&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	// SQLite has a single writer, one connection avoids busy errors and
+	// keeps a ":memory:" database alive.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	for _, stmt := range sqliteSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating schema: %w", err)
+		}
+	}
+	if err := addSQLiteColumns(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	return &SQLiteRepository{Db: db}, nil
+}
+
+func addSQLiteColumns(ctx context.Context, db *sql.DB) error {
+	for _, column := range sqliteAddedColumns {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pragma_table_info('users') WHERE name = ?1", column.name).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE users ADD COLUMN "+column.name+" "+column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conn returns the transaction of r if any, the database otherwise.
+func (r *SQLiteRepository) conn() interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+} {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.Db
+}
+
+func (r *SQLiteRepository) InsertUser(ctx context.Context, input User) (output InsertUserOutput, err error) {
+	defer func() { err = mapError(err) }()
+
+	output.ID = uuid.New()
+	_, err = r.conn().ExecContext(ctx, "INSERT INTO users(id, phone_number, full_name, password, password_salt, created_at) VALUES(?1,?2,?3,?4,?5,?6)",
+		output.ID.String(), phone.Canonical(input.PhoneNumber), input.FullName, input.Password, input.PasswordSalt, time.Now())
+	if err != nil {
+		return InsertUserOutput{}, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) GetUserByPhoneNumber(ctx context.Context, input GetUserByPhoneNumberInput) (output User, err error) {
+	return r.GetUserByLoginIdentifier(ctx, GetUserByLoginIdentifierInput{
+		Type:  LoginIdentifierPhoneNumber,
+		Value: input.PhoneNumber,
+	})
+}
+
+func (r *SQLiteRepository) GetUserByLoginIdentifier(ctx context.Context, input GetUserByLoginIdentifierInput) (output User, err error) {
+	defer func() { err = mapError(err) }()
+
+	var query, value string
+	switch input.Type {
+	case LoginIdentifierPhoneNumber:
+		query = "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE phone_number = ?1 AND deleted_at IS NULL"
+		value = phone.Canonical(input.Value)
+	case LoginIdentifierEmail:
+		query = "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt FROM users WHERE LOWER(email) = LOWER(?1) AND deleted_at IS NULL"
+		value = input.Value
+	default:
+		err = fmt.Errorf("unknown login identifier type %q", input.Type)
+		return
+	}
+
+	err = r.conn().QueryRowContext(ctx, query, value).Scan(
+		&output.ID,
+		&output.PhoneNumber,
+		&output.FullName,
+		&output.Email,
+		&output.EmailVerifiedAt,
+		&output.SuspendedAt,
+		&output.Password,
+		&output.PasswordSalt,
+	)
+	return
+}
+
+func (r *SQLiteRepository) GetUserByID(ctx context.Context, input GetUserByIDInput) (output UserInfo, err error) {
+	defer func() { err = mapError(err) }()
+
+	var address sql.NullString
+	err = r.conn().QueryRowContext(ctx, "SELECT phone_number, full_name, email, email_verified_at, avatar_key, suspended_at, tokens_valid_after, date_of_birth, gender, address FROM users WHERE id = ?1 AND deleted_at IS NULL", input.ID.String()).Scan(
+		&output.PhoneNumber,
+		&output.FullName,
+		&output.Email,
+		&output.EmailVerifiedAt,
+		&output.AvatarKey,
+		&output.SuspendedAt,
+		&output.TokensValidAfter,
+		&output.DateOfBirth,
+		&output.Gender,
+		&address,
+	)
+	if err != nil {
+		return
+	}
+	if address.Valid {
+		output.Address = &Address{}
+		err = output.Address.Scan(address.String)
+	}
+	return
+}
+
+func (r *SQLiteRepository) GetUserByFullName(ctx context.Context, input GetUserByFullNameInput) (output UserInfo, err error) {
+	defer func() { err = mapError(err) }()
+
+	err = r.conn().QueryRowContext(ctx, "SELECT phone_number, full_name FROM users WHERE LOWER(full_name) = LOWER(?1) AND deleted_at IS NULL ORDER BY created_at LIMIT 1", input.FullName).Scan(
+		&output.PhoneNumber,
+		&output.FullName,
+	)
+	return
+}
+
+func (r *SQLiteRepository) UpdateUser(ctx context.Context, input UpdateUserInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	var dateOfBirth *string
+	if input.DateOfBirth != nil {
+		date := input.DateOfBirth.Format("2006-01-02")
+		dateOfBirth = &date
+	}
+	_, err = r.conn().ExecContext(ctx, "UPDATE users SET phone_number = ?1, full_name = ?2, email = COALESCE(?3, email), email_verified_at = CASE WHEN LOWER(COALESCE(?3, email)) = LOWER(email) THEN email_verified_at END, date_of_birth = COALESCE(?4, date_of_birth), gender = COALESCE(?5, gender), address = COALESCE(?6, address) WHERE id = ?7 AND deleted_at IS NULL",
+		phone.Canonical(input.PhoneNumber), input.FullName, input.Email, dateOfBirth, input.Gender, input.Address, input.ID.String())
+	return
+}
+
+func (r *SQLiteRepository) UpdateLastLoginAndSuccessfullyLogin(ctx context.Context, input UpdateLastLoginAndSuccessfullyLoginInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	_, err = r.conn().ExecContext(ctx, "UPDATE users SET successfully_login = (successfully_login + 1), last_login = ?1 WHERE id = ?2 AND deleted_at IS NULL",
+		input.LastLogin, input.ID.String())
+	return
+}
+
+func (r *SQLiteRepository) VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := r.conn().ExecContext(ctx, "UPDATE users SET email_verified_at = ?1 WHERE id = ?2 AND LOWER(email) = LOWER(?3) AND deleted_at IS NULL",
+		input.VerifiedAt, input.ID.String(), input.Email)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	output.Verified = affected > 0
+	return
+}
+
+func (r *SQLiteRepository) UpdateUserAvatar(ctx context.Context, input UpdateUserAvatarInput) (output UpdateUserAvatarOutput, err error) {
+	// SQLite has no FOR UPDATE, the single connection serializes the
+	// transactions so nobody changes the key between reading and writing it.
+	err = r.WithTx(ctx, func(repo RepositoryInterface) error {
+		tx := repo.(*SQLiteRepository).tx
+		err := tx.QueryRowContext(ctx, "SELECT avatar_key FROM users WHERE id = ?1 AND deleted_at IS NULL", input.ID.String()).Scan(&output.PreviousAvatarKey)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE users SET avatar_key = ?1 WHERE id = ?2", input.AvatarKey, input.ID.String())
+		return err
+	})
+	if err != nil {
+		return UpdateUserAvatarOutput{}, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) UpdateUserPassword(ctx context.Context, input UpdateUserPasswordInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := r.conn().ExecContext(ctx, "UPDATE users SET password = ?1, password_salt = ?2, tokens_valid_after = ?3 WHERE id = ?4 AND deleted_at IS NULL",
+		input.Password, input.PasswordSalt, input.TokensValidAfter, input.ID.String())
+	if err != nil {
+		return
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteRepository) UpdateUserSuspension(ctx context.Context, input UpdateUserSuspensionInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := r.conn().ExecContext(ctx, "UPDATE users SET suspended_at = ?1, tokens_valid_after = COALESCE(?1, tokens_valid_after) WHERE id = ?2 AND deleted_at IS NULL",
+		input.SuspendedAt, input.ID.String())
+	if err != nil {
+		return
+	}
+	return requireAffected(result)
+}
+
+func (r *SQLiteRepository) GetRecentUsers(ctx context.Context, input GetRecentUsersInput) (output []RecentUser, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := r.conn().QueryContext(ctx, "SELECT id, phone_number, full_name, email, created_at, suspended_at FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id LIMIT ?1", input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	return scanRecentUsers(rows)
+}
+
+func (r *SQLiteRepository) InsertUsers(ctx context.Context, input InsertUsersInput) (output InsertUsersOutput, err error) {
+	err = r.WithTx(ctx, func(repo RepositoryInterface) error {
+		tx := repo.(*SQLiteRepository).tx
+		for _, user := range input.Users {
+			id := uuid.New()
+			_, err := tx.ExecContext(ctx, "INSERT INTO users(id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt, created_at) VALUES(?1,?2,?3,?4,?5,?6,?7,?8,?9)",
+				id.String(), phone.Canonical(user.PhoneNumber), user.FullName, user.Email, user.EmailVerifiedAt, user.SuspendedAt, user.Password, user.PasswordSalt, time.Now())
+			if err != nil {
+				return err
+			}
+			output.IDs = append(output.IDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return InsertUsersOutput{}, mapError(err)
+	}
+	return
+}
+
+func (r *SQLiteRepository) GetUsers(ctx context.Context, input GetUsersInput) (output []StoredUser, err error) {
+	defer func() { err = mapError(err) }()
+
+	// IDs are stored in their canonical lower case form, which sorts like
+	// the UUID bytes.
+	rows, err := r.conn().QueryContext(ctx, "SELECT id, phone_number, full_name, email, email_verified_at, suspended_at, password, password_salt, created_at FROM users WHERE id > ?1 AND deleted_at IS NULL ORDER BY id LIMIT ?2", input.AfterID.String(), input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	return scanStoredUsers(rows)
+}
+
+func (r *SQLiteRepository) InsertOutboxEvent(ctx context.Context, input InsertOutboxEventInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	_, err = r.conn().ExecContext(ctx, "INSERT INTO outbox_events(user_id, type, payload, created_at) VALUES(?1,?2,?3,?4)",
+		input.UserID.String(), input.Type, string(input.Payload), time.Now())
+	return
+}
+
+// LockOutbox always succeeds, the single connection already serializes the
+// transactions.
+func (r *SQLiteRepository) LockOutbox(ctx context.Context) (locked bool, err error) {
+	return true, nil
+}
+
+func (r *SQLiteRepository) GetPendingOutboxEvents(ctx context.Context, input GetPendingOutboxEventsInput) (output []OutboxEvent, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := r.conn().QueryContext(ctx, "SELECT e.id, e.user_id, e.type, e.payload, e.created_at, e.attempts, e.next_attempt_at, e.last_error FROM outbox_events e WHERE e.dispatched_at IS NULL AND NOT EXISTS (SELECT 1 FROM outbox_events held WHERE held.user_id = e.user_id AND held.id <= e.id AND held.dispatched_at IS NULL AND held.next_attempt_at > ?2) ORDER BY e.id LIMIT ?1", input.Limit, input.Now)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event OutboxEvent
+		if err = rows.Scan(&event.ID, &event.UserID, &event.Type, (*[]byte)(&event.Payload), &event.CreatedAt, &event.Attempts, &event.NextAttemptAt, &event.LastError); err != nil {
+			return nil, err
+		}
+		output = append(output, event)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *SQLiteRepository) MarkOutboxEventsDispatched(ctx context.Context, input MarkOutboxEventsDispatchedInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	for _, id := range input.IDs {
+		if _, err = r.conn().ExecContext(ctx, "UPDATE outbox_events SET dispatched_at = ?1 WHERE id = ?2", input.DispatchedAt, id); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *SQLiteRepository) RecordOutboxEventFailure(ctx context.Context, input RecordOutboxEventFailureInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	_, err = r.conn().ExecContext(ctx, "UPDATE outbox_events SET attempts = attempts + 1, last_error = ?1, next_attempt_at = ?2 WHERE id = ?3",
+		input.Error, input.NextAttemptAt, input.ID)
+	return
+}
+
+func (r *SQLiteRepository) InsertWebhook(ctx context.Context, input InsertWebhookInput) (output InsertWebhookOutput, err error) {
+	defer func() { err = mapError(err) }()
+
+	events, err := json.Marshal(append([]string{}, input.Events...))
+	if err != nil {
+		return
+	}
+	output = InsertWebhookOutput{ID: uuid.New(), CreatedAt: time.Now()}
+	_, err = r.conn().ExecContext(ctx, "INSERT INTO webhooks(id, url, events, secret, created_at) VALUES(?1,?2,?3,?4,?5)",
+		output.ID.String(), input.URL, string(events), input.Secret, output.CreatedAt)
+	if err != nil {
+		return InsertWebhookOutput{}, err
+	}
+	return
+}
+
+// scanSQLiteWebhook scans the id, url, events, secret and created_at columns.
+func scanSQLiteWebhook(row scanner) (webhook Webhook, err error) {
+	var events string
+	if err = row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt); err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(events), &webhook.Events)
+	return
+}
+
+func (r *SQLiteRepository) GetWebhooks(ctx context.Context) (output []Webhook, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := r.conn().QueryContext(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE deleted_at IS NULL ORDER BY created_at")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook Webhook
+		if webhook, err = scanSQLiteWebhook(rows); err != nil {
+			return nil, err
+		}
+		output = append(output, webhook)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *SQLiteRepository) GetWebhookByID(ctx context.Context, input GetWebhookByIDInput) (output Webhook, err error) {
+	defer func() { err = mapError(err) }()
+
+	output, err = scanSQLiteWebhook(r.conn().QueryRowContext(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE id = ?1 AND deleted_at IS NULL", input.ID.String()))
+	return
+}
+
+func (r *SQLiteRepository) DeleteWebhook(ctx context.Context, input DeleteWebhookInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := r.conn().ExecContext(ctx, "UPDATE webhooks SET deleted_at = ?1 WHERE id = ?2 AND deleted_at IS NULL", time.Now(), input.ID.String())
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (r *SQLiteRepository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	now := time.Now()
+	for _, id := range input.WebhookIDs {
+		_, err = r.conn().ExecContext(ctx, "INSERT INTO webhook_deliveries(webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at) VALUES(?1,?2,?3,?4,'pending',?5,?6) ON CONFLICT (webhook_id, event_id) DO NOTHING",
+			id.String(), input.EventID, input.EventType, string(input.Payload), input.NextAttemptAt, now)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *SQLiteRepository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := r.conn().QueryContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ?1 WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= ?2 ORDER BY next_attempt_at, id LIMIT ?3) RETURNING "+webhookDeliveryColumns,
+		input.LeaseUntil, input.Now, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if delivery, err = scanWebhookDelivery(rows); err != nil {
+			return nil, err
+		}
+		output = append(output, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	// RETURNING does not follow the order of the subquery.
+	sort.Slice(output, func(i, j int) bool { return output[i].ID < output[j].ID })
+	return
+}
+
+func (r *SQLiteRepository) RecordWebhookDeliveryAttempt(ctx context.Context, input RecordWebhookDeliveryAttemptInput) (err error) {
+	var deliveredAt *time.Time
+	if input.Status == WebhookDeliverySucceeded {
+		deliveredAt = &input.Attempt.AttemptedAt
+	}
+	return r.WithTx(ctx, func(repo RepositoryInterface) error {
+		tx := repo.(*SQLiteRepository).tx
+		_, err := tx.ExecContext(ctx, "INSERT INTO webhook_delivery_attempts(delivery_id, attempted_at, status_code, error, duration_ms) VALUES(?1,?2,?3,?4,?5)",
+			input.DeliveryID, input.Attempt.AttemptedAt, input.Attempt.StatusCode, input.Attempt.Error, input.Attempt.Duration.Milliseconds())
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE webhook_deliveries SET status = ?1, attempts = attempts + 1, next_attempt_at = ?2, last_status_code = ?3, last_error = ?4, delivered_at = ?5 WHERE id = ?6",
+			input.Status, input.NextAttemptAt, input.Attempt.StatusCode, input.Attempt.Error, deliveredAt, input.DeliveryID)
+		return err
+	})
+}
+
+func (r *SQLiteRepository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (output []WebhookDelivery, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := r.conn().QueryContext(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ?1 AND status = COALESCE(?2, status) ORDER BY id DESC LIMIT ?3",
+		input.WebhookID.String(), input.Status, input.Limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if delivery, err = scanWebhookDelivery(rows); err != nil {
+			return nil, err
+		}
+		output = append(output, delivery)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *SQLiteRepository) GetWebhookDelivery(ctx context.Context, input GetWebhookDeliveryInput) (output GetWebhookDeliveryOutput, err error) {
+	defer func() { err = mapError(err) }()
+
+	output.WebhookDelivery, err = scanWebhookDelivery(r.conn().QueryRowContext(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?1 AND webhook_id = ?2",
+		input.ID, input.WebhookID.String()))
+	if err != nil {
+		return
+	}
+
+	rows, err := r.conn().QueryContext(ctx, "SELECT attempted_at, status_code, error, duration_ms FROM webhook_delivery_attempts WHERE delivery_id = ?1 ORDER BY id", input.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt WebhookDeliveryAttempt
+		var durationMs int64
+		if err = rows.Scan(&attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &durationMs); err != nil {
+			return
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		output.Log = append(output.Log, attempt)
+	}
+	err = rows.Err()
+	return
+}
+
+func (r *SQLiteRepository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error) {
+	defer func() { err = mapError(err) }()
+
+	result, err := r.conn().ExecContext(ctx, "UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ?1 WHERE id = ?2 AND webhook_id = ?3",
+		input.At, input.ID, input.WebhookID.String())
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		err = ErrNotFound
+	}
+	return
+}
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and
+// rolling it back otherwise. Calling WithTx on the repository passed to fn
+// runs it in a savepoint of the same transaction.
+func (r *SQLiteRepository) WithTx(ctx context.Context, fn func(repo RepositoryInterface) error) (err error) {
+	if r.tx != nil {
+		return runSavepoint(ctx, r.tx, func() error { return fn(r) })
+	}
+	defer func() { err = mapError(err) }()
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	return runTx(tx, func() error {
+		return fn(&SQLiteRepository{Db: r.Db, tx: tx})
+	})
+}
+
+// softDelete marks the user deleted like an operator setting deleted_at.
+func (r *SQLiteRepository) softDelete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.conn().ExecContext(ctx, "UPDATE users SET deleted_at = ?1 WHERE id = ?2", time.Now(), id.String())
+	return err
+}
+
+// Close closes the database.
+func (r *SQLiteRepository) Close() error {
+	return r.Db.Close()
+}
@@ -2,6 +2,9 @@
 package repository
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
@@ -21,14 +24,64 @@ type User struct {
 }
 
 type UserInfo struct {
-	PhoneNumber       string `validate:"required,min=10,max=13,indonesian_phone_number"`
+	PhoneNumber       string `validate:"required,indonesian_phone_number"`
 	FullName          string `validate:"required,min=3,max=60"`
 	LastLogin         *time.Time
 	SuccessfullyLogin int
+	// EmailVerifiedAt is set once the user confirmed Profile.Email, only
+	// verified emails can be used to log in.
+	EmailVerifiedAt *time.Time
+	// AvatarKey is the blob store key prefix of the user's avatar images.
+	AvatarKey *string
+	// SuspendedAt is set while the user is suspended, suspended users
+	// cannot log in.
+	SuspendedAt *time.Time
+	// TokensValidAfter revokes the tokens issued before it, it is set when
+	// the password is reset or the user suspended. Only GetUserByID reads
+	// it.
+	TokensValidAfter *time.Time
+	Profile
+}
+
+// Profile holds the optional profile fields, nil means not set.
+type Profile struct {
+	Email       *string    `validate:"omitempty,email,max=254"`
+	DateOfBirth *time.Time `validate:"omitempty,date_of_birth"`
+	Gender      *string    `validate:"omitempty,oneof=male female other"`
+	Address     *Address
+}
+
+// Address is stored as a JSON document in the users.address column.
+type Address struct {
+	Street     string `json:"street" validate:"required,max=255"`
+	City       string `json:"city" validate:"required,max=100"`
+	Province   string `json:"province,omitempty" validate:"max=100"`
+	PostalCode string `json:"postalCode,omitempty" validate:"omitempty,max=16"`
+	Country    string `json:"country" validate:"required,iso3166_1_alpha2"`
+}
+
+func (a Address) Value() (driver.Value, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	// lib/pq sends []byte as bytea, which jsonb does not accept.
+	return string(b), nil
+}
+
+func (a *Address) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("cannot scan %T into Address", src)
+	}
 }
 
 type UserSecret struct {
-	Password     string `validate:"required,min=6,max=64,valid_password"`
+	Password     string `validate:"required"`
 	PasswordSalt string
 }
 
@@ -40,6 +93,18 @@ type GetUserByPhoneNumberInput struct {
 	PhoneNumber string
 }
 
+type LoginIdentifierType string
+
+const (
+	LoginIdentifierPhoneNumber LoginIdentifierType = "phone_number"
+	LoginIdentifierEmail       LoginIdentifierType = "email"
+)
+
+type GetUserByLoginIdentifierInput struct {
+	Type  LoginIdentifierType
+	Value string
+}
+
 type GetUserByIDInput struct {
 	ID uuid.UUID
 }
@@ -50,11 +115,256 @@ type GetUserByFullNameInput struct {
 
 type UpdateUserInput struct {
 	ID          uuid.UUID
-	PhoneNumber string `validate:"required,min=10,max=13,indonesian_phone_number"`
+	PhoneNumber string `validate:"required,indonesian_phone_number"`
 	FullName    string `validate:"required,min=3,max=60"`
+	// Profile fields left nil keep their current value.
+	Profile
 }
 
 type UpdateLastLoginAndSuccessfullyLoginInput struct {
 	ID        uuid.UUID
 	LastLogin *time.Time
 }
+
+type UserPhoneNumber struct {
+	ID          uuid.UUID
+	PhoneNumber string
+}
+
+type UpdateUserPhoneNumberInput struct {
+	ID          uuid.UUID
+	PhoneNumber string
+}
+
+type VerifyEmailInput struct {
+	ID         uuid.UUID
+	Email      string
+	VerifiedAt time.Time
+}
+
+type VerifyEmailOutput struct {
+	// Verified is false when the user no longer has the given email.
+	Verified bool
+}
+
+type UpdateUserPasswordInput struct {
+	ID uuid.UUID
+	// Password is the bcrypt hash of the password and PasswordSalt.
+	UserSecret
+	// TokensValidAfter revokes the tokens issued before it.
+	TokensValidAfter time.Time
+}
+
+type UpdateUserSuspensionInput struct {
+	ID uuid.UUID
+	// SuspendedAt suspends the user and revokes the tokens issued before
+	// it, nil lifts the suspension.
+	SuspendedAt *time.Time
+}
+
+type GetRecentUsersInput struct {
+	Limit int
+}
+
+// RecentUser is a user of the registrations listing.
+type RecentUser struct {
+	ID          uuid.UUID
+	PhoneNumber string
+	FullName    string
+	Email       *string
+	CreatedAt   time.Time
+	SuspendedAt *time.Time
+}
+
+// InsertUsersInput holds the users to insert. Their phone number, full
+// name, email, EmailVerifiedAt, SuspendedAt and secret are stored, the
+// other fields are ignored.
+type InsertUsersInput struct {
+	Users []User
+}
+
+type InsertUsersOutput struct {
+	IDs []uuid.UUID
+}
+
+// GetUsersInput selects the page of Limit users following AfterID, uuid.Nil
+// for the first page.
+type GetUsersInput struct {
+	AfterID uuid.UUID
+	Limit   int
+}
+
+// StoredUser is a user with their secret and the time they registered.
+// Only the phone number, full name, email, email verification and
+// suspension of UserInfo are set.
+type StoredUser struct {
+	User
+	CreatedAt time.Time
+}
+
+type UpdateUserAvatarInput struct {
+	ID        uuid.UUID
+	AvatarKey string
+}
+
+type UpdateUserAvatarOutput struct {
+	PreviousAvatarKey *string
+}
+
+// OutboxEvent is a domain event of a user waiting in the outbox_events
+// table to be delivered.
+type OutboxEvent struct {
+	ID     int64
+	UserID uuid.UUID
+	Type   string
+	// Payload is the JSON document of the event.
+	Payload   json.RawMessage
+	CreatedAt time.Time
+	// Attempts counts the failed deliveries, the next one is not made
+	// before NextAttemptAt.
+	Attempts      int
+	NextAttemptAt *time.Time
+	LastError     *string
+}
+
+type InsertOutboxEventInput struct {
+	UserID  uuid.UUID
+	Type    string
+	Payload json.RawMessage
+}
+
+type GetPendingOutboxEventsInput struct {
+	Limit int
+	// Now leaves out the events whose next attempt is after it, and the
+	// later events of their users.
+	Now time.Time
+}
+
+type MarkOutboxEventsDispatchedInput struct {
+	IDs          []int64
+	DispatchedAt time.Time
+}
+
+type RecordOutboxEventFailureInput struct {
+	ID            int64
+	Error         string
+	NextAttemptAt time.Time
+}
+
+// Webhook is a subscription of a URL to user events.
+type Webhook struct {
+	ID  uuid.UUID
+	URL string
+	// Events lists the event types delivered, all of them when empty.
+	Events []string
+	// Secret is the HMAC key the payloads are signed with.
+	Secret    string
+	CreatedAt time.Time
+}
+
+type InsertWebhookInput struct {
+	URL    string
+	Events []string
+	Secret string
+}
+
+type InsertWebhookOutput struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+}
+
+type GetWebhookByIDInput struct {
+	ID uuid.UUID
+}
+
+type DeleteWebhookInput struct {
+	ID uuid.UUID
+}
+
+// Statuses of a WebhookDelivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	// WebhookDeliveryDead is the dead letter state of deliveries that ran
+	// out of attempts, they are only retried when redelivered.
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook.
+type WebhookDelivery struct {
+	ID        int64
+	WebhookID uuid.UUID
+	EventID   int64
+	EventType string
+	// Payload is the request body.
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int
+	NextAttemptAt *time.Time
+	// LastStatusCode and LastError describe the last attempt.
+	LastStatusCode *int
+	LastError      *string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// WebhookDeliveryAttempt is an entry of the delivery log.
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time
+	// StatusCode is nil when no response was received.
+	StatusCode *int
+	Error      *string
+	Duration   time.Duration
+}
+
+// InsertWebhookDeliveriesInput creates a pending delivery of an event to
+// every webhook of WebhookIDs that does not have one yet.
+type InsertWebhookDeliveriesInput struct {
+	WebhookIDs    []uuid.UUID
+	EventID       int64
+	EventType     string
+	Payload       json.RawMessage
+	NextAttemptAt time.Time
+}
+
+// ClaimWebhookDeliveriesInput claims the pending deliveries due at Now by
+// moving their next attempt to LeaseUntil, so they are retried then if the
+// claiming instance does not record the attempt.
+type ClaimWebhookDeliveriesInput struct {
+	Now        time.Time
+	LeaseUntil time.Time
+	Limit      int
+}
+
+type RecordWebhookDeliveryAttemptInput struct {
+	DeliveryID int64
+	Attempt    WebhookDeliveryAttempt
+	// Status is the status of the delivery after the attempt, pending
+	// ones are retried at NextAttemptAt.
+	Status        string
+	NextAttemptAt *time.Time
+}
+
+type GetWebhookDeliveriesInput struct {
+	WebhookID uuid.UUID
+	// Status filters the deliveries when set.
+	Status *string
+	Limit  int
+}
+
+type GetWebhookDeliveryInput struct {
+	WebhookID uuid.UUID
+	ID        int64
+}
+
+type GetWebhookDeliveryOutput struct {
+	WebhookDelivery
+	// Log is the delivery log, oldest first.
+	Log []WebhookDeliveryAttempt
+}
+
+type RedeliverWebhookDeliveryInput struct {
+	WebhookID uuid.UUID
+	ID        int64
+	At        time.Time
+}
@@ -0,0 +1,90 @@
+// Package service holds the user domain logic shared by the REST handlers
+// and the gRPC API, so both validate, hash, record events and count metrics
+// the same way.
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/jwt"
+	"InterviewBackendSawitProGolang/pkg/validator"
+	"InterviewBackendSawitProGolang/repository"
+)
+
+var (
+	// ErrWrongCredentials is returned for unknown users, wrong passwords and
+	// unverified emails alike, so a login does not reveal which it was.
+	ErrWrongCredentials = errors.New("wrong credentials")
+	// ErrIdentifierRequired is returned by a login without phone number
+	// and email.
+	ErrIdentifierRequired = errors.New("phonenumber or email is required")
+	// ErrSuspended is returned by a login with the right password of a
+	// suspended user.
+	ErrSuspended = errors.New("user is suspended")
+)
+
+// ValidationError lists the messages of every invalid field by its path,
+// e.g. "address.city".
+type ValidationError struct {
+	Fields map[string][]string
+}
+
+func (e *ValidationError) Error() string {
+	paths := make([]string, 0, len(e.Fields))
+	for path := range e.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var messages []string
+	for _, path := range paths {
+		messages = append(messages, e.Fields[path]...)
+	}
+	return "invalid input: " + strings.Join(messages, "; ")
+}
+
+type Service struct {
+	Repository  repository.RepositoryInterface
+	TokenSigner *jwt.Signer
+	// BcryptCost is the cost new password hashes are generated with.
+	BcryptCost int
+	// TokenTTL is how long the tokens handed out on login are valid.
+	TokenTTL time.Duration
+}
+
+type NewServiceOptions struct {
+	Repository  repository.RepositoryInterface
+	TokenSigner *jwt.Signer
+	BcryptCost  int
+	// TokenTTL defaults to jwt.DefaultTokenTTL.
+	TokenTTL time.Duration
+}
+
+func NewService(opts NewServiceOptions) *Service {
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = jwt.DefaultTokenTTL
+	}
+	return &Service{
+		Repository:  opts.Repository,
+		TokenSigner: opts.TokenSigner,
+		BcryptCost:  opts.BcryptCost,
+		TokenTTL:    opts.TokenTTL,
+	}
+}
+
+// validate runs the validator on input, returning a *ValidationError when
+// it is invalid.
+func validate(input interface{}) error {
+	err := validator.Validate(input)
+	if err == nil {
+		return nil
+	}
+	var fields map[string][]string
+	if json.Unmarshal([]byte(err.Error()), &fields) != nil {
+		return err
+	}
+	return &ValidationError{Fields: fields}
+}
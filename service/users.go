@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"InterviewBackendSawitProGolang/pkg/metrics"
+	"InterviewBackendSawitProGolang/pkg/outbox"
+	"InterviewBackendSawitProGolang/pkg/phone"
+	"InterviewBackendSawitProGolang/pkg/tracing"
+	"InterviewBackendSawitProGolang/repository"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanhpk/randstr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type RegisterInput struct {
+	PhoneNumber string
+	FullName    string
+	Password    string
+}
+
+// Register creates a user, returning repository.ErrPhoneNumberTaken when
+// the number belongs to another one.
+func (s *Service) Register(ctx context.Context, input RegisterInput) (uuid.UUID, error) {
+	passwordSalt := randstr.String(10)
+	user := repository.User{
+		UserInfo: repository.UserInfo{
+			PhoneNumber: phone.Canonical(input.PhoneNumber),
+			FullName:    input.FullName,
+		},
+		UserSecret: repository.UserSecret{
+			Password:     input.Password,
+			PasswordSalt: passwordSalt,
+		},
+	}
+	if err := validate(user); err != nil {
+		return uuid.Nil, err
+	}
+
+	hash, err := s.hashPassword(ctx, input.Password, passwordSalt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	user.Password = hash
+
+	// The check gives the usual answer for a taken number, the unique
+	// constraint catches registrations racing past it.
+	var output repository.InsertUserOutput
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		_, err := repo.GetUserByPhoneNumber(ctx, repository.GetUserByPhoneNumberInput{
+			PhoneNumber: user.PhoneNumber,
+		})
+		if err == nil {
+			return repository.ErrPhoneNumberTaken
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		output, err = repo.InsertUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		return outbox.Record(ctx, repo, output.ID, outbox.UserRegistered, outbox.UserRegisteredData{
+			PhoneNumber: user.PhoneNumber,
+			FullName:    user.FullName,
+		})
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	metrics.Registrations.Inc()
+	return output.ID, nil
+}
+
+func (s *Service) hashPassword(ctx context.Context, password, salt string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	timer := prometheus.NewTimer(metrics.PasswordHashDuration.WithLabelValues(metrics.PasswordHash))
+	hash, err := bcrypt.GenerateFromPassword([]byte(password+salt), s.BcryptCost)
+	timer.ObserveDuration()
+	tracing.End(span, err)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// LoginInput identifies the user by PhoneNumber or, when it is nil, by
+// Email.
+type LoginInput struct {
+	PhoneNumber *string
+	Email       *string
+	Password    string
+}
+
+type LoginOutput struct {
+	ID    uuid.UUID
+	Token string
+}
+
+// Login checks the password of a user and returns a token for them. Email
+// logins require a verified email.
+func (s *Service) Login(ctx context.Context, input LoginInput) (LoginOutput, error) {
+	now := time.Now()
+	var identifier repository.GetUserByLoginIdentifierInput
+	switch {
+	case input.PhoneNumber != nil:
+		identifier = repository.GetUserByLoginIdentifierInput{
+			Type:  repository.LoginIdentifierPhoneNumber,
+			Value: phone.Canonical(*input.PhoneNumber),
+		}
+	case input.Email != nil:
+		identifier = repository.GetUserByLoginIdentifierInput{
+			Type:  repository.LoginIdentifierEmail,
+			Value: *input.Email,
+		}
+	default:
+		return LoginOutput{}, ErrIdentifierRequired
+	}
+
+	user, err := s.Repository.GetUserByLoginIdentifier(ctx, identifier)
+	if errors.Is(err, repository.ErrNotFound) {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return LoginOutput{}, ErrWrongCredentials
+	}
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	if identifier.Type == repository.LoginIdentifierEmail && user.EmailVerifiedAt == nil {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return LoginOutput{}, ErrWrongCredentials
+	}
+
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	timer := prometheus.NewTimer(metrics.PasswordHashDuration.WithLabelValues(metrics.PasswordCompare))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password+user.PasswordSalt))
+	timer.ObserveDuration()
+	span.End()
+	if err != nil {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return LoginOutput{}, ErrWrongCredentials
+	}
+	// Checked after the password so the answer does not reveal that a
+	// suspended user exists.
+	if user.SuspendedAt != nil {
+		metrics.Logins.WithLabelValues(metrics.LoginLockedOut).Inc()
+		return LoginOutput{}, ErrSuspended
+	}
+
+	token, err := s.TokenSigner.CreateExpiringJWS(ctx, map[string]interface{}{"id": user.ID.String()}, s.TokenTTL)
+	if err != nil {
+		return LoginOutput{}, fmt.Errorf("creating token: %w", err)
+	}
+
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		if err := repo.UpdateLastLoginAndSuccessfullyLogin(ctx, repository.UpdateLastLoginAndSuccessfullyLoginInput{
+			ID:        user.ID,
+			LastLogin: &now,
+		}); err != nil {
+			return err
+		}
+		return outbox.Record(ctx, repo, user.ID, outbox.UserLoggedIn, outbox.UserLoggedInData{
+			IdentifierType: string(identifier.Type),
+		})
+	})
+	if err != nil {
+		return LoginOutput{}, err
+	}
+	metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
+	return LoginOutput{ID: user.ID, Token: string(token)}, nil
+}
+
+// GetProfile returns the profile of a user, repository.ErrNotFound when
+// there is none.
+func (s *Service) GetProfile(ctx context.Context, id uuid.UUID) (repository.UserInfo, error) {
+	return s.Repository.GetUserByID(ctx, repository.GetUserByIDInput{ID: id})
+}
+
+// TokenActive tells whether a token issued to a user at issuedAt may still
+// be used: the user exists, is not suspended and did not reset their
+// password nor get suspended after issuedAt. Tokens without issuedAt only
+// pass when neither happened yet. The user is read from the primary,
+// bypassing the cache, so changes made by another process, e.g. userctl,
+// are seen at once.
+func (s *Service) TokenActive(ctx context.Context, id uuid.UUID, issuedAt time.Time) (bool, error) {
+	user, err := s.Repository.GetUserByID(repository.WithPrimary(ctx), repository.GetUserByIDInput{ID: id})
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.SuspendedAt != nil {
+		return false, nil
+	}
+	// iat has second precision, so tokens issued in the second of the
+	// change are revoked too.
+	return user.TokensValidAfter == nil || !issuedAt.Before(*user.TokensValidAfter), nil
+}
+
+// UpdateProfile replaces the phone number and full name of a user and sets
+// the profile fields of input that are not nil. It returns
+// repository.ErrPhoneNumberTaken or repository.ErrEmailTaken when another
+// user has them.
+func (s *Service) UpdateProfile(ctx context.Context, input repository.UpdateUserInput) error {
+	input.PhoneNumber = phone.Canonical(input.PhoneNumber)
+	if err := validate(input); err != nil {
+		return err
+	}
+
+	user, err := s.Repository.GetUserByPhoneNumber(ctx, repository.GetUserByPhoneNumberInput{
+		PhoneNumber: input.PhoneNumber,
+	})
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err == nil && user.ID != input.ID {
+		return repository.ErrPhoneNumberTaken
+	}
+
+	if input.Email != nil {
+		user, err := s.Repository.GetUserByLoginIdentifier(ctx, repository.GetUserByLoginIdentifierInput{
+			Type:  repository.LoginIdentifierEmail,
+			Value: *input.Email,
+		})
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err == nil && user.ID != input.ID {
+			return repository.ErrEmailTaken
+		}
+	}
+
+	return s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		current, err := repo.GetUserByID(ctx, repository.GetUserByIDInput{ID: input.ID})
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateUser(ctx, input); err != nil {
+			return err
+		}
+		if err := outbox.Record(ctx, repo, input.ID, outbox.ProfileUpdated, profileUpdatedData(input)); err != nil {
+			return err
+		}
+		if current.PhoneNumber == input.PhoneNumber {
+			return nil
+		}
+		return outbox.Record(ctx, repo, input.ID, outbox.PhoneNumberChanged, outbox.PhoneNumberChangedData{
+			PreviousPhoneNumber: current.PhoneNumber,
+			PhoneNumber:         input.PhoneNumber,
+		})
+	})
+}
+
+// ResetPassword replaces the password of a user after checking it against
+// the password policy and revokes the tokens issued to them. It returns
+// repository.ErrNotFound when there is no such user.
+func (s *Service) ResetPassword(ctx context.Context, id uuid.UUID, password string) error {
+	current, err := s.Repository.GetUserByID(ctx, repository.GetUserByIDInput{ID: id})
+	if err != nil {
+		return err
+	}
+	passwordSalt := randstr.String(10)
+	user := repository.User{
+		ID:       id,
+		UserInfo: current,
+		UserSecret: repository.UserSecret{
+			Password:     password,
+			PasswordSalt: passwordSalt,
+		},
+	}
+	if err := validate(user); err != nil {
+		return err
+	}
+	hash, err := s.hashPassword(ctx, password, passwordSalt)
+	if err != nil {
+		return err
+	}
+	return s.Repository.UpdateUserPassword(ctx, repository.UpdateUserPasswordInput{
+		ID:               id,
+		UserSecret:       repository.UserSecret{Password: hash, PasswordSalt: passwordSalt},
+		TokensValidAfter: time.Now(),
+	})
+}
+
+// SetSuspended suspends a user or lifts the suspension. Suspended users
+// cannot log in and their tokens are rejected, see TokenActive. It returns
+// repository.ErrNotFound when there is no such user.
+func (s *Service) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	input := repository.UpdateUserSuspensionInput{ID: id}
+	if suspended {
+		now := time.Now()
+		input.SuspendedAt = &now
+	}
+	return s.Repository.UpdateUserSuspension(ctx, input)
+}
+
+func profileUpdatedData(input repository.UpdateUserInput) outbox.ProfileUpdatedData {
+	data := outbox.ProfileUpdatedData{
+		PhoneNumber: input.PhoneNumber,
+		FullName:    input.FullName,
+		Email:       input.Email,
+		Gender:      input.Gender,
+		Address:     input.Address,
+	}
+	if input.DateOfBirth != nil {
+		dateOfBirth := input.DateOfBirth.Format("2006-01-02")
+		data.DateOfBirth = &dateOfBirth
+	}
+	return data
+}